@@ -26,11 +26,11 @@ func CleanDB(t *testing.T) {
 		postgres.Open(
 			fmt.Sprintf(
 				"postgres://%s:%s@%s:%v/%s",
-				Conf.DB.User,
-				Conf.DB.Password,
-				Conf.DB.Host,
-				Conf.DB.Port,
-				Conf.DB.Database+"_test",
+				Conf.Postgres.User,
+				Conf.Postgres.Password,
+				Conf.Postgres.Host,
+				Conf.Postgres.Port,
+				Conf.Postgres.Database+"_test",
 			),
 		), &gorm.Config{},
 	)
@@ -9,6 +9,7 @@ package service_discovery
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 )
@@ -20,29 +21,786 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type EventType int32
+
+const (
+	EventType_UNSPECIFIED  EventType = 0
+	EventType_REGISTERED   EventType = 1
+	EventType_DEREGISTERED EventType = 2
+	EventType_ACTIVATED    EventType = 3
+	EventType_DEACTIVATED  EventType = 4
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "UNSPECIFIED",
+		1: "REGISTERED",
+		2: "DEREGISTERED",
+		3: "ACTIVATED",
+		4: "DEACTIVATED",
+	}
+	EventType_value = map[string]int32{
+		"UNSPECIFIED":  0,
+		"REGISTERED":   1,
+		"DEREGISTERED": 2,
+		"ACTIVATED":    3,
+		"DEACTIVATED":  4,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_pb_discovery_proto_enumTypes[0].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_api_pb_discovery_proto_enumTypes[0]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{0}
+}
+
 type Empty struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 }
 
-func (x *Empty) Reset() {
-	*x = Empty{}
+func (x *Empty) Reset() {
+	*x = Empty{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{0}
+}
+
+type NameAndAddressMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name     string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address  string            `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Version  string            `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Tags     []string          `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Defaults to 1 when omitted
+	Weight int32 `protobuf:"varint,6,opt,name=weight,proto3" json:"weight,omitempty"`
+	// Lease in seconds, instances with a ttl must heartbeat instead of being probed
+	Ttl int32 `protobuf:"varint,7,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	// Overrides the checker configuration for this instance
+	Check *CheckMsg `protobuf:"bytes,8,opt,name=check,proto3" json:"check,omitempty"`
+}
+
+func (x *NameAndAddressMsg) Reset() {
+	*x = NameAndAddressMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NameAndAddressMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NameAndAddressMsg) ProtoMessage() {}
+
+func (x *NameAndAddressMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NameAndAddressMsg.ProtoReflect.Descriptor instead.
+func (*NameAndAddressMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NameAndAddressMsg) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *NameAndAddressMsg) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *NameAndAddressMsg) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *NameAndAddressMsg) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *NameAndAddressMsg) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *NameAndAddressMsg) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *NameAndAddressMsg) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *NameAndAddressMsg) GetCheck() *CheckMsg {
+	if x != nil {
+		return x.Check
+	}
+	return nil
+}
+
+type CheckMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// "http", "grpc", "tcp", "ttl" or "none"
+	Type     string  `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Path     string  `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Statuses []int32 `protobuf:"varint,3,rep,packed,name=statuses,proto3" json:"statuses,omitempty"`
+	// In seconds
+	Interval int32 `protobuf:"varint,4,opt,name=interval,proto3" json:"interval,omitempty"`
+	// In seconds
+	Timeout     int32  `protobuf:"varint,5,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	GrpcService string `protobuf:"bytes,6,opt,name=grpc_service,json=grpcService,proto3" json:"grpc_service,omitempty"`
+}
+
+func (x *CheckMsg) Reset() {
+	*x = CheckMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CheckMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckMsg) ProtoMessage() {}
+
+func (x *CheckMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckMsg.ProtoReflect.Descriptor instead.
+func (*CheckMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CheckMsg) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CheckMsg) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CheckMsg) GetStatuses() []int32 {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+func (x *CheckMsg) GetInterval() int32 {
+	if x != nil {
+		return x.Interval
+	}
+	return 0
+}
+
+func (x *CheckMsg) GetTimeout() int32 {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+func (x *CheckMsg) GetGrpcService() string {
+	if x != nil {
+		return x.GrpcService
+	}
+	return ""
+}
+
+type InstanceMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name     string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address  string            `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Version  string            `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Tags     []string          `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Weight   int32             `protobuf:"varint,6,opt,name=weight,proto3" json:"weight,omitempty"`
+	Ttl      int32             `protobuf:"varint,7,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Check    *CheckMsg         `protobuf:"bytes,8,opt,name=check,proto3" json:"check,omitempty"`
+	IsActive bool              `protobuf:"varint,9,opt,name=is_active,json=isActive,proto3" json:"is_active,omitempty"`
+	Health   *HealthMsg        `protobuf:"bytes,10,opt,name=health,proto3" json:"health,omitempty"`
+}
+
+func (x *InstanceMsg) Reset() {
+	*x = InstanceMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InstanceMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstanceMsg) ProtoMessage() {}
+
+func (x *InstanceMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstanceMsg.ProtoReflect.Descriptor instead.
+func (*InstanceMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *InstanceMsg) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *InstanceMsg) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *InstanceMsg) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *InstanceMsg) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *InstanceMsg) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *InstanceMsg) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *InstanceMsg) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *InstanceMsg) GetCheck() *CheckMsg {
+	if x != nil {
+		return x.Check
+	}
+	return nil
+}
+
+func (x *InstanceMsg) GetIsActive() bool {
+	if x != nil {
+		return x.IsActive
+	}
+	return false
+}
+
+func (x *InstanceMsg) GetHealth() *HealthMsg {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+type HealthMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// "unknown", "passing", "failing" or "quarantined"
+	State string `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	// Why the instance is in its current state
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Consecutive successful checks
+	Successes int32 `protobuf:"varint,3,opt,name=successes,proto3" json:"successes,omitempty"`
+	// Consecutive failed checks
+	Failures         int32                  `protobuf:"varint,4,opt,name=failures,proto3" json:"failures,omitempty"`
+	Since            *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=since,proto3" json:"since,omitempty"`
+	QuarantinedUntil *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=quarantined_until,json=quarantinedUntil,proto3" json:"quarantined_until,omitempty"`
+	// Unset until the instance is checked for the first time
+	LastCheck  *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=last_check,json=lastCheck,proto3" json:"last_check,omitempty"`
+	LastResult bool                   `protobuf:"varint,8,opt,name=last_result,json=lastResult,proto3" json:"last_result,omitempty"`
+	LastError  string                 `protobuf:"bytes,9,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+}
+
+func (x *HealthMsg) Reset() {
+	*x = HealthMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HealthMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthMsg) ProtoMessage() {}
+
+func (x *HealthMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthMsg.ProtoReflect.Descriptor instead.
+func (*HealthMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HealthMsg) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *HealthMsg) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *HealthMsg) GetSuccesses() int32 {
+	if x != nil {
+		return x.Successes
+	}
+	return 0
+}
+
+func (x *HealthMsg) GetFailures() int32 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *HealthMsg) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *HealthMsg) GetQuarantinedUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.QuarantinedUntil
+	}
+	return nil
+}
+
+func (x *HealthMsg) GetLastCheck() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastCheck
+	}
+	return nil
+}
+
+func (x *HealthMsg) GetLastResult() bool {
+	if x != nil {
+		return x.LastResult
+	}
+	return false
+}
+
+func (x *HealthMsg) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+type WeightMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Weight  int32  `protobuf:"varint,3,opt,name=weight,proto3" json:"weight,omitempty"`
+}
+
+func (x *WeightMsg) Reset() {
+	*x = WeightMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WeightMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeightMsg) ProtoMessage() {}
+
+func (x *WeightMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeightMsg.ProtoReflect.Descriptor instead.
+func (*WeightMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WeightMsg) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *WeightMsg) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *WeightMsg) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type ServiceNameMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Selector of the form "version=v2,zone=eu-1", ignored by Watch
+	Selector string `protobuf:"bytes,2,opt,name=selector,proto3" json:"selector,omitempty"`
+	// Tags every matching instance must carry, ignored by Watch
+	Tags []string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Key for the consistent-hash balancer, used by FindService only
+	HashKey string `protobuf:"bytes,4,opt,name=hash_key,json=hashKey,proto3" json:"hash_key,omitempty"`
+}
+
+func (x *ServiceNameMsg) Reset() {
+	*x = ServiceNameMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceNameMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceNameMsg) ProtoMessage() {}
+
+func (x *ServiceNameMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceNameMsg.ProtoReflect.Descriptor instead.
+func (*ServiceNameMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ServiceNameMsg) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ServiceNameMsg) GetSelector() string {
+	if x != nil {
+		return x.Selector
+	}
+	return ""
+}
+
+func (x *ServiceNameMsg) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ServiceNameMsg) GetHashKey() string {
+	if x != nil {
+		return x.HashKey
+	}
+	return ""
+}
+
+type ServiceAddressMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+}
+
+func (x *ServiceAddressMsg) Reset() {
+	*x = ServiceAddressMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServiceAddressMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceAddressMsg) ProtoMessage() {}
+
+func (x *ServiceAddressMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceAddressMsg.ProtoReflect.Descriptor instead.
+func (*ServiceAddressMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ServiceAddressMsg) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type ListAddrsMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Address []string `protobuf:"bytes,1,rep,name=address,proto3" json:"address,omitempty"`
+}
+
+func (x *ListAddrsMsg) Reset() {
+	*x = ListAddrsMsg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_pb_discovery_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListAddrsMsg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAddrsMsg) ProtoMessage() {}
+
+func (x *ListAddrsMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAddrsMsg.ProtoReflect.Descriptor instead.
+func (*ListAddrsMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListAddrsMsg) GetAddress() []string {
+	if x != nil {
+		return x.Address
+	}
+	return nil
+}
+
+type ListNamesMsg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name []string `protobuf:"bytes,1,rep,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *ListNamesMsg) Reset() {
+	*x = ListNamesMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_pb_discovery_proto_msgTypes[0]
+		mi := &file_api_pb_discovery_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Empty) String() string {
+func (x *ListNamesMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Empty) ProtoMessage() {}
+func (*ListNamesMsg) ProtoMessage() {}
 
-func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pb_discovery_proto_msgTypes[0]
+func (x *ListNamesMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -53,37 +811,46 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
-func (*Empty) Descriptor() ([]byte, []int) {
-	return file_api_pb_discovery_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use ListNamesMsg.ProtoReflect.Descriptor instead.
+func (*ListNamesMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{9}
 }
 
-type NameAndAddressMsg struct {
+func (x *ListNamesMsg) GetName() []string {
+	if x != nil {
+		return x.Name
+	}
+	return nil
+}
+
+type ServiceEventMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Type      EventType              `protobuf:"varint,1,opt,name=type,proto3,enum=service_discovery.EventType" json:"type,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Address   string                 `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 }
 
-func (x *NameAndAddressMsg) Reset() {
-	*x = NameAndAddressMsg{}
+func (x *ServiceEventMsg) Reset() {
+	*x = ServiceEventMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_pb_discovery_proto_msgTypes[1]
+		mi := &file_api_pb_discovery_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *NameAndAddressMsg) String() string {
+func (x *ServiceEventMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*NameAndAddressMsg) ProtoMessage() {}
+func (*ServiceEventMsg) ProtoMessage() {}
 
-func (x *NameAndAddressMsg) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pb_discovery_proto_msgTypes[1]
+func (x *ServiceEventMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -94,50 +861,65 @@ func (x *NameAndAddressMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use NameAndAddressMsg.ProtoReflect.Descriptor instead.
-func (*NameAndAddressMsg) Descriptor() ([]byte, []int) {
-	return file_api_pb_discovery_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use ServiceEventMsg.ProtoReflect.Descriptor instead.
+func (*ServiceEventMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *NameAndAddressMsg) GetName() string {
+func (x *ServiceEventMsg) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_UNSPECIFIED
+}
+
+func (x *ServiceEventMsg) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *NameAndAddressMsg) GetAddress() string {
+func (x *ServiceEventMsg) GetAddress() string {
 	if x != nil {
 		return x.Address
 	}
 	return ""
 }
 
-type ServiceNameMsg struct {
+func (x *ServiceEventMsg) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type ExportSnapshotMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// "json" or "yaml", defaults to "json"
+	Format string `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
 }
 
-func (x *ServiceNameMsg) Reset() {
-	*x = ServiceNameMsg{}
+func (x *ExportSnapshotMsg) Reset() {
+	*x = ExportSnapshotMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_pb_discovery_proto_msgTypes[2]
+		mi := &file_api_pb_discovery_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ServiceNameMsg) String() string {
+func (x *ExportSnapshotMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServiceNameMsg) ProtoMessage() {}
+func (*ExportSnapshotMsg) ProtoMessage() {}
 
-func (x *ServiceNameMsg) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pb_discovery_proto_msgTypes[2]
+func (x *ExportSnapshotMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -148,43 +930,44 @@ func (x *ServiceNameMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServiceNameMsg.ProtoReflect.Descriptor instead.
-func (*ServiceNameMsg) Descriptor() ([]byte, []int) {
-	return file_api_pb_discovery_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ExportSnapshotMsg.ProtoReflect.Descriptor instead.
+func (*ExportSnapshotMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *ServiceNameMsg) GetName() string {
+func (x *ExportSnapshotMsg) GetFormat() string {
 	if x != nil {
-		return x.Name
+		return x.Format
 	}
 	return ""
 }
 
-type ServiceAddressMsg struct {
+type SnapshotMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Format string `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
 }
 
-func (x *ServiceAddressMsg) Reset() {
-	*x = ServiceAddressMsg{}
+func (x *SnapshotMsg) Reset() {
+	*x = SnapshotMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_pb_discovery_proto_msgTypes[3]
+		mi := &file_api_pb_discovery_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ServiceAddressMsg) String() string {
+func (x *SnapshotMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServiceAddressMsg) ProtoMessage() {}
+func (*SnapshotMsg) ProtoMessage() {}
 
-func (x *ServiceAddressMsg) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pb_discovery_proto_msgTypes[3]
+func (x *SnapshotMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -195,43 +978,54 @@ func (x *ServiceAddressMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServiceAddressMsg.ProtoReflect.Descriptor instead.
-func (*ServiceAddressMsg) Descriptor() ([]byte, []int) {
-	return file_api_pb_discovery_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use SnapshotMsg.ProtoReflect.Descriptor instead.
+func (*SnapshotMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *ServiceAddressMsg) GetAddress() string {
+func (x *SnapshotMsg) GetFormat() string {
 	if x != nil {
-		return x.Address
+		return x.Format
 	}
 	return ""
 }
 
-type ListAddrsMsg struct {
+func (x *SnapshotMsg) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ImportSnapshotMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Address []string `protobuf:"bytes,1,rep,name=address,proto3" json:"address,omitempty"`
+	// "json" or "yaml", defaults to "json"
+	Format string `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// Deregisters instances missing from the snapshot
+	Replace bool `protobuf:"varint,3,opt,name=replace,proto3" json:"replace,omitempty"`
 }
 
-func (x *ListAddrsMsg) Reset() {
-	*x = ListAddrsMsg{}
+func (x *ImportSnapshotMsg) Reset() {
+	*x = ImportSnapshotMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_pb_discovery_proto_msgTypes[4]
+		mi := &file_api_pb_discovery_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ListAddrsMsg) String() string {
+func (x *ImportSnapshotMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListAddrsMsg) ProtoMessage() {}
+func (*ImportSnapshotMsg) ProtoMessage() {}
 
-func (x *ListAddrsMsg) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pb_discovery_proto_msgTypes[4]
+func (x *ImportSnapshotMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -242,43 +1036,57 @@ func (x *ListAddrsMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListAddrsMsg.ProtoReflect.Descriptor instead.
-func (*ListAddrsMsg) Descriptor() ([]byte, []int) {
-	return file_api_pb_discovery_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use ImportSnapshotMsg.ProtoReflect.Descriptor instead.
+func (*ImportSnapshotMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{13}
 }
 
-func (x *ListAddrsMsg) GetAddress() []string {
+func (x *ImportSnapshotMsg) GetFormat() string {
 	if x != nil {
-		return x.Address
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImportSnapshotMsg) GetData() []byte {
+	if x != nil {
+		return x.Data
 	}
 	return nil
 }
 
-type ListNamesMsg struct {
+func (x *ImportSnapshotMsg) GetReplace() bool {
+	if x != nil {
+		return x.Replace
+	}
+	return false
+}
+
+type ImportResultMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name []string `protobuf:"bytes,1,rep,name=name,proto3" json:"name,omitempty"`
+	Imported int32 `protobuf:"varint,1,opt,name=imported,proto3" json:"imported,omitempty"`
 }
 
-func (x *ListNamesMsg) Reset() {
-	*x = ListNamesMsg{}
+func (x *ImportResultMsg) Reset() {
+	*x = ImportResultMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_pb_discovery_proto_msgTypes[5]
+		mi := &file_api_pb_discovery_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ListNamesMsg) String() string {
+func (x *ImportResultMsg) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ListNamesMsg) ProtoMessage() {}
+func (*ImportResultMsg) ProtoMessage() {}
 
-func (x *ListNamesMsg) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pb_discovery_proto_msgTypes[5]
+func (x *ImportResultMsg) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pb_discovery_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -289,16 +1097,16 @@ func (x *ListNamesMsg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ListNamesMsg.ProtoReflect.Descriptor instead.
-func (*ListNamesMsg) Descriptor() ([]byte, []int) {
-	return file_api_pb_discovery_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use ImportResultMsg.ProtoReflect.Descriptor instead.
+func (*ImportResultMsg) Descriptor() ([]byte, []int) {
+	return file_api_pb_discovery_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *ListNamesMsg) GetName() []string {
+func (x *ImportResultMsg) GetImported() int32 {
 	if x != nil {
-		return x.Name
+		return x.Imported
 	}
-	return nil
+	return 0
 }
 
 var File_api_pb_discovery_proto protoreflect.FileDescriptor
@@ -306,52 +1114,210 @@ var File_api_pb_discovery_proto protoreflect.FileDescriptor
 var file_api_pb_discovery_proto_rawDesc = []byte{
 	0x0a, 0x16, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x62, 0x2f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65,
 	0x72, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x11, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
-	0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x22, 0x07, 0x0a, 0x05, 0x45,
-	0x6d, 0x70, 0x74, 0x79, 0x22, 0x41, 0x0a, 0x11, 0x4e, 0x61, 0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41,
-	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a,
-	0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
-	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x24, 0x0a, 0x0e, 0x53, 0x65, 0x72, 0x76, 0x69,
-	0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x2d, 0x0a,
-	0x11, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d,
-	0x73, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x28, 0x0a, 0x0c,
-	0x4c, 0x69, 0x73, 0x74, 0x41, 0x64, 0x64, 0x72, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x18, 0x0a, 0x07,
-	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x61,
-	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x22, 0x0a, 0x0c, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x61,
-	0x6d, 0x65, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01,
-	0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x32, 0xa0, 0x03, 0x0a, 0x10, 0x53,
-	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x12,
-	0x4a, 0x0a, 0x08, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x24, 0x2e, 0x73, 0x65,
-	0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e,
-	0x4e, 0x61, 0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73,
-	0x67, 0x1a, 0x18, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63,
-	0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x4c, 0x0a, 0x0a, 0x44,
-	0x65, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x72, 0x76,
+	0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x1a, 0x1f, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x07, 0x0a, 0x05,
+	0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0xd9, 0x02, 0x0a, 0x11, 0x4e, 0x61, 0x6d, 0x65, 0x41, 0x6e,
+	0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x4e, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x32, 0x2e, 0x73, 0x65, 0x72, 0x76,
 	0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4e, 0x61,
-	0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x1a,
+	0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x2e,
+	0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68,
+	0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12,
+	0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x74, 0x74,
+	0x6c, 0x12, 0x31, 0x0a, 0x05, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1b, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f,
+	0x76, 0x65, 0x72, 0x79, 0x2e, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x4d, 0x73, 0x67, 0x52, 0x05, 0x63,
+	0x68, 0x65, 0x63, 0x6b, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0xa7, 0x01, 0x0a, 0x08, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x4d, 0x73, 0x67, 0x12, 0x12,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x05, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x12, 0x18,
+	0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x67, 0x72, 0x70, 0x63,
+	0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x67, 0x72, 0x70, 0x63, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x22, 0xa0, 0x03, 0x0a, 0x0b,
+	0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x48, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x49, 0x6e,
+	0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x4d, 0x73, 0x67, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x6c,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x74, 0x74, 0x6c, 0x12, 0x31, 0x0a, 0x05, 0x63,
+	0x68, 0x65, 0x63, 0x6b, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x43,
+	0x68, 0x65, 0x63, 0x6b, 0x4d, 0x73, 0x67, 0x52, 0x05, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x12, 0x1b,
+	0x0a, 0x09, 0x69, 0x73, 0x5f, 0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x08, 0x69, 0x73, 0x41, 0x63, 0x74, 0x69, 0x76, 0x65, 0x12, 0x34, 0x0a, 0x06, 0x68,
+	0x65, 0x61, 0x6c, 0x74, 0x68, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e,
+	0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x4d, 0x73, 0x67, 0x52, 0x06, 0x68, 0x65, 0x61, 0x6c, 0x74,
+	0x68, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xe9,
+	0x02, 0x0a, 0x09, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x4d, 0x73, 0x67, 0x12, 0x14, 0x0a, 0x05,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x75,
+	0x63, 0x63, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x73,
+	0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x61, 0x69, 0x6c,
+	0x75, 0x72, 0x65, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x66, 0x61, 0x69, 0x6c,
+	0x75, 0x72, 0x65, 0x73, 0x12, 0x30, 0x0a, 0x05, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x05, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x12, 0x47, 0x0a, 0x11, 0x71, 0x75, 0x61, 0x72, 0x61, 0x6e,
+	0x74, 0x69, 0x6e, 0x65, 0x64, 0x5f, 0x75, 0x6e, 0x74, 0x69, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x10, 0x71,
+	0x75, 0x61, 0x72, 0x61, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x64, 0x55, 0x6e, 0x74, 0x69, 0x6c, 0x12,
+	0x39, 0x0a, 0x0a, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52,
+	0x09, 0x6c, 0x61, 0x73, 0x74, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x12, 0x1f, 0x0a, 0x0b, 0x6c, 0x61,
+	0x73, 0x74, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x0a, 0x6c, 0x61, 0x73, 0x74, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x6c,
+	0x61, 0x73, 0x74, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x6c, 0x61, 0x73, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x51, 0x0a, 0x09, 0x57, 0x65,
+	0x69, 0x67, 0x68, 0x74, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x6f, 0x0a,
+	0x0e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x12,
+	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x74,
+	0x61, 0x67, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x68, 0x61, 0x73, 0x68, 0x5f, 0x6b, 0x65, 0x79, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x68, 0x61, 0x73, 0x68, 0x4b, 0x65, 0x79, 0x22, 0x2d,
+	0x0a, 0x11, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73,
+	0x4d, 0x73, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x28, 0x0a,
+	0x0c, 0x4c, 0x69, 0x73, 0x74, 0x41, 0x64, 0x64, 0x72, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x18, 0x0a,
+	0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07,
+	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x22, 0x0a, 0x0c, 0x4c, 0x69, 0x73, 0x74, 0x4e,
+	0x61, 0x6d, 0x65, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xab, 0x01, 0x0a, 0x0f,
+	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4d, 0x73, 0x67, 0x12,
+	0x30, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72,
+	0x79, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12,
+	0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x22, 0x2b, 0x0a, 0x11, 0x45, 0x78, 0x70,
+	0x6f, 0x72, 0x74, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x4d, 0x73, 0x67, 0x12, 0x16,
+	0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0x39, 0x0a, 0x0b, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68,
+	0x6f, 0x74, 0x4d, 0x73, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74,
+	0x61, 0x22, 0x59, 0x0a, 0x11, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x6e, 0x61, 0x70, 0x73,
+	0x68, 0x6f, 0x74, 0x4d, 0x73, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
+	0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x22, 0x2d, 0x0a, 0x0f,
+	0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x4d, 0x73, 0x67, 0x12,
+	0x1a, 0x0a, 0x08, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x08, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x2a, 0x5e, 0x0a, 0x09, 0x45,
+	0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0f, 0x0a, 0x0b, 0x55, 0x4e, 0x53, 0x50,
+	0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0e, 0x0a, 0x0a, 0x52, 0x45, 0x47,
+	0x49, 0x53, 0x54, 0x45, 0x52, 0x45, 0x44, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x44, 0x45, 0x52,
+	0x45, 0x47, 0x49, 0x53, 0x54, 0x45, 0x52, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x41,
+	0x43, 0x54, 0x49, 0x56, 0x41, 0x54, 0x45, 0x44, 0x10, 0x03, 0x12, 0x0f, 0x0a, 0x0b, 0x44, 0x45,
+	0x41, 0x43, 0x54, 0x49, 0x56, 0x41, 0x54, 0x45, 0x44, 0x10, 0x04, 0x32, 0xe9, 0x07, 0x0a, 0x10,
+	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79,
+	0x12, 0x4a, 0x0a, 0x08, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x24, 0x2e, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79,
+	0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d,
+	0x73, 0x67, 0x1a, 0x18, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73,
+	0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x4c, 0x0a, 0x0a,
+	0x44, 0x65, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4e,
+	0x61, 0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67,
+	0x1a, 0x18, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f,
+	0x76, 0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x4b, 0x0a, 0x09, 0x48, 0x65,
+	0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4e, 0x61, 0x6d, 0x65,
+	0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x1a, 0x18, 0x2e,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72,
+	0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x56, 0x0a, 0x0b, 0x46, 0x69, 0x6e, 0x64, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x21, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x1a, 0x24, 0x2e, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x12,
+	0x49, 0x0a, 0x0c, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x12,
 	0x18, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76,
-	0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x56, 0x0a, 0x0b, 0x46, 0x69, 0x6e,
-	0x64, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x21, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69,
-	0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x53, 0x65, 0x72,
-	0x76, 0x69, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x1a, 0x24, 0x2e, 0x73, 0x65,
+	0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x1f, 0x2e, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4c, 0x69,
+	0x73, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x4f, 0x0a, 0x09, 0x4c, 0x69,
+	0x73, 0x74, 0x41, 0x64, 0x64, 0x72, 0x73, 0x12, 0x21, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x1a, 0x1f, 0x2e, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4c,
+	0x69, 0x73, 0x74, 0x41, 0x64, 0x64, 0x72, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x53, 0x0a, 0x0b, 0x47,
+	0x65, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4e,
+	0x61, 0x6d, 0x65, 0x41, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67,
+	0x1a, 0x1e, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f,
+	0x76, 0x65, 0x72, 0x79, 0x2e, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x4d, 0x73, 0x67,
+	0x12, 0x57, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x48,
+	0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f,
+	0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x41, 0x6e,
+	0x64, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73, 0x67, 0x1a, 0x1c, 0x2e, 0x73, 0x65,
 	0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e,
-	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x73,
-	0x67, 0x12, 0x49, 0x0a, 0x0c, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
-	0x73, 0x12, 0x18, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63,
-	0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x1f, 0x2e, 0x73, 0x65,
+	0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x4d, 0x73, 0x67, 0x12, 0x46, 0x0a, 0x0c, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x1c, 0x2e, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x57, 0x65,
+	0x69, 0x67, 0x68, 0x74, 0x4d, 0x73, 0x67, 0x1a, 0x18, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x45, 0x6d, 0x70, 0x74,
+	0x79, 0x12, 0x50, 0x0a, 0x05, 0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x21, 0x2e, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x1a, 0x22, 0x2e,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72,
+	0x79, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4d, 0x73,
+	0x67, 0x30, 0x01, 0x12, 0x56, 0x0a, 0x0e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f,
+	0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74,
+	0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x4d, 0x73, 0x67, 0x1a, 0x1e, 0x2e, 0x73, 0x65,
 	0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e,
-	0x4c, 0x69, 0x73, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x4d, 0x73, 0x67, 0x12, 0x4f, 0x0a, 0x09,
-	0x4c, 0x69, 0x73, 0x74, 0x41, 0x64, 0x64, 0x72, 0x73, 0x12, 0x21, 0x2e, 0x73, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x53, 0x65,
-	0x72, 0x76, 0x69, 0x63, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x4d, 0x73, 0x67, 0x1a, 0x1f, 0x2e, 0x73,
-	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79,
-	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x41, 0x64, 0x64, 0x72, 0x73, 0x4d, 0x73, 0x67, 0x42, 0x33, 0x5a,
-	0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x4a, 0x4d, 0x55, 0x52,
-	0x76, 0x2f, 0x70, 0x61, 0x72, 0x2d, 0x70, 0x72, 0x6f, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x62,
-	0x2f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2d, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65,
-	0x72, 0x79, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x4d, 0x73, 0x67, 0x12, 0x5a, 0x0a, 0x0e, 0x49,
+	0x6d, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x24, 0x2e,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72,
+	0x79, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74,
+	0x4d, 0x73, 0x67, 0x1a, 0x22, 0x2e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x64, 0x69,
+	0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x4d, 0x73, 0x67, 0x42, 0x33, 0x5a, 0x31, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x4a, 0x4d, 0x55, 0x52, 0x76, 0x2f, 0x70, 0x61, 0x72, 0x2d,
+	0x70, 0x72, 0x6f, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x62, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x2d, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x76, 0x65, 0x72, 0x79, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -366,31 +1332,69 @@ func file_api_pb_discovery_proto_rawDescGZIP() []byte {
 	return file_api_pb_discovery_proto_rawDescData
 }
 
-var file_api_pb_discovery_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_api_pb_discovery_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_pb_discovery_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_api_pb_discovery_proto_goTypes = []any{
-	(*Empty)(nil),             // 0: service_discovery.Empty
-	(*NameAndAddressMsg)(nil), // 1: service_discovery.NameAndAddressMsg
-	(*ServiceNameMsg)(nil),    // 2: service_discovery.ServiceNameMsg
-	(*ServiceAddressMsg)(nil), // 3: service_discovery.ServiceAddressMsg
-	(*ListAddrsMsg)(nil),      // 4: service_discovery.ListAddrsMsg
-	(*ListNamesMsg)(nil),      // 5: service_discovery.ListNamesMsg
+	(EventType)(0),                // 0: service_discovery.EventType
+	(*Empty)(nil),                 // 1: service_discovery.Empty
+	(*NameAndAddressMsg)(nil),     // 2: service_discovery.NameAndAddressMsg
+	(*CheckMsg)(nil),              // 3: service_discovery.CheckMsg
+	(*InstanceMsg)(nil),           // 4: service_discovery.InstanceMsg
+	(*HealthMsg)(nil),             // 5: service_discovery.HealthMsg
+	(*WeightMsg)(nil),             // 6: service_discovery.WeightMsg
+	(*ServiceNameMsg)(nil),        // 7: service_discovery.ServiceNameMsg
+	(*ServiceAddressMsg)(nil),     // 8: service_discovery.ServiceAddressMsg
+	(*ListAddrsMsg)(nil),          // 9: service_discovery.ListAddrsMsg
+	(*ListNamesMsg)(nil),          // 10: service_discovery.ListNamesMsg
+	(*ServiceEventMsg)(nil),       // 11: service_discovery.ServiceEventMsg
+	(*ExportSnapshotMsg)(nil),     // 12: service_discovery.ExportSnapshotMsg
+	(*SnapshotMsg)(nil),           // 13: service_discovery.SnapshotMsg
+	(*ImportSnapshotMsg)(nil),     // 14: service_discovery.ImportSnapshotMsg
+	(*ImportResultMsg)(nil),       // 15: service_discovery.ImportResultMsg
+	nil,                           // 16: service_discovery.NameAndAddressMsg.MetadataEntry
+	nil,                           // 17: service_discovery.InstanceMsg.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
 }
 var file_api_pb_discovery_proto_depIdxs = []int32{
-	1, // 0: service_discovery.ServiceDiscovery.Register:input_type -> service_discovery.NameAndAddressMsg
-	1, // 1: service_discovery.ServiceDiscovery.Deregister:input_type -> service_discovery.NameAndAddressMsg
-	2, // 2: service_discovery.ServiceDiscovery.FindService:input_type -> service_discovery.ServiceNameMsg
-	0, // 3: service_discovery.ServiceDiscovery.ListServices:input_type -> service_discovery.Empty
-	2, // 4: service_discovery.ServiceDiscovery.ListAddrs:input_type -> service_discovery.ServiceNameMsg
-	0, // 5: service_discovery.ServiceDiscovery.Register:output_type -> service_discovery.Empty
-	0, // 6: service_discovery.ServiceDiscovery.Deregister:output_type -> service_discovery.Empty
-	3, // 7: service_discovery.ServiceDiscovery.FindService:output_type -> service_discovery.ServiceAddressMsg
-	5, // 8: service_discovery.ServiceDiscovery.ListServices:output_type -> service_discovery.ListNamesMsg
-	4, // 9: service_discovery.ServiceDiscovery.ListAddrs:output_type -> service_discovery.ListAddrsMsg
-	5, // [5:10] is the sub-list for method output_type
-	0, // [0:5] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	16, // 0: service_discovery.NameAndAddressMsg.metadata:type_name -> service_discovery.NameAndAddressMsg.MetadataEntry
+	3,  // 1: service_discovery.NameAndAddressMsg.check:type_name -> service_discovery.CheckMsg
+	17, // 2: service_discovery.InstanceMsg.metadata:type_name -> service_discovery.InstanceMsg.MetadataEntry
+	3,  // 3: service_discovery.InstanceMsg.check:type_name -> service_discovery.CheckMsg
+	5,  // 4: service_discovery.InstanceMsg.health:type_name -> service_discovery.HealthMsg
+	18, // 5: service_discovery.HealthMsg.since:type_name -> google.protobuf.Timestamp
+	18, // 6: service_discovery.HealthMsg.quarantined_until:type_name -> google.protobuf.Timestamp
+	18, // 7: service_discovery.HealthMsg.last_check:type_name -> google.protobuf.Timestamp
+	0,  // 8: service_discovery.ServiceEventMsg.type:type_name -> service_discovery.EventType
+	18, // 9: service_discovery.ServiceEventMsg.timestamp:type_name -> google.protobuf.Timestamp
+	2,  // 10: service_discovery.ServiceDiscovery.Register:input_type -> service_discovery.NameAndAddressMsg
+	2,  // 11: service_discovery.ServiceDiscovery.Deregister:input_type -> service_discovery.NameAndAddressMsg
+	2,  // 12: service_discovery.ServiceDiscovery.Heartbeat:input_type -> service_discovery.NameAndAddressMsg
+	7,  // 13: service_discovery.ServiceDiscovery.FindService:input_type -> service_discovery.ServiceNameMsg
+	1,  // 14: service_discovery.ServiceDiscovery.ListServices:input_type -> service_discovery.Empty
+	7,  // 15: service_discovery.ServiceDiscovery.ListAddrs:input_type -> service_discovery.ServiceNameMsg
+	2,  // 16: service_discovery.ServiceDiscovery.GetInstance:input_type -> service_discovery.NameAndAddressMsg
+	2,  // 17: service_discovery.ServiceDiscovery.GetInstanceHealth:input_type -> service_discovery.NameAndAddressMsg
+	6,  // 18: service_discovery.ServiceDiscovery.UpdateWeight:input_type -> service_discovery.WeightMsg
+	7,  // 19: service_discovery.ServiceDiscovery.Watch:input_type -> service_discovery.ServiceNameMsg
+	12, // 20: service_discovery.ServiceDiscovery.ExportSnapshot:input_type -> service_discovery.ExportSnapshotMsg
+	14, // 21: service_discovery.ServiceDiscovery.ImportSnapshot:input_type -> service_discovery.ImportSnapshotMsg
+	1,  // 22: service_discovery.ServiceDiscovery.Register:output_type -> service_discovery.Empty
+	1,  // 23: service_discovery.ServiceDiscovery.Deregister:output_type -> service_discovery.Empty
+	1,  // 24: service_discovery.ServiceDiscovery.Heartbeat:output_type -> service_discovery.Empty
+	8,  // 25: service_discovery.ServiceDiscovery.FindService:output_type -> service_discovery.ServiceAddressMsg
+	10, // 26: service_discovery.ServiceDiscovery.ListServices:output_type -> service_discovery.ListNamesMsg
+	9,  // 27: service_discovery.ServiceDiscovery.ListAddrs:output_type -> service_discovery.ListAddrsMsg
+	4,  // 28: service_discovery.ServiceDiscovery.GetInstance:output_type -> service_discovery.InstanceMsg
+	5,  // 29: service_discovery.ServiceDiscovery.GetInstanceHealth:output_type -> service_discovery.HealthMsg
+	1,  // 30: service_discovery.ServiceDiscovery.UpdateWeight:output_type -> service_discovery.Empty
+	11, // 31: service_discovery.ServiceDiscovery.Watch:output_type -> service_discovery.ServiceEventMsg
+	13, // 32: service_discovery.ServiceDiscovery.ExportSnapshot:output_type -> service_discovery.SnapshotMsg
+	15, // 33: service_discovery.ServiceDiscovery.ImportSnapshot:output_type -> service_discovery.ImportResultMsg
+	22, // [22:34] is the sub-list for method output_type
+	10, // [10:22] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_api_pb_discovery_proto_init() }
@@ -424,7 +1428,7 @@ func file_api_pb_discovery_proto_init() {
 			}
 		}
 		file_api_pb_discovery_proto_msgTypes[2].Exporter = func(v any, i int) any {
-			switch v := v.(*ServiceNameMsg); i {
+			switch v := v.(*CheckMsg); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -436,7 +1440,7 @@ func file_api_pb_discovery_proto_init() {
 			}
 		}
 		file_api_pb_discovery_proto_msgTypes[3].Exporter = func(v any, i int) any {
-			switch v := v.(*ServiceAddressMsg); i {
+			switch v := v.(*InstanceMsg); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -448,7 +1452,7 @@ func file_api_pb_discovery_proto_init() {
 			}
 		}
 		file_api_pb_discovery_proto_msgTypes[4].Exporter = func(v any, i int) any {
-			switch v := v.(*ListAddrsMsg); i {
+			switch v := v.(*HealthMsg); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -460,6 +1464,54 @@ func file_api_pb_discovery_proto_init() {
 			}
 		}
 		file_api_pb_discovery_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*WeightMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*ServiceNameMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*ServiceAddressMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*ListAddrsMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[9].Exporter = func(v any, i int) any {
 			switch v := v.(*ListNamesMsg); i {
 			case 0:
 				return &v.state
@@ -471,19 +1523,80 @@ func file_api_pb_discovery_proto_init() {
 				return nil
 			}
 		}
+		file_api_pb_discovery_proto_msgTypes[10].Exporter = func(v any, i int) any {
+			switch v := v.(*ServiceEventMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[11].Exporter = func(v any, i int) any {
+			switch v := v.(*ExportSnapshotMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*SnapshotMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[13].Exporter = func(v any, i int) any {
+			switch v := v.(*ImportSnapshotMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_pb_discovery_proto_msgTypes[14].Exporter = func(v any, i int) any {
+			switch v := v.(*ImportResultMsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_api_pb_discovery_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      1,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_api_pb_discovery_proto_goTypes,
 		DependencyIndexes: file_api_pb_discovery_proto_depIdxs,
+		EnumInfos:         file_api_pb_discovery_proto_enumTypes,
 		MessageInfos:      file_api_pb_discovery_proto_msgTypes,
 	}.Build()
 	File_api_pb_discovery_proto = out.File
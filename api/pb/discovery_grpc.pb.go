@@ -19,11 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ServiceDiscovery_Register_FullMethodName     = "/service_discovery.ServiceDiscovery/Register"
-	ServiceDiscovery_Deregister_FullMethodName   = "/service_discovery.ServiceDiscovery/Deregister"
-	ServiceDiscovery_FindService_FullMethodName  = "/service_discovery.ServiceDiscovery/FindService"
-	ServiceDiscovery_ListServices_FullMethodName = "/service_discovery.ServiceDiscovery/ListServices"
-	ServiceDiscovery_ListAddrs_FullMethodName    = "/service_discovery.ServiceDiscovery/ListAddrs"
+	ServiceDiscovery_Register_FullMethodName          = "/service_discovery.ServiceDiscovery/Register"
+	ServiceDiscovery_Deregister_FullMethodName        = "/service_discovery.ServiceDiscovery/Deregister"
+	ServiceDiscovery_Heartbeat_FullMethodName         = "/service_discovery.ServiceDiscovery/Heartbeat"
+	ServiceDiscovery_FindService_FullMethodName       = "/service_discovery.ServiceDiscovery/FindService"
+	ServiceDiscovery_ListServices_FullMethodName      = "/service_discovery.ServiceDiscovery/ListServices"
+	ServiceDiscovery_ListAddrs_FullMethodName         = "/service_discovery.ServiceDiscovery/ListAddrs"
+	ServiceDiscovery_GetInstance_FullMethodName       = "/service_discovery.ServiceDiscovery/GetInstance"
+	ServiceDiscovery_GetInstanceHealth_FullMethodName = "/service_discovery.ServiceDiscovery/GetInstanceHealth"
+	ServiceDiscovery_UpdateWeight_FullMethodName      = "/service_discovery.ServiceDiscovery/UpdateWeight"
+	ServiceDiscovery_Watch_FullMethodName             = "/service_discovery.ServiceDiscovery/Watch"
+	ServiceDiscovery_ExportSnapshot_FullMethodName    = "/service_discovery.ServiceDiscovery/ExportSnapshot"
+	ServiceDiscovery_ImportSnapshot_FullMethodName    = "/service_discovery.ServiceDiscovery/ImportSnapshot"
 )
 
 // ServiceDiscoveryClient is the client API for ServiceDiscovery service.
@@ -32,9 +39,23 @@ const (
 type ServiceDiscoveryClient interface {
 	Register(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*Empty, error)
 	Deregister(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*Empty, error)
+	// Renews the lease of an instance registered with a ttl
+	Heartbeat(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*Empty, error)
 	FindService(ctx context.Context, in *ServiceNameMsg, opts ...grpc.CallOption) (*ServiceAddressMsg, error)
 	ListServices(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ListNamesMsg, error)
 	ListAddrs(ctx context.Context, in *ServiceNameMsg, opts ...grpc.CallOption) (*ListAddrsMsg, error)
+	// Returns an instance along with its health state
+	GetInstance(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*InstanceMsg, error)
+	// Returns the outcome of the latest health checks of an instance
+	GetInstanceHealth(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*HealthMsg, error)
+	// Zero weight drains the instance without deregistering it
+	UpdateWeight(ctx context.Context, in *WeightMsg, opts ...grpc.CallOption) (*Empty, error)
+	// Empty name watches every service
+	Watch(ctx context.Context, in *ServiceNameMsg, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ServiceEventMsg], error)
+	// Dumps the whole registry, including inactive instances
+	ExportSnapshot(ctx context.Context, in *ExportSnapshotMsg, opts ...grpc.CallOption) (*SnapshotMsg, error)
+	// Restores a dump produced by ExportSnapshot or written by hand
+	ImportSnapshot(ctx context.Context, in *ImportSnapshotMsg, opts ...grpc.CallOption) (*ImportResultMsg, error)
 }
 
 type serviceDiscoveryClient struct {
@@ -65,6 +86,16 @@ func (c *serviceDiscoveryClient) Deregister(ctx context.Context, in *NameAndAddr
 	return out, nil
 }
 
+func (c *serviceDiscoveryClient) Heartbeat(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, ServiceDiscovery_Heartbeat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *serviceDiscoveryClient) FindService(ctx context.Context, in *ServiceNameMsg, opts ...grpc.CallOption) (*ServiceAddressMsg, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ServiceAddressMsg)
@@ -95,15 +126,98 @@ func (c *serviceDiscoveryClient) ListAddrs(ctx context.Context, in *ServiceNameM
 	return out, nil
 }
 
+func (c *serviceDiscoveryClient) GetInstance(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*InstanceMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InstanceMsg)
+	err := c.cc.Invoke(ctx, ServiceDiscovery_GetInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceDiscoveryClient) GetInstanceHealth(ctx context.Context, in *NameAndAddressMsg, opts ...grpc.CallOption) (*HealthMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthMsg)
+	err := c.cc.Invoke(ctx, ServiceDiscovery_GetInstanceHealth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceDiscoveryClient) UpdateWeight(ctx context.Context, in *WeightMsg, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, ServiceDiscovery_UpdateWeight_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceDiscoveryClient) Watch(ctx context.Context, in *ServiceNameMsg, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ServiceEventMsg], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ServiceDiscovery_ServiceDesc.Streams[0], ServiceDiscovery_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ServiceNameMsg, ServiceEventMsg]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ServiceDiscovery_WatchClient = grpc.ServerStreamingClient[ServiceEventMsg]
+
+func (c *serviceDiscoveryClient) ExportSnapshot(ctx context.Context, in *ExportSnapshotMsg, opts ...grpc.CallOption) (*SnapshotMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotMsg)
+	err := c.cc.Invoke(ctx, ServiceDiscovery_ExportSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceDiscoveryClient) ImportSnapshot(ctx context.Context, in *ImportSnapshotMsg, opts ...grpc.CallOption) (*ImportResultMsg, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportResultMsg)
+	err := c.cc.Invoke(ctx, ServiceDiscovery_ImportSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ServiceDiscoveryServer is the server API for ServiceDiscovery service.
 // All implementations must embed UnimplementedServiceDiscoveryServer
 // for forward compatibility.
 type ServiceDiscoveryServer interface {
 	Register(context.Context, *NameAndAddressMsg) (*Empty, error)
 	Deregister(context.Context, *NameAndAddressMsg) (*Empty, error)
+	// Renews the lease of an instance registered with a ttl
+	Heartbeat(context.Context, *NameAndAddressMsg) (*Empty, error)
 	FindService(context.Context, *ServiceNameMsg) (*ServiceAddressMsg, error)
 	ListServices(context.Context, *Empty) (*ListNamesMsg, error)
 	ListAddrs(context.Context, *ServiceNameMsg) (*ListAddrsMsg, error)
+	// Returns an instance along with its health state
+	GetInstance(context.Context, *NameAndAddressMsg) (*InstanceMsg, error)
+	// Returns the outcome of the latest health checks of an instance
+	GetInstanceHealth(context.Context, *NameAndAddressMsg) (*HealthMsg, error)
+	// Zero weight drains the instance without deregistering it
+	UpdateWeight(context.Context, *WeightMsg) (*Empty, error)
+	// Empty name watches every service
+	Watch(*ServiceNameMsg, grpc.ServerStreamingServer[ServiceEventMsg]) error
+	// Dumps the whole registry, including inactive instances
+	ExportSnapshot(context.Context, *ExportSnapshotMsg) (*SnapshotMsg, error)
+	// Restores a dump produced by ExportSnapshot or written by hand
+	ImportSnapshot(context.Context, *ImportSnapshotMsg) (*ImportResultMsg, error)
 	mustEmbedUnimplementedServiceDiscoveryServer()
 }
 
@@ -120,6 +234,9 @@ func (UnimplementedServiceDiscoveryServer) Register(context.Context, *NameAndAdd
 func (UnimplementedServiceDiscoveryServer) Deregister(context.Context, *NameAndAddressMsg) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Deregister not implemented")
 }
+func (UnimplementedServiceDiscoveryServer) Heartbeat(context.Context, *NameAndAddressMsg) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
 func (UnimplementedServiceDiscoveryServer) FindService(context.Context, *ServiceNameMsg) (*ServiceAddressMsg, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindService not implemented")
 }
@@ -129,6 +246,24 @@ func (UnimplementedServiceDiscoveryServer) ListServices(context.Context, *Empty)
 func (UnimplementedServiceDiscoveryServer) ListAddrs(context.Context, *ServiceNameMsg) (*ListAddrsMsg, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListAddrs not implemented")
 }
+func (UnimplementedServiceDiscoveryServer) GetInstance(context.Context, *NameAndAddressMsg) (*InstanceMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInstance not implemented")
+}
+func (UnimplementedServiceDiscoveryServer) GetInstanceHealth(context.Context, *NameAndAddressMsg) (*HealthMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInstanceHealth not implemented")
+}
+func (UnimplementedServiceDiscoveryServer) UpdateWeight(context.Context, *WeightMsg) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateWeight not implemented")
+}
+func (UnimplementedServiceDiscoveryServer) Watch(*ServiceNameMsg, grpc.ServerStreamingServer[ServiceEventMsg]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedServiceDiscoveryServer) ExportSnapshot(context.Context, *ExportSnapshotMsg) (*SnapshotMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportSnapshot not implemented")
+}
+func (UnimplementedServiceDiscoveryServer) ImportSnapshot(context.Context, *ImportSnapshotMsg) (*ImportResultMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportSnapshot not implemented")
+}
 func (UnimplementedServiceDiscoveryServer) mustEmbedUnimplementedServiceDiscoveryServer() {}
 func (UnimplementedServiceDiscoveryServer) testEmbeddedByValue()                          {}
 
@@ -186,6 +321,24 @@ func _ServiceDiscovery_Deregister_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ServiceDiscovery_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NameAndAddressMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceDiscoveryServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceDiscovery_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceDiscoveryServer).Heartbeat(ctx, req.(*NameAndAddressMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ServiceDiscovery_FindService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ServiceNameMsg)
 	if err := dec(in); err != nil {
@@ -240,6 +393,107 @@ func _ServiceDiscovery_ListAddrs_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ServiceDiscovery_GetInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NameAndAddressMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceDiscoveryServer).GetInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceDiscovery_GetInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceDiscoveryServer).GetInstance(ctx, req.(*NameAndAddressMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceDiscovery_GetInstanceHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NameAndAddressMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceDiscoveryServer).GetInstanceHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceDiscovery_GetInstanceHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceDiscoveryServer).GetInstanceHealth(ctx, req.(*NameAndAddressMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceDiscovery_UpdateWeight_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WeightMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceDiscoveryServer).UpdateWeight(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceDiscovery_UpdateWeight_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceDiscoveryServer).UpdateWeight(ctx, req.(*WeightMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceDiscovery_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ServiceNameMsg)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceDiscoveryServer).Watch(m, &grpc.GenericServerStream[ServiceNameMsg, ServiceEventMsg]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ServiceDiscovery_WatchServer = grpc.ServerStreamingServer[ServiceEventMsg]
+
+func _ServiceDiscovery_ExportSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportSnapshotMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceDiscoveryServer).ExportSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceDiscovery_ExportSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceDiscoveryServer).ExportSnapshot(ctx, req.(*ExportSnapshotMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceDiscovery_ImportSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportSnapshotMsg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceDiscoveryServer).ImportSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceDiscovery_ImportSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceDiscoveryServer).ImportSnapshot(ctx, req.(*ImportSnapshotMsg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ServiceDiscovery_ServiceDesc is the grpc.ServiceDesc for ServiceDiscovery service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -255,6 +509,10 @@ var ServiceDiscovery_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Deregister",
 			Handler:    _ServiceDiscovery_Deregister_Handler,
 		},
+		{
+			MethodName: "Heartbeat",
+			Handler:    _ServiceDiscovery_Heartbeat_Handler,
+		},
 		{
 			MethodName: "FindService",
 			Handler:    _ServiceDiscovery_FindService_Handler,
@@ -267,7 +525,33 @@ var ServiceDiscovery_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListAddrs",
 			Handler:    _ServiceDiscovery_ListAddrs_Handler,
 		},
+		{
+			MethodName: "GetInstance",
+			Handler:    _ServiceDiscovery_GetInstance_Handler,
+		},
+		{
+			MethodName: "GetInstanceHealth",
+			Handler:    _ServiceDiscovery_GetInstanceHealth_Handler,
+		},
+		{
+			MethodName: "UpdateWeight",
+			Handler:    _ServiceDiscovery_UpdateWeight_Handler,
+		},
+		{
+			MethodName: "ExportSnapshot",
+			Handler:    _ServiceDiscovery_ExportSnapshot_Handler,
+		},
+		{
+			MethodName: "ImportSnapshot",
+			Handler:    _ServiceDiscovery_ImportSnapshot_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _ServiceDiscovery_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/pb/discovery.proto",
 }
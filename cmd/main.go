@@ -2,23 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/broker"
 	"github.com/JMURv/service-discovery/internal/checker"
+	"github.com/JMURv/service-discovery/internal/cluster"
 	"github.com/JMURv/service-discovery/internal/ctrl"
 	"github.com/JMURv/service-discovery/internal/hdl"
+	"github.com/JMURv/service-discovery/internal/hdl/dns"
 	"github.com/JMURv/service-discovery/internal/hdl/grpc"
 	"github.com/JMURv/service-discovery/internal/hdl/http"
+	"github.com/JMURv/service-discovery/internal/metrics"
 	sqlite "github.com/JMURv/service-discovery/internal/repo/db"
 	mem "github.com/JMURv/service-discovery/internal/repo/memory"
+	"github.com/JMURv/service-discovery/internal/repo/postgres"
+	"github.com/JMURv/service-discovery/internal/repo/traced"
+	"github.com/JMURv/service-discovery/internal/tracing"
 	cfg "github.com/JMURv/service-discovery/pkg/config"
 	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 )
 
-const configPath = "local.config.yaml"
+const (
+	defaultLeaseTTL = 15
+	defaultDNSPort  = 8600
+	flushTimeout    = 5 * time.Second
+)
 
 func mustRegisterLogger(mode string) {
 	switch mode {
@@ -29,6 +46,46 @@ func mustRegisterLogger(mode string) {
 	}
 }
 
+func newRepo(conf *cfg.Config, bal balancer.Balancer, events *broker.Broker) (ctrl.ServiceDiscoveryRepo, error) {
+	// Every node of a cluster keeps the registry in memory, the Raft log persists it
+	if conf.Cluster != nil {
+		return cluster.New(conf.Cluster, mem.New(bal), events)
+	}
+
+	switch conf.DB {
+	case cfg.InMem:
+		return mem.Open(conf.Memory, bal)
+	case cfg.SQLite:
+		return sqlite.New(conf.SQLite, bal)
+	case cfg.Postgres:
+		return postgres.New(conf.Postgres, bal)
+	default:
+		return nil, fmt.Errorf("unsupported repo type in configuration: %v", conf.DB)
+	}
+}
+
+// newElector picks how replicas sharing the registry agree on which one runs the health checks.
+func newElector(conf *cfg.CheckerConfig, repo ctrl.ServiceDiscoveryRepo) checker.Elector {
+	switch r := repo.(type) {
+	case *cluster.Repository:
+		return r
+	case *sqlite.Repository:
+		id := conf.ReplicaID
+		if id == "" {
+			host, _ := os.Hostname()
+			id = fmt.Sprintf("%v-%v", host, os.Getpid())
+		}
+
+		ttl := conf.LeaseTTL
+		if ttl <= 0 {
+			ttl = defaultLeaseTTL
+		}
+		return r.Lease("checker", id, time.Duration(ttl)*time.Second)
+	default:
+		return checker.Standalone{}
+	}
+}
+
 func main() {
 	defer func() {
 		if err := recover(); err != nil {
@@ -37,55 +94,140 @@ func main() {
 		}
 	}()
 
-	conf := cfg.MustLoad(configPath)
+	conf, args, err := cfg.Parse(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, usage)
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 	mustRegisterLogger(conf.Server.Mode)
 
+	if len(args) > 0 {
+		if err := runCommand(conf, args[0], args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	newAddrChan := make(chan md.Service)
 
 	// Setting up main app
 
-	var repo ctrl.ServiceDiscoveryRepo
-	switch conf.DB {
-	case cfg.InMem:
-		repo = mem.New()
-	case cfg.SQLite:
-		repo = sqlite.New()
-	default:
-		zap.L().Fatal("Unsupported repo type in configuration")
+	bal, err := balancer.New(conf.Balancer)
+	if err != nil {
+		zap.L().Fatal("Unsupported balancer in configuration", zap.Error(err))
 	}
 
-	check := checker.New(repo, newAddrChan, conf.Checker, conf.Checker.Req)
-	svc := ctrl.New(repo, newAddrChan)
+	shutdownTracing := func(context.Context) error { return nil }
+	if conf.Tracing != nil {
+		if shutdownTracing, err = tracing.Setup(ctx, conf.Tracing); err != nil {
+			zap.L().Fatal("Failed to set up tracing", zap.Error(err))
+		}
+	}
+
+	events := broker.New()
+	repo, err := newRepo(conf, bal, events)
+	if err != nil {
+		zap.L().Fatal("Failed to open repository", zap.Error(err))
+	}
+
+	prometheus.MustRegister(metrics.NewInstances(repo))
+	check := checker.New(repo, newAddrChan, events, conf.Checker, conf.Checker.Req, newElector(conf.Checker, repo))
+	// Only requests are traced, the checker probes the untraced repository
+	svc := ctrl.New(traced.New(repo, string(conf.DB)), newAddrChan, events)
+
+	type server struct {
+		h    hdl.Handler
+		port int
+	}
 
-	var h hdl.Handler
+	var srvs []server
 	switch conf.AcceptReq {
 	case cfg.HTTP:
-		h = http.New(svc)
+		srvs = append(srvs, server{h: http.New(svc), port: conf.Server.Port})
 	case cfg.GRPC:
-		h = grpc.New(svc)
+		srvs = append(srvs, server{h: grpc.New(svc), port: conf.Server.Port})
+	case cfg.Both:
+		srvs = append(
+			srvs,
+			server{h: grpc.New(svc), port: conf.Server.Port},
+			server{h: http.New(svc), port: conf.Server.HTTPPort},
+		)
 	default:
 		zap.L().Fatal("Unsupported handler type in configuration")
 	}
 
-	// Graceful shutdown
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-		<-c
+	if conf.Server.MetricsPort != 0 {
+		srvs = append(srvs, server{h: metrics.NewServer(), port: conf.Server.MetricsPort})
+	}
 
-		zap.L().Info("Shutting down gracefully...")
-		cancel()
+	if conf.DNS != nil {
+		port := conf.DNS.Port
+		if port == 0 {
+			port = defaultDNSPort
+		}
+		srvs = append(srvs, server{h: dns.New(svc, conf.DNS.Domain, conf.DNS.TTL), port: port})
+	}
 
-		repo.Close()
-		h.Close()
-		os.Exit(0)
+	// Start service
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		check.Start(ctx)
 	}()
 
-	// Start service
-	go check.Start(ctx)
-	zap.L().Info(
-		fmt.Sprintf("Starting server on %v://%v:%v", conf.Server.Scheme, conf.Server.Domain, conf.Server.Port),
-	)
-	h.Start(conf.Server.Port)
+	errs := make(chan error, len(srvs))
+	for _, srv := range srvs {
+		zap.L().Info(
+			fmt.Sprintf("Starting server on %v://%v:%v", conf.Server.Scheme, conf.Server.Domain, srv.port),
+		)
+		go func() {
+			errs <- srv.h.Start(srv.port)
+		}()
+	}
+
+	// Graceful shutdown, also when any of the servers stops on its own
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	code := 0
+	select {
+	case <-c:
+	case err := <-errs:
+		zap.L().Error("Server stopped unexpectedly", zap.Error(err))
+		code = 1
+	}
+
+	zap.L().Info("Shutting down gracefully...")
+	cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range srvs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.h.Close(); err != nil {
+				zap.L().Error("Failed to close server", zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The checker and the lease it campaigns for use the repository until they stop
+	<-checked
+	if err := repo.Close(); err != nil {
+		zap.L().Error("Failed to close repository", zap.Error(err))
+	}
+
+	// Flushes the spans of the last requests
+	tctx, tcancel := context.WithTimeout(context.Background(), flushTimeout)
+	if err := shutdownTracing(tctx); err != nil {
+		zap.L().Error("Failed to flush spans", zap.Error(err))
+	}
+	tcancel()
+	os.Exit(code)
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/snapshot"
+	"github.com/JMURv/service-discovery/internal/validation"
+	cfg "github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const usage = `usage:
+  main [flags]                                              run the server
+  main [flags] export [-format json|yaml] [-o file]         dump the configured registry
+  main [flags] import [-format json|yaml] [-replace] [file] restore a dump into the configured registry
+
+Every setting of the configuration file can be overridden with DISCOVERY_<KEYS> in the
+environment, e.g. DISCOVERY_SERVER_PORT, and with -<keys> flags, e.g. -server.port.
+Run main -help for the list.`
+
+// runCommand runs a subcommand directly against the configured backend.
+// The in-mem registry lives inside the server process, use the
+// ExportSnapshot and ImportSnapshot endpoints of a running server for it,
+// or import its snapshot file when memory.snapshot_path is configured.
+func runCommand(conf *cfg.Config, name string, args []string) error {
+	var run func(context.Context, ctrl.ServiceDiscoveryRepo, []string) error
+	switch name {
+	case "export":
+		run = exportSnapshot
+	case "import":
+		run = importSnapshot
+	default:
+		return errors.New(usage)
+	}
+
+	if conf.DB == cfg.InMem {
+		return fmt.Errorf("%v: the in-mem registry is only reachable through the /snapshot endpoints of a running server", name)
+	}
+
+	bal, err := balancer.New(conf.Balancer)
+	if err != nil {
+		return err
+	}
+
+	repo, err := newRepo(conf, bal, nil)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	return run(context.Background(), repo, args)
+}
+
+func exportSnapshot(ctx context.Context, repo ctrl.ServiceDiscoveryRepo, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	rawFormat := fs.String("format", "", "json or yaml, taken from the output file extension when omitted")
+	out := fs.String("o", "", "output file, stdout when omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := formatFor(*rawFormat, *out)
+	if err != nil {
+		return err
+	}
+
+	svcs, err := repo.ListInstances(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return snapshot.Encode(w, md.NewSnapshot(svcs, time.Now().UTC()), format)
+}
+
+func importSnapshot(ctx context.Context, repo ctrl.ServiceDiscoveryRepo, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	rawFormat := fs.String("format", "", "json or yaml, taken from the input file extension when omitted")
+	replace := fs.Bool("replace", false, "deregister instances missing from the snapshot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	in := fs.Arg(0)
+	format, err := formatFor(*rawFormat, in)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if in != "" {
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	snap, err := snapshot.Decode(r, format)
+	if err != nil {
+		return err
+	}
+	if err = validation.ValidateSnapshot(snap); err != nil {
+		return err
+	}
+
+	svcs := snap.Instances(time.Now())
+	if err = repo.Restore(ctx, svcs, *replace); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d instances\n", len(svcs))
+	return nil
+}
+
+func formatFor(raw, path string) (snapshot.Format, error) {
+	if raw == "" {
+		raw = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	return snapshot.ParseFormat(raw)
+}
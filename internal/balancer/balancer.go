@@ -0,0 +1,87 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+)
+
+var ErrNoInstances = errors.New("no instances to pick from")
+var ErrUnknownStrategy = errors.New("unknown balancing strategy")
+
+// Balancer picks one instance out of the active instances of a service.
+// Key is supplied by the caller and is only used by key-aware strategies.
+type Balancer interface {
+	Pick(name, key string, svcs []md.Service) (*md.Service, error)
+}
+
+// Router dispatches picks to the strategy configured for the service name.
+// Drained instances, with a weight of zero, are never handed to the strategy.
+type Router struct {
+	fallback  Balancer
+	overrides map[string]Balancer
+}
+
+func New(conf *config.BalancerConfig) (*Router, error) {
+	if conf == nil {
+		return &Router{fallback: NewRoundRobin(), overrides: make(map[string]Balancer)}, nil
+	}
+
+	fallback, err := fromStrategy(conf.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Services sharing a strategy share a balancer, state is kept per service name anyway
+	shared := map[config.Strategy]Balancer{conf.Strategy: fallback}
+	overrides := make(map[string]Balancer, len(conf.Services))
+	for name, strategy := range conf.Services {
+		if _, ok := shared[strategy]; !ok {
+			if shared[strategy], err = fromStrategy(strategy); err != nil {
+				return nil, fmt.Errorf("service %v: %w", name, err)
+			}
+		}
+		overrides[name] = shared[strategy]
+	}
+
+	return &Router{fallback: fallback, overrides: overrides}, nil
+}
+
+func (r *Router) Pick(name, key string, svcs []md.Service) (*md.Service, error) {
+	routable := make([]md.Service, 0, len(svcs))
+	for i := range svcs {
+		if svcs[i].Weight > 0 {
+			routable = append(routable, svcs[i])
+		}
+	}
+
+	if b, ok := r.overrides[name]; ok {
+		return b.Pick(name, key, routable)
+	}
+	return r.fallback.Pick(name, key, routable)
+}
+
+func fromStrategy(strategy config.Strategy) (Balancer, error) {
+	switch strategy {
+	case config.RoundRobin, "":
+		return NewRoundRobin(), nil
+	case config.Random:
+		return NewRandom(), nil
+	case config.WeightedRoundRobin:
+		return NewWeightedRoundRobin(), nil
+	case config.PowerOfTwo:
+		return NewPowerOfTwo(), nil
+	case config.ConsistentHash:
+		return NewConsistentHash(), nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownStrategy, strategy)
+	}
+}
+
+func weightOf(svc *md.Service) int {
+	if svc.Weight <= 0 {
+		return 1
+	}
+	return svc.Weight
+}
@@ -0,0 +1,158 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func instances(weights ...int) []md.Service {
+	svcs := make([]md.Service, len(weights))
+	for i, w := range weights {
+		svcs[i] = md.Service{Name: "svc", Address: fmt.Sprintf("addr%d", i), Weight: w}
+	}
+	return svcs
+}
+
+func pickN(t *testing.T, b Balancer, key string, svcs []md.Service, n int) map[string]int {
+	res := make(map[string]int)
+	for i := 0; i < n; i++ {
+		svc, err := b.Pick("svc", key, svcs)
+		assert.NoError(t, err)
+		res[svc.Address]++
+	}
+	return res
+}
+
+func TestNew(t *testing.T) {
+	r, err := New(nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &RoundRobin{}, r.fallback)
+
+	r, err = New(&config.BalancerConfig{
+		Strategy: config.Random,
+		Services: map[string]config.Strategy{
+			"cache":   config.ConsistentHash,
+			"orders":  config.WeightedRoundRobin,
+			"records": config.Random,
+		},
+	})
+	assert.NoError(t, err)
+	assert.IsType(t, &Random{}, r.fallback)
+	assert.IsType(t, &ConsistentHash{}, r.overrides["cache"])
+	assert.IsType(t, &WeightedRoundRobin{}, r.overrides["orders"])
+	assert.Same(t, r.fallback, r.overrides["records"])
+
+	_, err = New(&config.BalancerConfig{Strategy: "least-conn"})
+	assert.True(t, errors.Is(err, ErrUnknownStrategy))
+
+	_, err = New(&config.BalancerConfig{Services: map[string]config.Strategy{"cache": "least-conn"}})
+	assert.True(t, errors.Is(err, ErrUnknownStrategy))
+}
+
+func TestRouterDrain(t *testing.T) {
+	r, err := New(nil)
+	assert.NoError(t, err)
+
+	res := pickN(t, r, "", instances(1, 0, 1), 10)
+	assert.Equal(t, map[string]int{"addr0": 5, "addr2": 5}, res)
+
+	_, err = r.Pick("svc", "", instances(0, 0))
+	assert.Equal(t, ErrNoInstances, err)
+}
+
+func TestNoInstances(t *testing.T) {
+	for _, b := range []Balancer{NewRoundRobin(), NewRandom(), NewWeightedRoundRobin(), NewPowerOfTwo(), NewConsistentHash()} {
+		_, err := b.Pick("svc", "key", nil)
+		assert.Equal(t, ErrNoInstances, err)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	b := NewRoundRobin()
+	svcs := instances(1, 1, 1)
+
+	for i := 0; i < 6; i++ {
+		svc, err := b.Pick("svc", "", svcs)
+		assert.NoError(t, err)
+		assert.Equal(t, svcs[i%3].Address, svc.Address)
+	}
+
+	// Shrinking the instance set must not go out of range
+	svc, err := b.Pick("svc", "", svcs[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, "addr0", svc.Address)
+}
+
+func TestRandom(t *testing.T) {
+	res := pickN(t, NewRandom(), "", instances(1, 1), 200)
+	assert.Len(t, res, 2)
+
+	// 5% of picks go to the canary
+	res = pickN(t, NewRandom(), "", instances(95, 5), 10000)
+	assert.InDelta(t, 500, res["addr1"], 150)
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	b := NewWeightedRoundRobin()
+	svcs := instances(5, 1, 1)
+
+	res := pickN(t, b, "", svcs, 70)
+	assert.Equal(t, map[string]int{"addr0": 50, "addr1": 10, "addr2": 10}, res)
+
+	// Smooth: the heavy instance never takes the whole cycle in one burst
+	var seq []string
+	for i := 0; i < 7; i++ {
+		svc, _ := b.Pick("svc", "", svcs)
+		seq = append(seq, svc.Address)
+	}
+	assert.Equal(t, []string{"addr0", "addr0", "addr1", "addr0", "addr2", "addr0", "addr0"}, seq)
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	b := NewPowerOfTwo()
+
+	res := pickN(t, b, "", instances(1, 1, 1, 1), 400)
+	for _, n := range res {
+		assert.InDelta(t, 100, n, 10)
+	}
+
+	res = pickN(t, NewPowerOfTwo(), "", instances(3, 1), 400)
+	assert.Greater(t, res["addr0"], res["addr1"])
+}
+
+func TestConsistentHash(t *testing.T) {
+	b := NewConsistentHash()
+	svcs := instances(1, 1, 1, 1)
+
+	// Same key, same instance
+	first, err := b.Pick("svc", "user-42", svcs)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		svc, _ := b.Pick("svc", "user-42", svcs)
+		assert.Equal(t, first.Address, svc.Address)
+	}
+
+	// Removing an instance only moves keys owned by it
+	owners := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		svc, _ := b.Pick("svc", key, svcs)
+		owners[key] = svc.Address
+	}
+
+	remaining := svcs[:3]
+	for key, owner := range owners {
+		svc, _ := b.Pick("svc", key, remaining)
+		if owner != "addr3" {
+			assert.Equal(t, owner, svc.Address, key)
+		}
+	}
+
+	// Empty key falls back to random
+	res := pickN(t, b, "", svcs, 200)
+	assert.Greater(t, len(res), 1)
+}
@@ -0,0 +1,103 @@
+package balancer
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const replicas = 100
+
+type ring struct {
+	fingerprint string
+	hashes      []uint64
+	owners      map[uint64]string
+}
+
+// ConsistentHash maps the caller supplied key onto a hash ring, so the same key
+// keeps landing on the same instance while membership changes move only a
+// fraction of the keys. Picks without a key fall back to a random instance.
+type ConsistentHash struct {
+	mu     sync.Mutex
+	rings  map[string]*ring
+	random *Random
+}
+
+func NewConsistentHash() *ConsistentHash {
+	return &ConsistentHash{
+		rings:  make(map[string]*ring),
+		random: NewRandom(),
+	}
+}
+
+func (b *ConsistentHash) Pick(name, key string, svcs []md.Service) (*md.Service, error) {
+	if len(svcs) == 0 {
+		return nil, ErrNoInstances
+	} else if key == "" {
+		return b.random.Pick(name, key, svcs)
+	}
+
+	b.mu.Lock()
+	r := b.ring(name, svcs)
+	b.mu.Unlock()
+
+	h := hash(key)
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+
+	owner := r.owners[r.hashes[idx]]
+	for i := range svcs {
+		if svcs[i].Address == owner {
+			return &svcs[i], nil
+		}
+	}
+	return nil, ErrNoInstances
+}
+
+// ring returns the cached ring for the service, rebuilding it when instances or weights changed
+func (b *ConsistentHash) ring(name string, svcs []md.Service) *ring {
+	members := make([]string, len(svcs))
+	for i := range svcs {
+		members[i] = svcs[i].Address + "#" + strconv.Itoa(weightOf(&svcs[i]))
+	}
+	slices.Sort(members)
+	fingerprint := strings.Join(members, ",")
+
+	if r, ok := b.rings[name]; ok && r.fingerprint == fingerprint {
+		return r
+	}
+
+	total := 0
+	for i := range svcs {
+		total += weightOf(&svcs[i])
+	}
+
+	// Virtual nodes are spread proportionally to weight, keeping the ring size around replicas per instance
+	r := &ring{fingerprint: fingerprint, owners: make(map[uint64]string)}
+	for i := range svcs {
+		vnodes := max(1, replicas*len(svcs)*weightOf(&svcs[i])/total)
+		for v := 0; v < vnodes; v++ {
+			h := hash(svcs[i].Address + "#" + strconv.Itoa(v))
+			if _, taken := r.owners[h]; !taken {
+				r.owners[h] = svcs[i].Address
+				r.hashes = append(r.hashes, h)
+			}
+		}
+	}
+	slices.Sort(r.hashes)
+
+	b.rings[name] = r
+	return r
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
@@ -0,0 +1,53 @@
+package balancer
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"math/rand/v2"
+	"sync"
+)
+
+// PowerOfTwo samples two instances and picks the one that was handed out less,
+// relative to its weight. The registry has no view of real connection load,
+// so the number of previous picks stands in for it.
+type PowerOfTwo struct {
+	mu    sync.Mutex
+	picks map[string]map[string]int
+}
+
+func NewPowerOfTwo() *PowerOfTwo {
+	return &PowerOfTwo{
+		picks: make(map[string]map[string]int),
+	}
+}
+
+func (b *PowerOfTwo) Pick(name, _ string, svcs []md.Service) (*md.Service, error) {
+	if len(svcs) == 0 {
+		return nil, ErrNoInstances
+	} else if len(svcs) == 1 {
+		return &svcs[0], nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	picks, ok := b.picks[name]
+	if !ok || len(picks) > 2*len(svcs) {
+		picks = make(map[string]int, len(svcs))
+		b.picks[name] = picks
+	}
+
+	i := rand.IntN(len(svcs))
+	j := rand.IntN(len(svcs) - 1)
+	if j >= i {
+		j++
+	}
+
+	// Compare picks[i]/weight[i] with picks[j]/weight[j] without division
+	a, c := &svcs[i], &svcs[j]
+	if picks[c.Address]*weightOf(a) < picks[a.Address]*weightOf(c) {
+		a = c
+	}
+
+	picks[a.Address]++
+	return a, nil
+}
@@ -0,0 +1,32 @@
+package balancer
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"math/rand/v2"
+)
+
+// Random picks instances at random, proportionally to their weight.
+type Random struct{}
+
+func NewRandom() *Random {
+	return &Random{}
+}
+
+func (b *Random) Pick(_, _ string, svcs []md.Service) (*md.Service, error) {
+	if len(svcs) == 0 {
+		return nil, ErrNoInstances
+	}
+
+	total := 0
+	for i := range svcs {
+		total += weightOf(&svcs[i])
+	}
+
+	n := rand.IntN(total)
+	for i := range svcs {
+		if n -= weightOf(&svcs[i]); n < 0 {
+			return &svcs[i], nil
+		}
+	}
+	return &svcs[len(svcs)-1], nil
+}
@@ -0,0 +1,31 @@
+package balancer
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"sync"
+)
+
+// RoundRobin cycles through instances in order and ignores weights.
+type RoundRobin struct {
+	mu    sync.Mutex
+	index map[string]int
+}
+
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{
+		index: make(map[string]int),
+	}
+}
+
+func (b *RoundRobin) Pick(name, _ string, svcs []md.Service) (*md.Service, error) {
+	if len(svcs) == 0 {
+		return nil, ErrNoInstances
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.index[name] % len(svcs)
+	b.index[name] = (current + 1) % len(svcs)
+	return &svcs[current], nil
+}
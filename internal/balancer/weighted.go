@@ -0,0 +1,46 @@
+package balancer
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"sync"
+)
+
+// WeightedRoundRobin is the smooth weighted round-robin used by nginx:
+// picks are spread evenly over time instead of in bursts per instance.
+type WeightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+func NewWeightedRoundRobin() *WeightedRoundRobin {
+	return &WeightedRoundRobin{
+		current: make(map[string]map[string]int),
+	}
+}
+
+func (b *WeightedRoundRobin) Pick(name, _ string, svcs []md.Service) (*md.Service, error) {
+	if len(svcs) == 0 {
+		return nil, ErrNoInstances
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev := b.current[name]
+	current := make(map[string]int, len(svcs))
+
+	total, best := 0, -1
+	for i := range svcs {
+		w := weightOf(&svcs[i])
+		total += w
+
+		current[svcs[i].Address] = prev[svcs[i].Address] + w
+		if best == -1 || current[svcs[i].Address] > current[svcs[best].Address] {
+			best = i
+		}
+	}
+
+	current[svcs[best].Address] -= total
+	b.current[name] = current
+	return &svcs[best], nil
+}
@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"context"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const subscriberBuffer = 64
+
+type subscriber struct {
+	name string
+	ch   chan md.ServiceEvent
+}
+
+// Broker fans out membership events to subscribers.
+// Subscribers that do not keep up lose events instead of blocking publishers.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*subscriber]struct{}
+}
+
+func New() *Broker {
+	return &Broker{
+		subs: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe returns a channel of events for the given service name.
+// An empty name subscribes to every service. The channel is closed once ctx is done.
+func (b *Broker) Subscribe(ctx context.Context, name string) <-chan md.ServiceEvent {
+	sub := &subscriber{
+		name: name,
+		ch:   make(chan md.ServiceEvent, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.subs, sub)
+		close(sub.ch)
+		b.mu.Unlock()
+	}()
+
+	return sub.ch
+}
+
+func (b *Broker) Publish(typ md.EventType, name, addr string) {
+	evt := md.ServiceEvent{
+		Type:      typ,
+		Name:      name,
+		Address:   addr,
+		Timestamp: time.Now(),
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if sub.name != "" && sub.name != name {
+			continue
+		}
+
+		select {
+		case sub.ch <- evt:
+		default:
+			zap.L().Warn(
+				"Subscriber is full, dropping event",
+				zap.String("type", string(typ)),
+				zap.String("name", name), zap.String("address", addr),
+			)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package broker
+
+import (
+	"context"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBroker(t *testing.T) {
+	b := New()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	all := b.Subscribe(ctx, "")
+	svc1 := b.Subscribe(ctx, "service1")
+
+	t.Run("Filter by name", func(t *testing.T) {
+		b.Publish(md.Activated, "service2", "addr2")
+		b.Publish(md.Deactivated, "service1", "addr1")
+
+		evt := <-all
+		assert.Equal(t, md.Activated, evt.Type)
+		assert.Equal(t, "service2", evt.Name)
+
+		evt = <-all
+		assert.Equal(t, md.Deactivated, evt.Type)
+
+		evt = <-svc1
+		assert.Equal(t, md.Deactivated, evt.Type)
+		assert.Equal(t, "addr1", evt.Address)
+		assert.Empty(t, svc1)
+	})
+
+	t.Run("Slow subscriber does not block", func(t *testing.T) {
+		for i := 0; i < subscriberBuffer+10; i++ {
+			b.Publish(md.Registered, "service1", "addr1")
+		}
+		assert.Len(t, svc1, subscriberBuffer)
+	})
+
+	t.Run("Close on cancel", func(t *testing.T) {
+		cancel()
+		for range all {
+		}
+		for range svc1 {
+		}
+	})
+}
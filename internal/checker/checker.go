@@ -2,190 +2,424 @@ package checker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/JMURv/service-discovery/internal/broker"
 	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/metrics"
+	"github.com/JMURv/service-discovery/internal/repo"
 	"github.com/JMURv/service-discovery/pkg/config"
 	md "github.com/JMURv/service-discovery/pkg/model"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultPath       = "/health-check"
+	defaultTimeout    = 5
+	defaultResync     = 30
+	defaultHealthSync = 60
+)
+
 type Checker struct {
-	conf           *config.CheckerConfig
-	repo           ctrl.ServiceDiscoveryRepo
-	newAddrChan    chan md.Service
-	failedAttempts map[string]map[string]int
-	req            config.AcceptReq
+	conf        *config.CheckerConfig
+	repo        ctrl.ServiceDiscoveryRepo
+	newAddrChan chan md.Service
+	events      *broker.Broker
+	req         config.AcceptReq
+	elector     Elector
+
+	// scheduler is set while the replica leads, nil on standby
+	scheduler atomic.Pointer[scheduler]
 }
 
-func New(repo ctrl.ServiceDiscoveryRepo, newAddr chan md.Service, conf *config.CheckerConfig, req config.AcceptReq) *Checker {
+func New(repo ctrl.ServiceDiscoveryRepo, newAddr chan md.Service, events *broker.Broker, conf *config.CheckerConfig, req config.AcceptReq, elector Elector) *Checker {
 	return &Checker{
-		repo:           repo,
-		newAddrChan:    newAddr,
-		failedAttempts: make(map[string]map[string]int),
-		conf:           conf,
-		req:            req,
+		repo:        repo,
+		newAddrChan: newAddr,
+		events:      events,
+		conf:        conf,
+		req:         req,
+		elector:     elector,
 	}
 }
 
+// Start runs the health checks whenever the replica is elected, until ctx is done.
 func (c *Checker) Start(ctx context.Context) {
+	events := c.events.Subscribe(ctx, "")
 	go c.listenForNewAddresses(ctx)
+	go c.listenForEvents(events)
 
-	names, err := c.repo.ListServices(ctx)
-	if err != nil {
-		zap.L().Debug("failed to list services", zap.Error(err))
-		return
+	cancel := context.CancelFunc(func() {})
+	var done chan struct{}
+	for leading := range c.elector.Campaign(ctx) {
+		if leading && done == nil {
+			zap.L().Info("health check started, replica elected")
+
+			var term context.Context
+			term, cancel = context.WithCancel(ctx)
+			done = make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				c.lead(term)
+			}(done)
+		} else if !leading && done != nil {
+			zap.L().Info("health check on standby, leadership lost")
+			cancel()
+			<-done
+			done = nil
+		}
+	}
+
+	cancel()
+	if done != nil {
+		<-done
+	}
+	zap.L().Info("health check stopped")
+}
+
+// lead checks every instance of the registry until the term ends.
+// The health state is picked up from the registry, so checks continue
+// where the previous leader left them.
+func (c *Checker) lead(ctx context.Context) {
+	s := newScheduler(c.probe, c.conf.Workers, c.conf.Jitter)
+	c.scheduler.Store(s)
+	defer c.scheduler.Store(nil)
+
+	go c.resync(ctx)
+	s.run(ctx)
+}
+
+// resync schedules instances registered through other replicas,
+// their registrations never reach this replica otherwise.
+func (c *Checker) resync(ctx context.Context) {
+	interval := time.Duration(c.conf.Resync) * time.Second
+	if interval <= 0 {
+		interval = defaultResync * time.Second
 	}
 
-	for _, name := range names {
-		addrs, err := c.repo.ListAddrs(ctx, name)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		svcs, err := c.repo.ListInstances(ctx)
 		if err != nil {
-			zap.L().Debug("failed to list addrs", zap.Error(err))
-			continue
+			zap.L().Debug("failed to list instances", zap.Error(err))
+		}
+
+		for _, svc := range svcs {
+			c.schedule(svc.Name, svc.Address)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// listenForNewAddresses drains the channel on standby too, so registrations never block.
+func (c *Checker) listenForNewAddresses(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case newSvc := <-c.newAddrChan:
+			c.schedule(newSvc.Name, newSvc.Address)
 		}
+	}
+}
 
-		for _, addr := range addrs {
-			go c.worker(ctx, name, addr)
+// listenForEvents picks up instances registered through other cluster nodes
+// and cancels checks of instances as soon as they are deregistered.
+func (c *Checker) listenForEvents(events <-chan md.ServiceEvent) {
+	for evt := range events {
+		switch evt.Type {
+		case md.Registered:
+			c.schedule(evt.Name, evt.Address)
+		case md.Deregistered:
+			if s := c.scheduler.Load(); s != nil {
+				s.remove(evt.Name, evt.Address)
+			}
 		}
 	}
+}
 
-	zap.L().Info("health check started")
-	select {
-	case <-ctx.Done():
-		zap.L().Info("health check stopped")
+func (c *Checker) schedule(name, addr string) {
+	s := c.scheduler.Load()
+	if s == nil {
 		return
 	}
+
+	// The first checks are spread over a cooldown, so a restart does not probe every instance at once
+	if !s.add(name, addr, time.Duration(c.conf.CooldownReq)*time.Second) {
+		zap.L().Debug("service is already checked", zap.String("svc", name), zap.String("addr", addr))
+	}
 }
 
-func (c *Checker) listenForNewAddresses(ctx context.Context) {
-	for newSvc := range c.newAddrChan {
-		go c.worker(ctx, newSvc.Name, newSvc.Address)
+// probe checks an instance once and returns the delay before its next check.
+func (c *Checker) probe(ctx context.Context, t *task) (time.Duration, bool) {
+	name, addr := t.name, t.addr
+	cooldown := time.Duration(c.conf.CooldownReq) * time.Second
+
+	svc, err := c.repo.GetService(ctx, name, addr)
+	if err != nil && errors.Is(err, repo.ErrNotFound) {
+		zap.L().Info("health check stopped, service deregistered", zap.String("svc", name), zap.String("addr", addr))
+		return 0, false
+	} else if err != nil {
+		zap.L().Error(
+			"failed to get service",
+			zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
+		)
+		return cooldown, true
+	}
+
+	// The state is picked up from the registry, so it survives restarts
+	if t.state == nil {
+		t.state = newMachine(c.conf, svc.Health)
+	}
+	state := t.state
+	check := c.checkFor(svc)
+
+	// Leases are checked at least once per TTL so expiry is noticed in time
+	interval := time.Duration(check.Interval) * time.Second
+	if ttl := time.Duration(svc.TTL) * time.Second; check.Type == md.CheckTTL && ttl < interval {
+		interval = ttl
+	}
+
+	start := time.Now()
+	switch check.Type {
+	case md.CheckTTL:
+		if svc.LeaseExpired(time.Now()) {
+			err = fmt.Errorf("%w: last heartbeat at %s", ErrLeaseExpired, svc.LastHeartbeat.Format(time.RFC3339))
+		}
+	case md.CheckHTTP:
+		err = c.HTTPReq(addr, check)
+	case md.CheckGRPC:
+		err = c.gRPCReq(name, addr, check)
+	case md.CheckTCP:
+		err = c.TCPReq(addr, check)
+	}
+	if check.Type != md.CheckNone {
+		metrics.ObserveCheck(check.Type, err, start)
+	}
+
+	if err != nil {
+		zap.L().Warn(
+			"service health check failed",
+			zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
+		)
+	}
+
+	prev := state.health.State
+	changed := state.observe(err, time.Now())
+	if changed {
+		zap.L().Info(
+			"service health changed",
+			zap.String("svc", name), zap.String("addr", addr),
+			zap.String("from", string(prev)), zap.String("to", string(state.health.State)),
+			zap.String("reason", state.health.Reason),
+		)
+	}
+
+	// Unchanged results are not written, in cluster mode every write is a Raft log entry
+	if c.outdated(&svc.Health, &state.health) {
+		if err := c.repo.SetHealth(ctx, name, addr, state.health); err != nil {
+			zap.L().Error(
+				"failed to update service health",
+				zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
+			)
+		}
+	}
+
+	switch state.health.State {
+	case md.HealthPassing:
+		if svc.IsActive {
+			break
+		}
+		if err := c.repo.ActivateSvc(ctx, name, addr); err != nil {
+			zap.L().Error(
+				"failed to activate service",
+				zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
+			)
+		} else {
+			c.events.Publish(md.Activated, name, addr)
+		}
+	case md.HealthFailing, md.HealthQuarantined:
+		if !svc.IsActive {
+			break
+		}
+		if err := c.repo.DeactivateSvc(ctx, name, addr); err != nil {
+			zap.L().Error(
+				"failed to deactivate service",
+				zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
+			)
+		} else {
+			c.events.Publish(md.Deactivated, name, addr)
+		}
+	}
+
+	if err != nil && state.health.Failures >= c.conf.MaxRetriesReq {
+		zap.L().Warn(
+			"deregistering service due to failed health checks",
+			zap.String("svc", name), zap.String("addr", addr),
+		)
+
+		if err = c.repo.Deregister(ctx, name, addr); err != nil {
+			zap.L().Error(
+				"failed to deregister service",
+				zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
+			)
+			return interval, true
+		}
+
+		c.events.Publish(md.Deregistered, name, addr)
+		return 0, false
 	}
+
+	return interval, true
 }
 
-func (c *Checker) worker(ctx context.Context, name, addr string) {
-	if _, exists := c.failedAttempts[name]; !exists {
-		c.failedAttempts[name] = make(map[string]int)
+// outdated reports whether the observed health has to be written over the stored one.
+// Changes of state or check outcome are written right away, counters and
+// the time of the last check once per sync interval.
+func (c *Checker) outdated(stored, observed *md.Health) bool {
+	if stored.State != observed.State || stored.LastResult != observed.LastResult || stored.LastError != observed.LastError {
+		return true
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			zap.L().Info("worker stopped", zap.String("svc", name), zap.String("addr", addr))
-			return
+	sync := time.Duration(c.conf.HealthSync) * time.Second
+	if sync <= 0 {
+		sync = defaultHealthSync * time.Second
+	}
+	return observed.LastCheck.Sub(stored.LastCheck) >= sync
+}
+
+// checkFor resolves the check spec of an instance, filling the gaps from configuration.
+// Instances without a spec are probed with the configured protocol, or by lease when registered with a TTL.
+func (c *Checker) checkFor(svc *md.Service) *md.Check {
+	check := &md.Check{}
+	if svc.Check != nil {
+		*check = *svc.Check
+	}
+
+	if check.Type == "" {
+		switch {
+		case svc.TTL > 0:
+			check.Type = md.CheckTTL
+		case c.req == config.HTTP:
+			check.Type = md.CheckHTTP
 		default:
-			time.Sleep(time.Duration(c.conf.CooldownReq) * time.Second)
-			success := false
-
-			switch c.req {
-			case config.HTTP:
-				success = c.HTTPReq(addr)
-			case config.GRPC:
-				success = c.gRPCReq(name, addr)
-			}
+			check.Type = md.CheckGRPC
+		}
+	}
 
-			if !success {
-				zap.L().Warn(
-					"service health check failed",
-					zap.String("svc", name), zap.String("addr", addr),
-				)
-
-				if err := c.repo.DeactivateSvc(ctx, name, addr); err != nil {
-					zap.L().Error(
-						"failed to deactivate service",
-						zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
-					)
-				}
-
-				c.failedAttempts[name][addr]++
-				if c.failedAttempts[name][addr] >= c.conf.MaxRetriesReq {
-					zap.L().Warn(
-						"deregistering service due to failed health checks",
-						zap.String("svc", name), zap.String("addr", addr),
-					)
-
-					if err := c.repo.Deregister(ctx, name, addr); err != nil {
-						zap.L().Error(
-							"failed to deregister service",
-							zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
-						)
-					} else {
-						delete(c.failedAttempts[name], addr)
-					}
-
-					return
-				}
-			} else {
-				if err := c.repo.ActivateSvc(ctx, name, addr); err != nil {
-					zap.L().Error(
-						"failed to activate service",
-						zap.String("svc", name), zap.String("addr", addr), zap.Error(err),
-					)
-				}
-				delete(c.failedAttempts[name], addr)
-			}
+	if check.Path == "" {
+		check.Path = c.conf.Path
+		if check.Path == "" {
+			check.Path = defaultPath
+		}
+	}
+
+	if len(check.Statuses) == 0 {
+		check.Statuses = []int{http.StatusOK}
+	}
+
+	if check.Interval <= 0 {
+		check.Interval = c.conf.CooldownReq
+	}
 
+	if check.Timeout <= 0 {
+		check.Timeout = c.conf.Timeout
+		if check.Timeout <= 0 {
+			check.Timeout = defaultTimeout
 		}
 	}
+
+	if check.GRPCService == "" {
+		check.GRPCService = svc.Name
+	}
+
+	return check
 }
 
-func (c *Checker) HTTPReq(addr string) bool {
-	success := false
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%v/health-check", addr), nil)
+func (c *Checker) HTTPReq(addr string, check *md.Check) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%v%v", addr, check.Path), nil)
 	if err != nil {
 		zap.L().Debug("failed to create request", zap.Error(err))
-		return false
+		return err
 	}
 
-	cli := &http.Client{Timeout: 5 * time.Second}
+	cli := &http.Client{Timeout: time.Duration(check.Timeout) * time.Second}
 	resp, err := cli.Do(req)
-	if resp != nil {
-		if err := resp.Body.Close(); err != nil {
-			zap.L().Error("failed to close response body", zap.Error(err))
-		}
+	if err != nil {
+		return err
 	}
-	if err == nil && resp.StatusCode == http.StatusOK {
-		success = true
+
+	if err := resp.Body.Close(); err != nil {
+		zap.L().Error("failed to close response body", zap.Error(err))
 	}
 
-	return success
+	if !slices.Contains(check.Statuses, resp.StatusCode) {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return nil
 }
 
-func (c *Checker) gRPCReq(name, addr string) bool {
-	success := false
-	addr = strings.TrimPrefix(addr, "http://")
-	addr = strings.TrimPrefix(addr, "https://")
+func (c *Checker) TCPReq(addr string, check *md.Check) error {
+	addr = trimScheme(addr)
+
+	conn, err := net.DialTimeout("tcp", addr, time.Duration(check.Timeout)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Close(); err != nil {
+		zap.L().Debug("failed to close connection", zap.String("addr", addr), zap.Error(err))
+	}
+	return nil
+}
+
+func (c *Checker) gRPCReq(name, addr string, check *md.Check) error {
+	addr = trimScheme(addr)
 
 	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
-		zap.L().Warn("failed to connect to service", zap.String("svc", name), zap.String("addr", addr), zap.Error(err))
-		return false
+		return err
 	}
 
-	check, err := grpc_health_v1.NewHealthClient(conn).
-		Check(context.Background(), &grpc_health_v1.HealthCheckRequest{
-			Service: name,
+	defer func() {
+		if err := conn.Close(); err != nil {
+			zap.L().Warn("failed to close connection", zap.String("svc", name), zap.String("addr", addr), zap.Error(err))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(check.Timeout)*time.Second)
+	defer cancel()
+
+	res, err := grpc_health_v1.NewHealthClient(conn).
+		Check(ctx, &grpc_health_v1.HealthCheckRequest{
+			Service: check.GRPCService,
 		})
 	if err != nil {
-		zap.L().Warn("gRPC health check failed", zap.String("svc", name), zap.String("addr", addr), zap.Error(err))
-	} else if check.GetStatus() == grpc_health_v1.HealthCheckResponse_SERVING {
-		success = true
-	} else {
-		zap.L().Warn(
-			"service is not in a serving state",
-			zap.String("svc", name), zap.String("addr", addr),
-			zap.String("status", check.GetStatus().String()),
-		)
+		return err
 	}
 
-	if err := conn.Close(); err != nil {
-		zap.L().Warn("failed to close connection", zap.String("svc", name), zap.String("addr", addr), zap.Error(err))
+	if res.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%w: %s", ErrNotServing, res.GetStatus())
 	}
+	return nil
+}
 
-	return success
+func trimScheme(addr string) string {
+	addr = strings.TrimPrefix(addr, "http://")
+	return strings.TrimPrefix(addr, "https://")
 }
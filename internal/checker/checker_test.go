@@ -0,0 +1,209 @@
+package checker
+
+import (
+	"context"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/metrics"
+	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/mocks"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckFor(t *testing.T) {
+	c := New(nil, nil, nil, &config.CheckerConfig{CooldownReq: 5, Timeout: 2}, config.GRPC, Standalone{})
+
+	// Test case 1: Defaults from configuration
+	check := c.checkFor(&md.Service{Name: "orders"})
+	assert.Equal(t, &md.Check{
+		Type:        md.CheckGRPC,
+		Path:        defaultPath,
+		Statuses:    []int{http.StatusOK},
+		Interval:    5,
+		Timeout:     2,
+		GRPCService: "orders",
+	}, check)
+
+	// Test case 2: Lease mode
+	check = c.checkFor(&md.Service{Name: "worker", TTL: 10})
+	assert.Equal(t, md.CheckTTL, check.Type)
+
+	// Test case 3: Spec overrides configuration
+	spec := &md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{204}, Interval: 1, Timeout: 1, GRPCService: "x"}
+	check = c.checkFor(&md.Service{Name: "orders", Check: spec})
+	assert.Equal(t, spec, check)
+	assert.NotSame(t, spec, check)
+}
+
+func TestHTTPReq(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := New(nil, nil, nil, &config.CheckerConfig{}, config.HTTP, Standalone{})
+
+	assert.NoError(t, c.HTTPReq(srv.URL, &md.Check{Path: "/health-check", Statuses: []int{200}, Timeout: 1}))
+	assert.NoError(t, c.HTTPReq(srv.URL, &md.Check{Path: "/ready", Statuses: []int{200, 204}, Timeout: 1}))
+
+	err := c.HTTPReq(srv.URL, &md.Check{Path: "/ready", Statuses: []int{200}, Timeout: 1})
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+	assert.EqualError(t, err, "unexpected status code: 204")
+}
+
+func TestTCPReq(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	c := New(nil, nil, nil, &config.CheckerConfig{}, config.HTTP, Standalone{})
+	addr := lis.Addr().String()
+
+	assert.NoError(t, c.TCPReq("http://"+addr, &md.Check{Timeout: 1}))
+
+	assert.NoError(t, lis.Close())
+	assert.Error(t, c.TCPReq(addr, &md.Check{Timeout: 1}))
+}
+
+func TestProbe(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	events := broker.New()
+	c := New(svcRepo, nil, events, &config.CheckerConfig{CooldownReq: 5, MaxRetriesReq: 2}, config.HTTP, Standalone{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evts := events.Subscribe(ctx, "worker")
+
+	name := "worker"
+	addr := "addr1"
+	task := &task{key: key{name: name, addr: addr}}
+
+	// Test case 1: Lease alive
+	svc := &md.Service{Name: name, Address: addr, TTL: 2, LastHeartbeat: time.Now()}
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(svc, nil).Times(1)
+	svcRepo.EXPECT().SetHealth(gomock.Any(), name, addr, gomock.Any()).Return(nil).Times(1)
+	svcRepo.EXPECT().ActivateSvc(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	passed := testutil.ToFloat64(metrics.Checks.WithLabelValues("ttl", metrics.CheckPassed))
+	delay, keep := c.probe(ctx, task)
+	assert.True(t, keep)
+	assert.Equal(t, 2*time.Second, delay)
+	assert.Equal(t, md.HealthPassing, task.state.health.State)
+	assert.Equal(t, md.Activated, (<-evts).Type)
+	assert.Equal(t, passed+1, testutil.ToFloat64(metrics.Checks.WithLabelValues("ttl", metrics.CheckPassed)))
+
+	// Test case 2: Unchanged results are not written
+	svc.IsActive, svc.Health = true, task.state.health
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(svc, nil).Times(1)
+
+	_, keep = c.probe(ctx, task)
+	assert.True(t, keep)
+	assert.Equal(t, 2, task.state.health.Successes)
+
+	// Test case 3: Unchanged results are written once per sync interval
+	svc.Health.LastCheck = time.Now().Add(-2 * time.Minute)
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(svc, nil).Times(1)
+	svcRepo.EXPECT().SetHealth(gomock.Any(), name, addr, gomock.Any()).Return(nil).Times(1)
+
+	_, keep = c.probe(ctx, task)
+	assert.True(t, keep)
+
+	// Test case 4: Lease expired
+	svc.LastHeartbeat = time.Now().Add(-time.Minute)
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(svc, nil).Times(1)
+	svcRepo.EXPECT().SetHealth(gomock.Any(), name, addr, gomock.Any()).Return(nil).Times(1)
+	svcRepo.EXPECT().DeactivateSvc(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	failed := testutil.ToFloat64(metrics.Checks.WithLabelValues("ttl", metrics.CheckFailed))
+	_, keep = c.probe(ctx, task)
+	assert.True(t, keep)
+	assert.Equal(t, failed+1, testutil.ToFloat64(metrics.Checks.WithLabelValues("ttl", metrics.CheckFailed)))
+	assert.Equal(t, md.HealthFailing, task.state.health.State)
+	assert.False(t, task.state.health.LastResult)
+	assert.Contains(t, task.state.health.LastError, ErrLeaseExpired.Error())
+	assert.Equal(t, md.Deactivated, (<-evts).Type)
+
+	// Test case 5: Deregistered after max retries, the instance is inactive already
+	svc.IsActive, svc.Health = false, task.state.health
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(svc, nil).Times(1)
+	svcRepo.EXPECT().Deregister(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	_, keep = c.probe(ctx, task)
+	assert.False(t, keep)
+	assert.Equal(t, md.Deregistered, (<-evts).Type)
+
+	// Test case 6: Service deregistered
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(nil, repo.ErrNotFound).Times(1)
+
+	_, keep = c.probe(ctx, task)
+	assert.False(t, keep)
+}
+
+type elector chan bool
+
+func (e elector) Campaign(ctx context.Context) <-chan bool {
+	return e
+}
+
+func TestLeadership(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	newAddr := make(chan md.Service)
+	campaign := make(elector)
+	c := New(svcRepo, newAddr, broker.New(), &config.CheckerConfig{CooldownReq: 60, Workers: 1}, config.HTTP, campaign)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		c.Start(ctx)
+	}()
+
+	// Test case 1: Registrations are drained on standby without being checked
+	newAddr <- md.Service{Name: "worker", Address: "addr1"}
+	assert.Nil(t, c.scheduler.Load())
+
+	// Test case 2: The elected replica checks every instance of the registry
+	svcRepo.EXPECT().ListInstances(gomock.Any()).Return([]md.Service{{Name: "worker", Address: "addr1"}}, nil).Times(1)
+	campaign <- true
+	assert.Eventually(t, func() bool {
+		s := c.scheduler.Load()
+		return s != nil && s.len() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	newAddr <- md.Service{Name: "worker", Address: "addr2"}
+	assert.Eventually(t, func() bool {
+		return c.scheduler.Load().len() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// Test case 3: Checks stop once leadership is lost
+	campaign <- false
+	assert.Eventually(t, func() bool {
+		return c.scheduler.Load() == nil
+	}, time.Second, 10*time.Millisecond)
+
+	close(campaign)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("checker not stopped")
+	}
+}
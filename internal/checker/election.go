@@ -0,0 +1,23 @@
+package checker
+
+import "context"
+
+// Elector decides which replica runs the health checks, the others stay on standby.
+// Campaign sends true when the replica gains leadership and false when it loses it.
+// The channel is closed once ctx is done.
+type Elector interface {
+	Campaign(ctx context.Context) <-chan bool
+}
+
+// Standalone is the Elector of a replica that does not share its registry, it always leads.
+type Standalone struct{}
+
+func (Standalone) Campaign(ctx context.Context) <-chan bool {
+	ch := make(chan bool, 1)
+	ch <- true
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
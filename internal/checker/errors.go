@@ -0,0 +1,7 @@
+package checker
+
+import "errors"
+
+var ErrUnexpectedStatus = errors.New("unexpected status code")
+var ErrNotServing = errors.New("service is not serving")
+var ErrLeaseExpired = errors.New("lease expired")
@@ -0,0 +1,224 @@
+package checker
+
+import (
+	"container/heap"
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+type key struct {
+	name string
+	addr string
+}
+
+// task is a single instance scheduled for health checks.
+// A task is out of the queue while it is being probed, so its state
+// is only ever touched by one worker at a time and needs no locking.
+type task struct {
+	key
+	state   *machine
+	next    time.Time
+	index   int
+	removed bool
+}
+
+// probeFunc checks an instance and returns the delay before its next check.
+// Returning false stops scheduling the instance.
+type probeFunc func(ctx context.Context, t *task) (time.Duration, bool)
+
+// scheduler runs health checks of every instance on a bounded pool of workers.
+// Tasks are kept in a heap ordered by their next run, so a single goroutine
+// sleeps until the earliest one is due instead of one goroutine per instance.
+// Each instance is scheduled at most once and every delay is jittered
+// to spread checks of instances registered at the same time.
+type scheduler struct {
+	mu      sync.Mutex
+	tasks   map[key]*task
+	queue   taskQueue
+	wake    chan struct{}
+	probe   probeFunc
+	workers int
+	jitter  float64
+}
+
+func newScheduler(probe probeFunc, workers, jitter int) *scheduler {
+	return &scheduler{
+		tasks:   make(map[key]*task),
+		wake:    make(chan struct{}, 1),
+		probe:   probe,
+		workers: max(workers, 1),
+		jitter:  float64(jitter) / 100,
+	}
+}
+
+// add schedules an instance to be checked within the initial delay.
+// It reports false if the instance is already scheduled.
+func (s *scheduler) add(name, addr string, delay time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{name: name, addr: addr}
+	if _, ok := s.tasks[k]; ok {
+		return false
+	}
+
+	t := &task{key: k, next: time.Now().Add(spread(delay)), index: -1}
+	s.tasks[k] = t
+	heap.Push(&s.queue, t)
+	s.notify()
+	return true
+}
+
+// remove stops checking an instance. A check already in flight finishes
+// but the instance is not scheduled again.
+func (s *scheduler) remove(name, addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key{name: name, addr: addr}
+	t, ok := s.tasks[k]
+	if !ok {
+		return
+	}
+
+	delete(s.tasks, k)
+	t.removed = true
+	if t.index >= 0 {
+		heap.Remove(&s.queue, t.index)
+	}
+	s.notify()
+}
+
+func (s *scheduler) removed(t *task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return t.removed
+}
+
+func (s *scheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks)
+}
+
+// run dispatches due tasks to the workers until the context is done.
+func (s *scheduler) run(ctx context.Context) {
+	jobs := make(chan *task)
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				if s.removed(t) {
+					continue
+				}
+
+				delay, keep := s.probe(ctx, t)
+				s.done(t, delay, keep)
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		var due *task
+		wait := time.Hour
+		if len(s.queue) > 0 {
+			if wait = time.Until(s.queue[0].next); wait <= 0 {
+				due = heap.Pop(&s.queue).(*task)
+			}
+		}
+		s.mu.Unlock()
+
+		if due != nil {
+			select {
+			case jobs <- due:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *scheduler) done(t *task, delay time.Duration, keep bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.removed {
+		return
+	}
+
+	if !keep {
+		delete(s.tasks, t.key)
+		return
+	}
+
+	t.next = time.Now().Add(delay + spread(time.Duration(float64(delay)*s.jitter)))
+	heap.Push(&s.queue, t)
+	s.notify()
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// spread returns a random delay in [0, d).
+func spread(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+type taskQueue []*task
+
+func (q taskQueue) Len() int { return len(q) }
+
+func (q taskQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+
+func (q taskQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *taskQueue) Push(x any) {
+	t := x.(*task)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *taskQueue) Pop() any {
+	old := *q
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*q = old[:n-1]
+	return t
+}
@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Run("Deduplicates instances", func(t *testing.T) {
+		s := newScheduler(nil, 1, 0)
+
+		assert.True(t, s.add("orders", "addr1", 0))
+		assert.False(t, s.add("orders", "addr1", 0))
+		assert.True(t, s.add("orders", "addr2", 0))
+		assert.Equal(t, 2, s.len())
+
+		s.remove("orders", "addr1")
+		s.remove("orders", "non-existing-addr")
+		assert.Equal(t, 1, s.len())
+		assert.True(t, s.add("orders", "addr1", 0))
+	})
+
+	t.Run("Reschedules until probe gives up", func(t *testing.T) {
+		var calls atomic.Int32
+		done := make(chan struct{})
+		s := newScheduler(func(ctx context.Context, t *task) (time.Duration, bool) {
+			if calls.Add(1) == 3 {
+				close(done)
+				return 0, false
+			}
+			return time.Millisecond, true
+		}, 2, 50)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.run(ctx)
+
+		s.add("orders", "addr1", 0)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("probe was not rescheduled")
+		}
+
+		assert.Eventually(t, func() bool { return s.len() == 0 }, time.Second, time.Millisecond)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Removed instances are not checked again", func(t *testing.T) {
+		var calls atomic.Int32
+		s := newScheduler(func(ctx context.Context, t *task) (time.Duration, bool) {
+			calls.Add(1)
+			return time.Millisecond, true
+		}, 1, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.run(ctx)
+
+		s.add("orders", "addr1", 0)
+		assert.Eventually(t, func() bool { return calls.Load() > 0 }, time.Second, time.Millisecond)
+
+		s.remove("orders", "addr1")
+		time.Sleep(10 * time.Millisecond)
+		n := calls.Load()
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, n, calls.Load())
+	})
+
+	t.Run("Bounded number of workers", func(t *testing.T) {
+		var mu sync.Mutex
+		var running, peak, calls int
+		s := newScheduler(func(ctx context.Context, t *task) (time.Duration, bool) {
+			mu.Lock()
+			running++
+			calls++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return 0, false
+		}, 3, 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.run(ctx)
+
+		for _, addr := range []string{"addr1", "addr2", "addr3", "addr4", "addr5", "addr6", "addr7", "addr8"} {
+			s.add("orders", addr, 0)
+		}
+
+		assert.Eventually(t, func() bool { return s.len() == 0 }, time.Second, time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 8, calls)
+		assert.LessOrEqual(t, peak, 3)
+	})
+}
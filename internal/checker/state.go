@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"time"
+)
+
+// machine tracks the health state of a single instance.
+// An instance needs Rise consecutive successes to become passing and Fall
+// consecutive failures to become failing, so a single dropped probe does not
+// take it out of rotation. Instances changing state too often within the flap
+// window are quarantined and kept out of rotation until the quarantine is over.
+type machine struct {
+	rise    int
+	fall    int
+	flap    *config.FlapConfig
+	health  md.Health
+	changes []time.Time
+}
+
+func newMachine(conf *config.CheckerConfig, health md.Health) *machine {
+	m := &machine{
+		rise:   max(conf.Rise, 1),
+		fall:   max(conf.Fall, 1),
+		flap:   conf.Flap,
+		health: health,
+	}
+
+	if m.health.State == "" {
+		m.health.State = md.HealthUnknown
+	}
+	return m
+}
+
+// observe records a probe result and reports whether the state has changed.
+// A nil error is a successful probe.
+func (m *machine) observe(err error, now time.Time) bool {
+	prev := m.health.State
+	m.health.LastCheck = now
+	m.health.LastResult = err == nil
+	m.health.LastError = ""
+	if err == nil {
+		m.health.Successes++
+		m.health.Failures = 0
+	} else {
+		m.health.LastError = err.Error()
+		m.health.Failures++
+		m.health.Successes = 0
+	}
+
+	if m.health.State == md.HealthQuarantined {
+		if now.Before(m.health.QuarantinedUntil) {
+			return false
+		}
+
+		m.health.QuarantinedUntil = time.Time{}
+		m.set(md.HealthFailing, "quarantine is over", now)
+	}
+
+	switch {
+	case m.health.State != md.HealthPassing && m.health.Successes >= m.rise:
+		m.set(md.HealthPassing, fmt.Sprintf("%d consecutive successful checks", m.health.Successes), now)
+	case m.health.State != md.HealthFailing && m.health.Failures >= m.fall:
+		m.set(md.HealthFailing, fmt.Sprintf("%d consecutive failed checks", m.health.Failures), now)
+	}
+
+	// Only toggles between passing and failing count towards flapping
+	if (prev == md.HealthPassing || prev == md.HealthFailing) && m.health.State != prev && m.flapping(now) {
+		m.health.QuarantinedUntil = now.Add(time.Duration(m.flap.Quarantine) * time.Second)
+		m.set(
+			md.HealthQuarantined,
+			fmt.Sprintf("flapping: %d state changes within %ds", m.flap.Threshold, m.flap.Window),
+			now,
+		)
+	}
+
+	return m.health.State != prev
+}
+
+func (m *machine) flapping(now time.Time) bool {
+	if m.flap == nil || m.flap.Threshold <= 0 {
+		return false
+	}
+
+	window := now.Add(-time.Duration(m.flap.Window) * time.Second)
+	changes := m.changes[:0]
+	for _, t := range m.changes {
+		if t.After(window) {
+			changes = append(changes, t)
+		}
+	}
+	m.changes = append(changes, now)
+
+	if len(m.changes) < m.flap.Threshold {
+		return false
+	}
+
+	m.changes = m.changes[:0]
+	return true
+}
+
+func (m *machine) set(state md.HealthState, reason string, now time.Time) {
+	m.health.State = state
+	m.health.Reason = reason
+	m.health.Since = now
+}
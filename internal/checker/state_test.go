@@ -0,0 +1,98 @@
+package checker
+
+import (
+	"errors"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMachine(t *testing.T) {
+	ok, failed := error(nil), errors.New("connection refused")
+	now := time.Now()
+	tick := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	t.Run("Rise and fall thresholds", func(t *testing.T) {
+		m := newMachine(&config.CheckerConfig{Rise: 2, Fall: 3}, md.Health{})
+		assert.Equal(t, md.HealthUnknown, m.health.State)
+
+		assert.False(t, m.observe(ok, tick()))
+		assert.True(t, m.observe(ok, tick()))
+		assert.Equal(t, md.HealthPassing, m.health.State)
+		assert.Equal(t, "2 consecutive successful checks", m.health.Reason)
+		assert.Equal(t, now, m.health.Since)
+
+		// A single dropped probe keeps the instance in rotation
+		assert.False(t, m.observe(failed, tick()))
+		assert.False(t, m.observe(ok, tick()))
+		assert.False(t, m.observe(failed, tick()))
+		assert.False(t, m.observe(failed, tick()))
+		assert.True(t, m.observe(failed, tick()))
+		assert.Equal(t, md.HealthFailing, m.health.State)
+		assert.Equal(t, 3, m.health.Failures)
+		assert.Equal(t, 0, m.health.Successes)
+		assert.Equal(t, now, m.health.LastCheck)
+		assert.False(t, m.health.LastResult)
+		assert.Equal(t, "connection refused", m.health.LastError)
+
+		m.observe(ok, tick())
+		assert.True(t, m.health.LastResult)
+		assert.Empty(t, m.health.LastError)
+	})
+
+	t.Run("Defaults switch on the first probe", func(t *testing.T) {
+		m := newMachine(&config.CheckerConfig{}, md.Health{State: md.HealthPassing})
+
+		assert.True(t, m.observe(failed, tick()))
+		assert.Equal(t, md.HealthFailing, m.health.State)
+		assert.True(t, m.observe(ok, tick()))
+		assert.Equal(t, md.HealthPassing, m.health.State)
+	})
+
+	t.Run("Flapping instance is quarantined", func(t *testing.T) {
+		m := newMachine(&config.CheckerConfig{
+			Flap: &config.FlapConfig{Threshold: 3, Window: 60, Quarantine: 30},
+		}, md.Health{})
+
+		assert.True(t, m.observe(ok, tick()))
+		assert.True(t, m.observe(failed, tick()))
+		assert.True(t, m.observe(ok, tick()))
+		assert.Equal(t, md.HealthPassing, m.health.State)
+
+		assert.True(t, m.observe(failed, tick()))
+		assert.Equal(t, md.HealthQuarantined, m.health.State)
+		assert.Equal(t, "flapping: 3 state changes within 60s", m.health.Reason)
+		assert.Equal(t, now.Add(30*time.Second), m.health.QuarantinedUntil)
+
+		// Results keep being counted but do not leave the quarantine
+		assert.False(t, m.observe(ok, tick()))
+		assert.Equal(t, md.HealthQuarantined, m.health.State)
+		assert.Equal(t, 1, m.health.Successes)
+
+		now = now.Add(30 * time.Second)
+		assert.True(t, m.observe(ok, now))
+		assert.Equal(t, md.HealthPassing, m.health.State)
+		assert.True(t, m.health.QuarantinedUntil.IsZero())
+	})
+
+	t.Run("Changes outside of the window are not flapping", func(t *testing.T) {
+		m := newMachine(&config.CheckerConfig{
+			Flap: &config.FlapConfig{Threshold: 3, Window: 10, Quarantine: 30},
+		}, md.Health{})
+
+		for i := 0; i < 5; i++ {
+			now = now.Add(10 * time.Second)
+			res := failed
+			if i%2 == 0 {
+				res = ok
+			}
+			assert.True(t, m.observe(res, now))
+			assert.NotEqual(t, md.HealthQuarantined, m.health.State)
+		}
+	})
+}
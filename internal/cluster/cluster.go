@@ -0,0 +1,417 @@
+// Package cluster replicates the registry between discovery nodes with Raft.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/goccy/go-json"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultApplyTimeout = 5 * time.Second
+	defaultRetain       = 2
+	transportTimeout    = 10 * time.Second
+	transportMaxPool    = 3
+
+	// appliedPollInterval is how often a follower checks whether it caught up with a forwarded write
+	appliedPollInterval = 5 * time.Millisecond
+	// leaderPollInterval is how often a follower checks whether a leader was elected
+	leaderPollInterval = 10 * time.Millisecond
+)
+
+// Local is the repository a node applies the replicated commands to.
+// Timestamps are taken from the commands rather than the clock of the node.
+type Local interface {
+	ctrl.ServiceDiscoveryRepo
+	RegisterAt(ctx context.Context, svc *md.Service, at time.Time) error
+	HeartbeatAt(ctx context.Context, name, addr string, at time.Time) error
+}
+
+// Repository replicates the mutations of a local repository through the Raft log.
+// The leader applies writes, followers forward them to it. Reads are served
+// by the local repository, so they may lag behind the leader on followers,
+// except for writes made through the same node.
+type Repository struct {
+	id        string
+	local     Local
+	fsm       *fsm
+	raft      *raft.Raft
+	transport *raft.NetworkTransport
+	store     *raftboltdb.BoltStore
+	timeout   time.Duration
+}
+
+func New(conf *config.ClusterConfig, local Local, events *broker.Broker) (*Repository, error) {
+	ln, err := net.Listen("tcp", conf.BindAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newRepository(conf, ln, raft.DefaultConfig(), local, events)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func newRepository(conf *config.ClusterConfig, ln net.Listener, rc *raft.Config, local Local, events *broker.Broker) (*Repository, error) {
+	if conf.NodeID == "" {
+		return nil, ErrMissingNodeID
+	}
+
+	advertise := ln.Addr()
+	if conf.AdvertiseAddr != "" {
+		addr, err := net.ResolveTCPAddr("tcp", conf.AdvertiseAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid advertise address: %w", err)
+		}
+		advertise = addr
+	}
+
+	if err := os.MkdirAll(conf.DataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the raft directory: %w", err)
+	}
+
+	logger := hclog.New(&hclog.LoggerOptions{Name: "raft", Level: hclog.Warn})
+	retain := conf.RetainSnapshots
+	if retain <= 0 {
+		retain = defaultRetain
+	}
+	snaps, err := raft.NewFileSnapshotStoreWithLogger(conf.DataDir, retain, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the snapshot store: %w", err)
+	}
+
+	store, err := raftboltdb.NewBoltStore(filepath.Join(conf.DataDir, "raft.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the raft log: %w", err)
+	}
+
+	r := &Repository{
+		id:      conf.NodeID,
+		local:   local,
+		store:   store,
+		timeout: defaultApplyTimeout,
+	}
+	if conf.ApplyTimeout > 0 {
+		r.timeout = time.Duration(conf.ApplyTimeout) * time.Second
+	}
+
+	r.transport = raft.NewNetworkTransportWithConfig(&raft.NetworkTransportConfig{
+		Stream:  newMux(ln, advertise, r.serveForward),
+		MaxPool: transportMaxPool,
+		Timeout: transportTimeout,
+		Logger:  logger,
+	})
+
+	rc.LocalID = raft.ServerID(conf.NodeID)
+	rc.Logger = logger
+	if conf.SnapshotInterval > 0 {
+		rc.SnapshotInterval = time.Duration(conf.SnapshotInterval) * time.Second
+	}
+	if conf.SnapshotThreshold > 0 {
+		rc.SnapshotThreshold = conf.SnapshotThreshold
+	}
+
+	r.fsm = &fsm{id: conf.NodeID, local: local, events: events}
+	if r.raft, err = raft.NewRaft(rc, r.fsm, store, store, snaps, r.transport); err != nil {
+		r.transport.Close()
+		store.Close()
+		return nil, fmt.Errorf("failed to start raft: %w", err)
+	}
+
+	if conf.Bootstrap {
+		if err = r.bootstrap(conf, advertise, snaps); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// bootstrap forms a new cluster out of the configured peers. It is a no-op
+// once the node has state, so every node can be started with the same peers.
+func (r *Repository) bootstrap(conf *config.ClusterConfig, advertise net.Addr, snaps raft.SnapshotStore) error {
+	hasState, err := raft.HasExistingState(r.store, r.store, snaps)
+	if err != nil || hasState {
+		return err
+	}
+
+	servers := []raft.Server{{ID: raft.ServerID(conf.NodeID), Address: raft.ServerAddress(advertise.String())}}
+	for _, peer := range conf.Peers {
+		if peer.ID != conf.NodeID {
+			servers = append(servers, raft.Server{ID: raft.ServerID(peer.ID), Address: raft.ServerAddress(peer.Addr)})
+		}
+	}
+
+	err = r.raft.BootstrapCluster(raft.Configuration{Servers: servers}).Error()
+	if err != nil && !errors.Is(err, raft.ErrCantBootstrap) {
+		return fmt.Errorf("failed to bootstrap the cluster: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) Close() error {
+	return errors.Join(
+		r.raft.Shutdown().Error(),
+		r.transport.Close(),
+		r.store.Close(),
+		r.local.Close(),
+	)
+}
+
+// IsLeader reports whether this node currently applies the writes of the cluster.
+func (r *Repository) IsLeader() bool {
+	return r.raft.State() == raft.Leader
+}
+
+// Campaign follows the leadership of the node, so singleton jobs run on the Raft leader.
+// It sends the current state first, then every change, until ctx is done.
+// The leadership channel of Raft has a single consumer, Campaign is called once.
+func (r *Repository) Campaign(ctx context.Context) <-chan bool {
+	ch := make(chan bool, 1)
+	leading := r.IsLeader()
+	ch <- leading
+
+	go func() {
+		defer close(ch)
+		changes := r.raft.LeaderCh()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case isLeader := <-changes:
+				if isLeader == leading {
+					continue
+				}
+				leading = isLeader
+				select {
+				case ch <- leading:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+func (r *Repository) Register(ctx context.Context, svc *md.Service) error {
+	at := time.Now()
+	return r.apply(ctx, &command{Op: opRegister, Service: svc, At: &at})
+}
+
+func (r *Repository) Deregister(ctx context.Context, name, addr string) error {
+	return r.apply(ctx, &command{Op: opDeregister, Name: name, Address: addr})
+}
+
+func (r *Repository) Heartbeat(ctx context.Context, name, addr string) error {
+	at := time.Now()
+	return r.apply(ctx, &command{Op: opHeartbeat, Name: name, Address: addr, At: &at})
+}
+
+func (r *Repository) UpdateWeight(ctx context.Context, name, addr string, weight int) error {
+	return r.apply(ctx, &command{Op: opUpdateWeight, Name: name, Address: addr, Weight: weight})
+}
+
+func (r *Repository) SetHealth(ctx context.Context, name, addr string, health md.Health) error {
+	return r.apply(ctx, &command{Op: opSetHealth, Name: name, Address: addr, Health: &health})
+}
+
+func (r *Repository) ActivateSvc(ctx context.Context, name, addr string) error {
+	return r.apply(ctx, &command{Op: opActivate, Name: name, Address: addr})
+}
+
+func (r *Repository) DeactivateSvc(ctx context.Context, name, addr string) error {
+	return r.apply(ctx, &command{Op: opDeactivate, Name: name, Address: addr})
+}
+
+func (r *Repository) Restore(ctx context.Context, svcs []md.Service, replace bool) error {
+	return r.apply(ctx, &command{Op: opRestore, Services: svcs, Replace: replace})
+}
+
+func (r *Repository) GetService(ctx context.Context, name, addr string) (*md.Service, error) {
+	return r.local.GetService(ctx, name, addr)
+}
+
+func (r *Repository) FindServiceByName(ctx context.Context, name string, sel md.Selector, key string) (string, error) {
+	return r.local.FindServiceByName(ctx, name, sel, key)
+}
+
+func (r *Repository) ListServices(ctx context.Context) ([]string, error) {
+	return r.local.ListServices(ctx)
+}
+
+func (r *Repository) ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error) {
+	return r.local.ListAddrs(ctx, name, sel)
+}
+
+func (r *Repository) ListInstances(ctx context.Context) ([]md.Service, error) {
+	return r.local.ListInstances(ctx)
+}
+
+func (r *Repository) apply(ctx context.Context, cmd *command) error {
+	cmd.Origin = r.id
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	if r.IsLeader() {
+		// Leadership may move between the check and the apply, forward to the next leader then
+		if _, err = r.applyLocal(data); !leadershipChanged(err) {
+			return err
+		}
+		return r.forward(ctx, data, raft.ServerID(r.id))
+	}
+
+	return r.forward(ctx, data, "")
+}
+
+// leadershipChanged reports whether err means the node stopped leading before
+// it committed the command, or is handing leadership over.
+func leadershipChanged(err error) bool {
+	return errors.Is(err, raft.ErrNotLeader) ||
+		errors.Is(err, raft.ErrLeadershipLost) ||
+		errors.Is(err, raft.ErrLeadershipTransferInProgress)
+}
+
+// applyLocal returns the log index of the command along with the error of the mutation.
+func (r *Repository) applyLocal(data []byte) (uint64, error) {
+	f := r.raft.Apply(data, r.timeout)
+	if err := f.Error(); err != nil {
+		return 0, err
+	}
+
+	if err, ok := f.Response().(error); ok {
+		return f.Index(), err
+	}
+	return f.Index(), nil
+}
+
+// forward sends the command to the leader other than stale. A leader refusing
+// it is stepping down, the command is sent once more to the next one.
+func (r *Repository) forward(ctx context.Context, data []byte, stale raft.ServerID) error {
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	for retried := false; ; retried = true {
+		addr, id, err := r.leader(ctx, deadline, stale)
+		if err != nil {
+			return err
+		}
+
+		index, err := r.send(addr, id, data, deadline)
+		if errors.Is(err, ErrNoLeader) && !retried {
+			stale = id
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		r.waitApplied(ctx, index, deadline)
+		return nil
+	}
+}
+
+// send applies the command on the leader and returns its log index.
+func (r *Repository) send(addr raft.ServerAddress, id raft.ServerID, data []byte, deadline time.Time) (uint64, error) {
+	conn, err := dial(string(addr), forwardConn, r.timeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach leader %v: %w", id, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	if err = json.NewEncoder(conn).Encode(json.RawMessage(data)); err != nil {
+		return 0, fmt.Errorf("failed to forward to leader %v: %w", id, err)
+	}
+
+	var res result
+	if err = json.NewDecoder(conn).Decode(&res); err != nil {
+		return 0, fmt.Errorf("failed to forward to leader %v: %w", id, err)
+	}
+	return res.Index, res.err()
+}
+
+// leader waits for a leader other than stale to be known, writes made during
+// an election wait for its outcome rather than failing.
+func (r *Repository) leader(ctx context.Context, deadline time.Time, stale raft.ServerID) (raft.ServerAddress, raft.ServerID, error) {
+	ticker := time.NewTicker(leaderPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if addr, id := r.raft.LeaderWithID(); addr != "" && id != stale {
+			return addr, id, nil
+		}
+		if !time.Now().Before(deadline) {
+			return "", "", ErrNoLeader
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", "", ErrNoLeader
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitApplied lets reads on this node observe a write it forwarded.
+// The write is committed already, so running out of time is not an error.
+func (r *Repository) waitApplied(ctx context.Context, index uint64, deadline time.Time) {
+	ticker := time.NewTicker(appliedPollInterval)
+	defer ticker.Stop()
+
+	for r.fsm.applied.Load() < index {
+		if time.Now().After(deadline) {
+			zap.L().Debug("forwarded write not applied locally yet", zap.Uint64("index", index))
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// serveForward applies a command forwarded by a follower.
+func (r *Repository) serveForward(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * r.timeout))
+
+	var cmd json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&cmd); err != nil {
+		zap.L().Debug("failed to decode forwarded command", zap.Error(err))
+		return
+	}
+
+	var res *result
+	if !r.IsLeader() {
+		res = resultOf(0, raft.ErrNotLeader)
+	} else {
+		res = resultOf(r.applyLocal(cmd))
+	}
+
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		zap.L().Debug("failed to answer forwarded command", zap.Error(err))
+	}
+}
@@ -0,0 +1,350 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/repo/memory"
+	"github.com/JMURv/service-discovery/internal/repo/repotest"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/goccy/go-json"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type node struct {
+	*Repository
+	conf   *config.ClusterConfig
+	events *broker.Broker
+}
+
+func fastRaft() *raft.Config {
+	rc := raft.DefaultConfig()
+	rc.HeartbeatTimeout = 100 * time.Millisecond
+	rc.ElectionTimeout = 100 * time.Millisecond
+	rc.LeaderLeaseTimeout = 100 * time.Millisecond
+	rc.CommitTimeout = 5 * time.Millisecond
+	return rc
+}
+
+func start(t *testing.T, conf *config.ClusterConfig, ln net.Listener) *node {
+	if ln == nil {
+		var err error
+		ln, err = net.Listen("tcp", conf.BindAddr)
+		require.NoError(t, err)
+	}
+
+	events := broker.New()
+	r, err := newRepository(conf, ln, fastRaft(), memory.New(repotest.Balancer()), events)
+	require.NoError(t, err)
+
+	return &node{Repository: r, conf: conf, events: events}
+}
+
+// startCluster runs n nodes on loopback, all bootstrapped with the same peers.
+func startCluster(t *testing.T, n int) []*node {
+	dir := t.TempDir()
+
+	lns := make([]net.Listener, n)
+	peers := make([]config.PeerConfig, n)
+	for i := range lns {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		lns[i] = ln
+		peers[i] = config.PeerConfig{ID: fmt.Sprintf("node%d", i), Addr: ln.Addr().String()}
+	}
+
+	nodes := make([]*node, n)
+	for i := range nodes {
+		nodes[i] = start(t, &config.ClusterConfig{
+			NodeID:    peers[i].ID,
+			BindAddr:  peers[i].Addr,
+			DataDir:   filepath.Join(dir, peers[i].ID),
+			Bootstrap: true,
+			Peers:     peers,
+		}, lns[i])
+	}
+
+	t.Cleanup(func() {
+		for _, n := range nodes {
+			n.Close()
+		}
+	})
+	return nodes
+}
+
+func waitLeader(t *testing.T, nodes []*node) (*node, []*node) {
+	var leader *node
+	require.Eventually(t, func() bool {
+		for _, n := range nodes {
+			if n.IsLeader() {
+				leader = n
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var followers []*node
+	for _, n := range nodes {
+		if n != leader {
+			followers = append(followers, n)
+		}
+	}
+	return leader, followers
+}
+
+func replicated(t *testing.T, nodes []*node, name, addr string) {
+	for _, n := range nodes {
+		assert.Eventually(t, func() bool {
+			_, err := n.GetService(context.Background(), name, addr)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond, "not replicated to %v", n.id)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+	nodes := startCluster(t, 3)
+	leader, followers := waitLeader(t, nodes)
+
+	t.Run("Writes are forwarded to the leader", func(t *testing.T) {
+		assert.NoError(t, followers[0].Register(ctx, &md.Service{Name: "orders", Address: "addr1", Weight: 1, Tags: []string{"canary"}}))
+
+		// Read your writes on the node the write was made on
+		svc, err := followers[0].GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"canary"}, svc.Tags)
+
+		replicated(t, nodes, "orders", "addr1")
+	})
+
+	t.Run("Repository errors survive forwarding", func(t *testing.T) {
+		assert.Equal(t, repo.ErrAlreadyExists, followers[1].Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.Equal(t, repo.ErrAlreadyExists, leader.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.Equal(t, repo.ErrNotFound, followers[1].Deregister(ctx, "orders", "addr2"))
+		assert.Equal(t, repo.ErrNotFound, followers[1].ActivateSvc(ctx, "orders", "addr2"))
+	})
+
+	t.Run("Timestamps are taken from the node the write was made on", func(t *testing.T) {
+		assert.NoError(t, followers[1].Heartbeat(ctx, "orders", "addr1"))
+		svc, err := followers[1].GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+
+		for _, n := range nodes {
+			assert.Eventually(t, func() bool {
+				replica, err := n.GetService(ctx, "orders", "addr1")
+				return err == nil && replica.LastHeartbeat.Equal(svc.LastHeartbeat)
+			}, 5*time.Second, 10*time.Millisecond, "heartbeat differs on %v", n.id)
+		}
+	})
+
+	t.Run("Every mutation is replicated", func(t *testing.T) {
+		health := md.Health{State: md.HealthFailing, Failures: 2}
+		assert.NoError(t, followers[1].DeactivateSvc(ctx, "orders", "addr1"))
+		assert.NoError(t, followers[1].UpdateWeight(ctx, "orders", "addr1", 3))
+		assert.NoError(t, leader.SetHealth(ctx, "orders", "addr1", health))
+
+		for _, n := range nodes {
+			assert.Eventually(t, func() bool {
+				svc, err := n.GetService(ctx, "orders", "addr1")
+				return err == nil && !svc.IsActive && svc.Weight == 3 && svc.Health == health
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+
+		assert.NoError(t, followers[0].Deregister(ctx, "orders", "addr1"))
+		for _, n := range nodes {
+			assert.Eventually(t, func() bool {
+				_, err := n.GetService(ctx, "orders", "addr1")
+				return err == repo.ErrNotFound
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+	})
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	at := time.Now().Add(-time.Hour)
+	logs := make([]*raft.Log, 0, 2)
+	for i, cmd := range []command{
+		{Op: opRegister, Service: &md.Service{Name: "orders", Address: "addr1", TTL: 10}, At: &at},
+		{Op: opHeartbeat, Name: "orders", Address: "addr1"},
+	} {
+		data, err := json.Marshal(cmd)
+		assert.NoError(t, err)
+		logs = append(logs, &raft.Log{Index: uint64(i + 1), Data: data, AppendedAt: at.Add(time.Minute)})
+	}
+
+	// Test case 1: Replaying the log restores the timestamps of the commands
+	f := &fsm{id: "node1", local: memory.New(repotest.Balancer())}
+	assert.Nil(t, f.Apply(logs[0]))
+	svc, err := f.local.GetService(ctx, "orders", "addr1")
+	assert.NoError(t, err)
+	assert.True(t, at.Equal(svc.LastHeartbeat))
+
+	// Test case 2: Commands without a timestamp get the time they were appended
+	assert.Nil(t, f.Apply(logs[1]))
+	svc, err = f.local.GetService(ctx, "orders", "addr1")
+	assert.NoError(t, err)
+	assert.True(t, at.Add(time.Minute).Equal(svc.LastHeartbeat))
+	assert.Equal(t, uint64(2), f.applied.Load())
+}
+
+func TestEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodes := startCluster(t, 2)
+	waitLeader(t, nodes)
+
+	origin := nodes[0].events.Subscribe(ctx, "orders")
+	remote := nodes[1].events.Subscribe(ctx, "orders")
+
+	assert.NoError(t, nodes[0].Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+
+	select {
+	case evt := <-remote:
+		assert.Equal(t, md.Registered, evt.Type)
+		assert.Equal(t, "addr1", evt.Address)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not published on the remote node")
+	}
+
+	// The controller of the origin node publishes its own events
+	select {
+	case evt := <-origin:
+		t.Fatalf("unexpected event on the origin node: %v", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// A replacing restore deregisters the instances missing from it
+	assert.NoError(t, nodes[0].Restore(ctx, []md.Service{{Name: "orders", Address: "addr2", IsActive: true}}, true))
+	for _, expected := range []md.ServiceEvent{{Type: md.Deregistered, Address: "addr1"}, {Type: md.Registered, Address: "addr2"}} {
+		select {
+		case evt := <-remote:
+			assert.Equal(t, expected.Type, evt.Type)
+			assert.Equal(t, expected.Address, evt.Address)
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not published on the remote node")
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	nodes := startCluster(t, 3)
+	leader, followers := waitLeader(t, nodes)
+
+	assert.NoError(t, leader.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+	replicated(t, nodes, "orders", "addr1")
+
+	assert.NoError(t, leader.Close())
+	_, _ = waitLeader(t, followers)
+
+	assert.NoError(t, followers[0].Register(ctx, &md.Service{Name: "orders", Address: "addr2"}))
+	replicated(t, followers, "orders", "addr2")
+
+	_, err := followers[1].GetService(ctx, "orders", "addr1")
+	assert.NoError(t, err)
+}
+
+func TestLeadershipTransfer(t *testing.T) {
+	ctx := context.Background()
+	nodes := startCluster(t, 3)
+	leader, _ := waitLeader(t, nodes)
+
+	// Writes refused by a leader handing over go to the next one
+	transfer := leader.raft.LeadershipTransfer()
+	assert.NoError(t, leader.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+	assert.NoError(t, transfer.Error())
+	assert.False(t, leader.IsLeader())
+	replicated(t, nodes, "orders", "addr1")
+}
+
+func TestSnapshotAndRestart(t *testing.T) {
+	ctx := context.Background()
+	nodes := startCluster(t, 3)
+	leader, followers := waitLeader(t, nodes)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, leader.Register(ctx, &md.Service{Name: "orders", Address: fmt.Sprintf("addr%d", i)}))
+	}
+	assert.NoError(t, leader.DeactivateSvc(ctx, "orders", "addr0"))
+	replicated(t, nodes, "orders", "addr4")
+
+	restarted := followers[0]
+	assert.NoError(t, restarted.raft.Snapshot().Error())
+	assert.NoError(t, restarted.Close())
+
+	// Written after the snapshot, replayed from the log of the leader
+	assert.NoError(t, leader.Register(ctx, &md.Service{Name: "orders", Address: "addr5"}))
+
+	n := start(t, restarted.conf, nil)
+	defer n.Close()
+
+	for _, addr := range []string{"addr0", "addr4", "addr5"} {
+		replicated(t, []*node{n}, "orders", addr)
+	}
+	svc, err := n.GetService(ctx, "orders", "addr0")
+	assert.NoError(t, err)
+	assert.False(t, svc.IsActive)
+}
+
+func TestNoLeader(t *testing.T) {
+	dir := t.TempDir()
+	n := start(t, &config.ClusterConfig{NodeID: "node0", BindAddr: "127.0.0.1:0", DataDir: dir}, nil)
+	defer n.Close()
+
+	// Writes wait for an election until their deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, ErrNoLeader, n.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+
+	_, err := newRepository(&config.ClusterConfig{DataDir: dir}, nil, fastRaft(), nil, nil)
+	assert.Equal(t, ErrMissingNodeID, err)
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) ctrl.ServiceDiscoveryRepo {
+		nodes := startCluster(t, 1)
+		waitLeader(t, nodes)
+		return nodes[0]
+	})
+}
+
+func TestCampaign(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodes := startCluster(t, 3)
+	leader, followers := waitLeader(t, nodes)
+
+	campaigns := make(map[*node]<-chan bool)
+	for _, n := range nodes {
+		campaigns[n] = n.Campaign(ctx)
+	}
+	assert.True(t, <-campaigns[leader])
+	for _, n := range followers {
+		assert.False(t, <-campaigns[n])
+	}
+
+	// The leader steps down on shutdown, one of the followers takes over
+	assert.NoError(t, leader.Close())
+	assert.False(t, <-campaigns[leader])
+
+	next, _ := waitLeader(t, followers)
+	select {
+	case leading := <-campaigns[next]:
+		assert.True(t, leading)
+	case <-time.After(5 * time.Second):
+		t.Fatal("leadership not reported")
+	}
+}
@@ -0,0 +1,6 @@
+package cluster
+
+import "errors"
+
+var ErrNoLeader = errors.New("no cluster leader")
+var ErrMissingNodeID = errors.New("missing cluster node id")
@@ -0,0 +1,192 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/broker"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/goccy/go-json"
+	"github.com/hashicorp/raft"
+	"go.uber.org/zap"
+	"io"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+type op string
+
+const (
+	opRegister     op = "register"
+	opDeregister   op = "deregister"
+	opHeartbeat    op = "heartbeat"
+	opUpdateWeight op = "update_weight"
+	opSetHealth    op = "set_health"
+	opActivate     op = "activate"
+	opDeactivate   op = "deactivate"
+	opRestore      op = "restore"
+)
+
+// command is a registry mutation as stored in the Raft log.
+// Origin is the node the mutation was made on, At is when it was made,
+// it is set on the mutations storing a timestamp.
+type command struct {
+	Op       op           `json:"op"`
+	Origin   string       `json:"origin"`
+	At       *time.Time   `json:"at,omitempty"`
+	Name     string       `json:"name,omitempty"`
+	Address  string       `json:"address,omitempty"`
+	Service  *md.Service  `json:"service,omitempty"`
+	Weight   int          `json:"weight,omitempty"`
+	Health   *md.Health   `json:"health,omitempty"`
+	Services []md.Service `json:"services,omitempty"`
+	Replace  bool         `json:"replace,omitempty"`
+}
+
+// fsm applies committed commands to the local repository.
+// The node a mutation was made on publishes its events through the controller,
+// every other node publishes them here, so watchers see the same stream on every node.
+type fsm struct {
+	id     string
+	local  Local
+	events *broker.Broker
+	// applied is the index of the last command applied to local,
+	// raft.AppliedIndex moves before the FSM is done with it
+	applied atomic.Uint64
+}
+
+// Apply returns the error of the mutation, nil on success.
+// It never reads the clock, so every replica and every replay of the log
+// ends up with the same registry.
+func (f *fsm) Apply(l *raft.Log) any {
+	defer f.applied.Store(l.Index)
+
+	var cmd command
+	if err := json.Unmarshal(l.Data, &cmd); err != nil {
+		return fmt.Errorf("failed to decode command: %w", err)
+	}
+
+	// Commands without a timestamp get the time the leader appended them
+	at := l.AppendedAt
+	if cmd.At != nil {
+		at = *cmd.At
+	}
+
+	publish := cmd.Origin != f.id && f.events != nil
+	var removed []md.Service
+	if publish && cmd.Op == opRestore && cmd.Replace {
+		removed = f.removedBy(&cmd)
+	}
+
+	err := f.apply(&cmd, at)
+	if err == nil && publish {
+		f.publish(&cmd, removed)
+	}
+	return err
+}
+
+// removedBy lists the instances a replacing restore deregisters.
+// A failed listing only costs their events, the restore is applied regardless.
+func (f *fsm) removedBy(cmd *command) []md.Service {
+	svcs, err := f.local.ListInstances(context.Background())
+	if err != nil {
+		zap.L().Warn("failed to list instances before restore", zap.Error(err))
+		return nil
+	}
+
+	return slices.DeleteFunc(svcs, func(svc md.Service) bool {
+		return slices.ContainsFunc(cmd.Services, func(restored md.Service) bool {
+			return restored.Name == svc.Name && restored.Address == svc.Address
+		})
+	})
+}
+
+func (f *fsm) apply(cmd *command, at time.Time) error {
+	ctx := context.Background()
+	switch cmd.Op {
+	case opRegister:
+		if cmd.Service == nil {
+			return errors.New("register without service")
+		}
+		return f.local.RegisterAt(ctx, cmd.Service, at)
+	case opDeregister:
+		return f.local.Deregister(ctx, cmd.Name, cmd.Address)
+	case opHeartbeat:
+		return f.local.HeartbeatAt(ctx, cmd.Name, cmd.Address, at)
+	case opUpdateWeight:
+		return f.local.UpdateWeight(ctx, cmd.Name, cmd.Address, cmd.Weight)
+	case opSetHealth:
+		if cmd.Health == nil {
+			return errors.New("set health without health")
+		}
+		return f.local.SetHealth(ctx, cmd.Name, cmd.Address, *cmd.Health)
+	case opActivate:
+		return f.local.ActivateSvc(ctx, cmd.Name, cmd.Address)
+	case opDeactivate:
+		return f.local.DeactivateSvc(ctx, cmd.Name, cmd.Address)
+	case opRestore:
+		return f.local.Restore(ctx, cmd.Services, cmd.Replace)
+	default:
+		return fmt.Errorf("unknown command %v", cmd.Op)
+	}
+}
+
+// publish sends the events of cmd, removed are the instances a replacing restore deregistered.
+func (f *fsm) publish(cmd *command, removed []md.Service) {
+	switch cmd.Op {
+	case opRegister:
+		f.events.Publish(md.Registered, cmd.Service.Name, cmd.Service.Address)
+	case opDeregister:
+		f.events.Publish(md.Deregistered, cmd.Name, cmd.Address)
+	case opActivate:
+		f.events.Publish(md.Activated, cmd.Name, cmd.Address)
+	case opDeactivate:
+		f.events.Publish(md.Deactivated, cmd.Name, cmd.Address)
+	case opRestore:
+		for _, svc := range removed {
+			f.events.Publish(md.Deregistered, svc.Name, svc.Address)
+		}
+		for _, svc := range cmd.Services {
+			f.events.Publish(md.Registered, svc.Name, svc.Address)
+		}
+	}
+}
+
+// Snapshot copies the registry right away, Persist may run concurrently with Apply.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	svcs, err := f.local.ListInstances(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &fsmSnapshot{svcs: svcs}, nil
+}
+
+// Restore replaces the registry, instances are kept exactly as they were
+// including their last heartbeat.
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	var svcs []md.Service
+	if err := json.NewDecoder(rc).Decode(&svcs); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return f.local.Restore(context.Background(), svcs, true)
+}
+
+type fsmSnapshot struct {
+	svcs []md.Service
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(s.svcs); err != nil {
+		sink.Cancel()
+		return err
+	}
+
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {}
@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"errors"
+	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/hashicorp/raft"
+	"go.uber.org/zap"
+	"net"
+	"sync"
+	"time"
+)
+
+// The first byte of every connection tells Raft traffic from forwarded writes,
+// so both share the bind address.
+const (
+	raftConn    byte = 1
+	forwardConn byte = 2
+)
+
+// handshakeTimeout bounds how long an accepted connection may take to send its type
+const handshakeTimeout = 5 * time.Second
+
+// mux is the raft.StreamLayer of a node, it hands forwarded writes to forward.
+type mux struct {
+	ln        net.Listener
+	advertise net.Addr
+	forward   func(net.Conn)
+
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newMux(ln net.Listener, advertise net.Addr, forward func(net.Conn)) *mux {
+	m := &mux{
+		ln:        ln,
+		advertise: advertise,
+		forward:   forward,
+		conns:     make(chan net.Conn),
+		closed:    make(chan struct{}),
+	}
+	go m.serve()
+	return m
+}
+
+func (m *mux) serve() {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			select {
+			case <-m.closed:
+			default:
+				zap.L().Error("failed to accept cluster connection", zap.Error(err))
+			}
+			return
+		}
+		go m.route(conn)
+	}
+}
+
+func (m *mux) route(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	typ := make([]byte, 1)
+	if _, err := conn.Read(typ); err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	switch typ[0] {
+	case raftConn:
+		select {
+		case m.conns <- conn:
+		case <-m.closed:
+			conn.Close()
+		}
+	case forwardConn:
+		m.forward(conn)
+	default:
+		zap.L().Debug("unknown cluster connection type", zap.Uint8("type", typ[0]))
+		conn.Close()
+	}
+}
+
+func (m *mux) Accept() (net.Conn, error) {
+	select {
+	case conn := <-m.conns:
+		return conn, nil
+	case <-m.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (m *mux) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		err = m.ln.Close()
+	})
+	return err
+}
+
+func (m *mux) Addr() net.Addr {
+	return m.advertise
+}
+
+func (m *mux) Dial(addr raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	return dial(string(addr), raftConn, timeout)
+}
+
+func dial(addr string, typ byte, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = conn.Write([]byte{typ}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// result is the answer of the leader to a forwarded command.
+// Index is the log index of the command, so the follower can wait until it applied it.
+type result struct {
+	Index uint64 `json:"index"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	codeNotFound      = "not_found"
+	codeAlreadyExists = "already_exists"
+	codeNotLeader     = "not_leader"
+)
+
+// Repository errors are sent as codes, so errors.Is keeps working on the follower.
+func resultOf(index uint64, err error) *result {
+	res := &result{Index: index}
+	if err == nil {
+		return res
+	}
+
+	res.Error = err.Error()
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		res.Code = codeNotFound
+	case errors.Is(err, repo.ErrAlreadyExists):
+		res.Code = codeAlreadyExists
+	case leadershipChanged(err):
+		res.Code = codeNotLeader
+	}
+	return res
+}
+
+func (r *result) err() error {
+	switch r.Code {
+	case codeNotFound:
+		return repo.ErrNotFound
+	case codeAlreadyExists:
+		return repo.ErrAlreadyExists
+	case codeNotLeader:
+		return ErrNoLeader
+	}
+
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	return nil
+}
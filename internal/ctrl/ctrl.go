@@ -3,17 +3,28 @@ package ctrl
 import (
 	"context"
 	"errors"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/metrics"
 	"github.com/JMURv/service-discovery/internal/repo"
 	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"slices"
+	"time"
 )
 
 type ServiceDiscoveryRepo interface {
-	Register(ctx context.Context, name, addr string) error
+	Register(ctx context.Context, svc *md.Service) error
 	Deregister(ctx context.Context, name, addr string) error
-	FindServiceByName(ctx context.Context, name string) (string, error)
+	Heartbeat(ctx context.Context, name, addr string) error
+	GetService(ctx context.Context, name, addr string) (*md.Service, error)
+	FindServiceByName(ctx context.Context, name string, sel md.Selector, key string) (string, error)
 	ListServices(ctx context.Context) ([]string, error)
-	ListAddrs(ctx context.Context, name string) ([]string, error)
+	ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error)
+	ListInstances(ctx context.Context) ([]md.Service, error)
+	Restore(ctx context.Context, svcs []md.Service, replace bool) error
+	UpdateWeight(ctx context.Context, name, addr string, weight int) error
+	SetHealth(ctx context.Context, name, addr string, health md.Health) error
 	DeactivateSvc(_ context.Context, name, addr string) error
 	ActivateSvc(ctx context.Context, name, addr string) error
 	Close() error
@@ -22,17 +33,27 @@ type ServiceDiscoveryRepo interface {
 type Controller struct {
 	repo        ServiceDiscoveryRepo
 	newAddrChan chan md.Service
+	events      *broker.Broker
 }
 
-func New(repo ServiceDiscoveryRepo, newAddrChan chan md.Service) *Controller {
+func New(repo ServiceDiscoveryRepo, newAddrChan chan md.Service, events *broker.Broker) *Controller {
 	return &Controller{
 		repo:        repo,
 		newAddrChan: newAddrChan,
+		events:      events,
 	}
 }
 
-func (c *Controller) Register(ctx context.Context, name, addr string) error {
-	err := c.repo.Register(ctx, name, addr)
+func (c *Controller) Register(ctx context.Context, svc *md.Service) (err error) {
+	name, addr := svc.Name, svc.Address
+	ctx, op := begin(ctx, opRegister, svcAttrs(name, addr)...)
+	defer op.end(&err)
+
+	if svc.Weight == 0 {
+		svc.Weight = md.DefaultWeight
+	}
+
+	err = c.repo.Register(ctx, svc)
 	if err != nil && errors.Is(err, repo.ErrAlreadyExists) {
 		zap.L().Debug(
 			"Error svc already registered",
@@ -48,18 +69,20 @@ func (c *Controller) Register(ctx context.Context, name, addr string) error {
 	}
 
 	select {
-	case c.newAddrChan <- md.Service{Name: name, Address: addr}:
+	case c.newAddrChan <- *svc:
 		zap.L().Debug(
 			"Sent new address",
 			zap.String("name", name), zap.String("address", addr),
 		)
 	default:
+		metrics.DroppedNotification()
 		zap.L().Warn(
 			"Channel is full, could not send new address",
 			zap.String("name", name), zap.String("address", addr),
 		)
 	}
 
+	c.events.Publish(md.Registered, name, addr)
 	zap.L().Debug(
 		"Registered svc",
 		zap.String("name", name), zap.String("address", addr),
@@ -67,8 +90,11 @@ func (c *Controller) Register(ctx context.Context, name, addr string) error {
 	return nil
 }
 
-func (c *Controller) Deregister(ctx context.Context, name, addr string) error {
-	err := c.repo.Deregister(ctx, name, addr)
+func (c *Controller) Deregister(ctx context.Context, name, addr string) (err error) {
+	ctx, op := begin(ctx, opDeregister, svcAttrs(name, addr)...)
+	defer op.end(&err)
+
+	err = c.repo.Deregister(ctx, name, addr)
 	if err != nil && errors.Is(err, repo.ErrNotFound) {
 		zap.L().Debug(
 			"Error svc not registered",
@@ -83,11 +109,101 @@ func (c *Controller) Deregister(ctx context.Context, name, addr string) error {
 		return err
 	}
 
+	c.events.Publish(md.Deregistered, name, addr)
+	return nil
+}
+
+func (c *Controller) Heartbeat(ctx context.Context, name, addr string) (err error) {
+	ctx, op := begin(ctx, opHeartbeat, svcAttrs(name, addr)...)
+	defer op.end(&err)
+
+	err = c.repo.Heartbeat(ctx, name, addr)
+	if err != nil && errors.Is(err, repo.ErrNotFound) {
+		zap.L().Debug(
+			"Error svc not registered",
+			zap.String("name", name), zap.String("address", addr),
+		)
+		return ErrNotFound
+	} else if err != nil {
+		zap.L().Error(
+			"Error renewing svc lease",
+			zap.String("name", name), zap.String("address", addr), zap.Error(err),
+		)
+		return err
+	}
+
+	return nil
+}
+
+func (c *Controller) UpdateWeight(ctx context.Context, name, addr string, weight int) (err error) {
+	ctx, op := begin(ctx, opUpdateWeight, svcAttrs(name, addr)...)
+	defer op.end(&err)
+
+	err = c.repo.UpdateWeight(ctx, name, addr, weight)
+	if err != nil && errors.Is(err, repo.ErrNotFound) {
+		zap.L().Debug(
+			"Error svc not registered",
+			zap.String("name", name), zap.String("address", addr),
+		)
+		return ErrNotFound
+	} else if err != nil {
+		zap.L().Error(
+			"Error updating svc weight",
+			zap.String("name", name), zap.String("address", addr), zap.Error(err),
+		)
+		return err
+	}
+
+	zap.L().Debug(
+		"Updated svc weight",
+		zap.String("name", name), zap.String("address", addr), zap.Int("weight", weight),
+	)
 	return nil
 }
 
-func (c *Controller) FindServiceByName(ctx context.Context, name string) (string, error) {
-	addr, err := c.repo.FindServiceByName(ctx, name)
+func (c *Controller) GetInstance(ctx context.Context, name, addr string) (_ *md.Service, err error) {
+	ctx, op := begin(ctx, opGetInstance, svcAttrs(name, addr)...)
+	defer op.end(&err)
+
+	return c.getInstance(ctx, name, addr)
+}
+
+func (c *Controller) getInstance(ctx context.Context, name, addr string) (*md.Service, error) {
+	svc, err := c.repo.GetService(ctx, name, addr)
+	if err != nil && errors.Is(err, repo.ErrNotFound) {
+		zap.L().Debug(
+			"Error svc not registered",
+			zap.String("name", name), zap.String("address", addr),
+		)
+		return nil, ErrNotFound
+	} else if err != nil {
+		zap.L().Error(
+			"Error getting svc",
+			zap.String("name", name), zap.String("address", addr), zap.Error(err),
+		)
+		return nil, err
+	}
+
+	return svc, nil
+}
+
+func (c *Controller) GetInstanceHealth(ctx context.Context, name, addr string) (_ *md.Health, err error) {
+	ctx, op := begin(ctx, opGetInstanceHealth, svcAttrs(name, addr)...)
+	defer op.end(&err)
+
+	svc, err := c.getInstance(ctx, name, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &svc.Health, nil
+}
+
+func (c *Controller) FindServiceByName(ctx context.Context, name string, sel md.Selector, key string) (_ string, err error) {
+	ctx, op := begin(ctx, opFind, attribute.String("discovery.service", name))
+	defer op.end(&err)
+
+	addr, err := c.repo.FindServiceByName(ctx, name, sel, key)
 	if err != nil && errors.Is(err, repo.ErrNotFound) {
 		zap.L().Debug(
 			"Error svc not registered",
@@ -105,7 +221,10 @@ func (c *Controller) FindServiceByName(ctx context.Context, name string) (string
 	return addr, nil
 }
 
-func (c *Controller) ListServices(ctx context.Context) ([]string, error) {
+func (c *Controller) ListServices(ctx context.Context) (_ []string, err error) {
+	ctx, op := begin(ctx, opListServices)
+	defer op.end(&err)
+
 	svcs, err := c.repo.ListServices(ctx)
 	if err != nil {
 		zap.L().Error("Error finding svcs", zap.Error(err))
@@ -115,8 +234,11 @@ func (c *Controller) ListServices(ctx context.Context) ([]string, error) {
 	return svcs, nil
 }
 
-func (c *Controller) ListAddrs(ctx context.Context, name string) ([]string, error) {
-	svcs, err := c.repo.ListAddrs(ctx, name)
+func (c *Controller) ListAddrs(ctx context.Context, name string, sel md.Selector) (_ []string, err error) {
+	ctx, op := begin(ctx, opListAddrs, attribute.String("discovery.service", name))
+	defer op.end(&err)
+
+	svcs, err := c.repo.ListAddrs(ctx, name, sel)
 	if err != nil && errors.Is(err, repo.ErrNotFound) {
 		zap.L().Debug("Error svc not registered")
 		return []string{}, ErrNotFound
@@ -130,3 +252,79 @@ func (c *Controller) ListAddrs(ctx context.Context, name string) ([]string, erro
 
 	return svcs, nil
 }
+
+// ListInstances returns every registered instance, active or not.
+func (c *Controller) ListInstances(ctx context.Context) (_ []md.Service, err error) {
+	ctx, op := begin(ctx, opListInstances)
+	defer op.end(&err)
+
+	svcs, err := c.repo.ListInstances(ctx)
+	if err != nil {
+		zap.L().Error("Error listing instances", zap.Error(err))
+		return nil, err
+	}
+
+	return svcs, nil
+}
+
+func (c *Controller) Export(ctx context.Context) (_ *md.Snapshot, err error) {
+	ctx, op := begin(ctx, opExport)
+	defer op.end(&err)
+
+	svcs, err := c.repo.ListInstances(ctx)
+	if err != nil {
+		zap.L().Error("Error listing instances", zap.Error(err))
+		return nil, err
+	}
+
+	return md.NewSnapshot(svcs, time.Now().UTC()), nil
+}
+
+// Import restores a snapshot and returns the number of restored instances.
+// With replace instances missing from the snapshot are deregistered.
+func (c *Controller) Import(ctx context.Context, snap *md.Snapshot, replace bool) (_ int, err error) {
+	ctx, op := begin(ctx, opImport, attribute.Bool("discovery.replace", replace))
+	defer op.end(&err)
+
+	var before []md.Service
+	if replace {
+		var err error
+		if before, err = c.repo.ListInstances(ctx); err != nil {
+			zap.L().Error("Error listing instances", zap.Error(err))
+			return 0, err
+		}
+	}
+
+	svcs := snap.Instances(time.Now())
+	if err := c.repo.Restore(ctx, svcs, replace); err != nil {
+		zap.L().Error("Error restoring snapshot", zap.Error(err))
+		return 0, err
+	}
+
+	for _, svc := range before {
+		if !slices.ContainsFunc(svcs, func(restored md.Service) bool {
+			return restored.Name == svc.Name && restored.Address == svc.Address
+		}) {
+			c.events.Publish(md.Deregistered, svc.Name, svc.Address)
+		}
+	}
+
+	// Unlike Register, do not drop instances when the checker is busy,
+	// a whole registry would otherwise go unchecked until the next restart
+	for _, svc := range svcs {
+		select {
+		case c.newAddrChan <- svc:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		c.events.Publish(md.Registered, svc.Name, svc.Address)
+	}
+
+	zap.L().Info("Imported snapshot", zap.Int("instances", len(svcs)), zap.Bool("replace", replace))
+	return len(svcs), nil
+}
+
+func (c *Controller) Watch(ctx context.Context, name string) <-chan md.ServiceEvent {
+	zap.L().Debug("New watcher", zap.String("name", name))
+	return c.events.Subscribe(ctx, name)
+}
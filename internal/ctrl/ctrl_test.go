@@ -3,10 +3,18 @@ package ctrl
 import (
 	"context"
 	"errors"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/metrics"
 	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/tracing"
+	"github.com/JMURv/service-discovery/internal/tracing/tracingtest"
 	"github.com/JMURv/service-discovery/mocks"
 	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/mock/gomock"
 	"testing"
 )
@@ -18,7 +26,7 @@ func TestRegister(t *testing.T) {
 	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
 
 	newAddrChan := make(chan md.Service)
-	ctrl := New(svcRepo, newAddrChan)
+	ctrl := New(svcRepo, newAddrChan, broker.New())
 
 	ctx := context.Background()
 	name := "test-svc"
@@ -31,32 +39,121 @@ func TestRegister(t *testing.T) {
 			assert.Equal(t, addr, service.Address)
 		}
 	}()
-	svcRepo.EXPECT().Register(gomock.Any(), name, addr).Return(nil).Times(1)
+	svcRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr, Weight: md.DefaultWeight}).Return(nil).Times(1)
 
-	err := ctrl.Register(ctx, name, addr)
+	err := ctrl.Register(ctx, &md.Service{Name: name, Address: addr})
 	assert.Nil(t, err)
 
 	// Test case 2: ErrAlreadyExists
-	svcRepo.EXPECT().Register(gomock.Any(), name, addr).Return(repo.ErrAlreadyExists).Times(1)
+	svcRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr, Weight: md.DefaultWeight}).Return(repo.ErrAlreadyExists).Times(1)
 
-	err = ctrl.Register(ctx, name, addr)
+	err = ctrl.Register(ctx, &md.Service{Name: name, Address: addr})
 	assert.IsType(t, repo.ErrAlreadyExists, err)
 
 	// Test case 3: Repo error (other than ErrAlreadyExists)
 	var ErrOther = errors.New("other error")
-	svcRepo.EXPECT().Register(gomock.Any(), name, addr).Return(ErrOther).Times(1)
+	svcRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr, Weight: md.DefaultWeight}).Return(ErrOther).Times(1)
 
-	err = ctrl.Register(ctx, name, addr)
+	err = ctrl.Register(ctx, &md.Service{Name: name, Address: addr})
 	assert.IsType(t, ErrOther, err)
 
 }
 
+func TestMetrics(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+
+	// Nobody receives, the checker is busy
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+	count := func(op, result string) float64 {
+		return testutil.ToFloat64(metrics.Operations.WithLabelValues(op, result))
+	}
+
+	// Test case 1: Operations are counted by result
+	ok, exists := count(opRegister, metrics.ResultOK), count(opRegister, metrics.ResultAlreadyExists)
+	dropped := testutil.ToFloat64(metrics.DroppedNotifications)
+	svcRepo.EXPECT().Register(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	svcRepo.EXPECT().Register(gomock.Any(), gomock.Any()).Return(repo.ErrAlreadyExists).Times(1)
+
+	assert.Nil(t, ctrl.Register(ctx, &md.Service{Name: "test-svc", Address: "addr1"}))
+	assert.Equal(t, ErrAlreadyExists, ctrl.Register(ctx, &md.Service{Name: "test-svc", Address: "addr1"}))
+	assert.Equal(t, ok+1, count(opRegister, metrics.ResultOK))
+	assert.Equal(t, exists+1, count(opRegister, metrics.ResultAlreadyExists))
+
+	// Test case 2: Registrations the checker missed are counted
+	assert.Equal(t, dropped+1, testutil.ToFloat64(metrics.DroppedNotifications))
+
+	// Test case 3: Nested lookups are counted once
+	health, get := count(opGetInstanceHealth, metrics.ResultNotFound), count(opGetInstance, metrics.ResultNotFound)
+	svcRepo.EXPECT().GetService(gomock.Any(), "test-svc", "addr2").Return(nil, repo.ErrNotFound).Times(1)
+
+	_, err := ctrl.GetInstanceHealth(ctx, "test-svc", "addr2")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, health+1, count(opGetInstanceHealth, metrics.ResultNotFound))
+	assert.Equal(t, get, count(opGetInstance, metrics.ResultNotFound))
+}
+
+func TestTracing(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+	exp := tracingtest.Install(t)
+
+	ctx, parent := tracing.Start(context.Background(), "request")
+
+	// Test case 1: Repository calls are children of the operation
+	svcRepo.EXPECT().ListAddrs(gomock.Any(), "test-svc", md.Selector{}).DoAndReturn(
+		func(ctx context.Context, name string, sel md.Selector) ([]string, error) {
+			span := trace.SpanFromContext(ctx)
+			assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+			assert.NotEqual(t, parent.SpanContext().SpanID(), span.SpanContext().SpanID())
+			return []string{"addr1"}, nil
+		},
+	).Times(1)
+
+	_, err := ctrl.ListAddrs(ctx, "test-svc", md.Selector{})
+	assert.Nil(t, err)
+
+	span := tracingtest.Find(exp, "ctrl.list_addrs")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		assert.Contains(t, span.Attributes, attribute.String("discovery.service", "test-svc"))
+		assert.Contains(t, span.Attributes, attribute.String("discovery.result", metrics.ResultOK))
+	}
+
+	// Test case 2: Unknown instances do not fail the span
+	svcRepo.EXPECT().Heartbeat(gomock.Any(), "test-svc", "addr2").Return(repo.ErrNotFound).Times(1)
+
+	assert.Equal(t, ErrNotFound, ctrl.Heartbeat(ctx, "test-svc", "addr2"))
+	span = tracingtest.Find(exp, "ctrl.heartbeat")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, codes.Unset, span.Status.Code)
+		assert.Contains(t, span.Attributes, attribute.String("discovery.result", metrics.ResultNotFound))
+	}
+
+	// Test case 3: Other errors fail the span
+	svcRepo.EXPECT().ListServices(gomock.Any()).Return(nil, errors.New("other error")).Times(1)
+
+	_, err = ctrl.ListServices(ctx)
+	assert.Error(t, err)
+	span = tracingtest.Find(exp, "ctrl.list_services")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, codes.Error, span.Status.Code)
+	}
+}
+
 func TestDeregister(t *testing.T) {
 	ctrlMock := gomock.NewController(t)
 	defer ctrlMock.Finish()
 
 	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
-	ctrl := New(svcRepo, make(chan md.Service))
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
 
 	ctx := context.Background()
 	name := "test-svc"
@@ -82,35 +179,159 @@ func TestDeregister(t *testing.T) {
 	assert.IsType(t, ErrOther, err)
 }
 
+func TestHeartbeat(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	svcRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	err := ctrl.Heartbeat(ctx, name, addr)
+	assert.Nil(t, err)
+
+	// Test case 2: ErrNotFound
+	svcRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(repo.ErrNotFound).Times(1)
+
+	err = ctrl.Heartbeat(ctx, name, addr)
+	assert.Equal(t, ErrNotFound, err)
+
+	// Test case 3: Repo error (other than ErrNotFound)
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(ErrOther).Times(1)
+
+	err = ctrl.Heartbeat(ctx, name, addr)
+	assert.Equal(t, ErrOther, err)
+}
+
+func TestUpdateWeight(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	svcRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 5).Return(nil).Times(1)
+
+	err := ctrl.UpdateWeight(ctx, name, addr, 5)
+	assert.Nil(t, err)
+
+	// Test case 2: ErrNotFound
+	svcRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 5).Return(repo.ErrNotFound).Times(1)
+
+	err = ctrl.UpdateWeight(ctx, name, addr, 5)
+	assert.Equal(t, ErrNotFound, err)
+
+	// Test case 3: Repo error (other than ErrNotFound)
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 5).Return(ErrOther).Times(1)
+
+	err = ctrl.UpdateWeight(ctx, name, addr, 5)
+	assert.Equal(t, ErrOther, err)
+}
+
+func TestGetInstance(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+	svc := &md.Service{Name: name, Address: addr, Health: md.Health{State: md.HealthFailing}}
+
+	// Test case 1: Success
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(svc, nil).Times(1)
+
+	res, err := ctrl.GetInstance(ctx, name, addr)
+	assert.Nil(t, err)
+	assert.Equal(t, svc, res)
+
+	// Test case 2: ErrNotFound
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(nil, repo.ErrNotFound).Times(1)
+
+	res, err = ctrl.GetInstance(ctx, name, addr)
+	assert.Nil(t, res)
+	assert.Equal(t, ErrNotFound, err)
+
+	// Test case 3: Repo error (other than ErrNotFound)
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(nil, ErrOther).Times(1)
+
+	res, err = ctrl.GetInstance(ctx, name, addr)
+	assert.Nil(t, res)
+	assert.Equal(t, ErrOther, err)
+}
+
+func TestGetInstanceHealth(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+	health := md.Health{State: md.HealthFailing, Failures: 2, LastError: "connection refused"}
+
+	// Test case 1: Success
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(&md.Service{Name: name, Address: addr, Health: health}, nil).Times(1)
+
+	res, err := ctrl.GetInstanceHealth(ctx, name, addr)
+	assert.Nil(t, err)
+	assert.Equal(t, &health, res)
+
+	// Test case 2: ErrNotFound
+	svcRepo.EXPECT().GetService(gomock.Any(), name, addr).Return(nil, repo.ErrNotFound).Times(1)
+
+	res, err = ctrl.GetInstanceHealth(ctx, name, addr)
+	assert.Nil(t, res)
+	assert.Equal(t, ErrNotFound, err)
+}
+
 func TestFindServiceByName(t *testing.T) {
 	ctrlMock := gomock.NewController(t)
 	defer ctrlMock.Finish()
 
 	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
-	ctrl := New(svcRepo, make(chan md.Service))
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
 
 	ctx := context.Background()
 	name := "test-svc"
 	addr := "http://localhost:8080"
 
 	// Test case 1: Success
-	svcRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return(addr, nil).Times(1)
+	svcRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return(addr, nil).Times(1)
 
-	res, err := ctrl.FindServiceByName(ctx, name)
+	res, err := ctrl.FindServiceByName(ctx, name, md.Selector{}, "")
 	assert.Equal(t, addr, res)
 
 	// Test case 2: ErrNotFound
-	svcRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return("", repo.ErrNotFound).Times(1)
+	svcRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return("", repo.ErrNotFound).Times(1)
 
-	res, err = ctrl.FindServiceByName(ctx, name)
+	res, err = ctrl.FindServiceByName(ctx, name, md.Selector{}, "")
 	assert.Equal(t, "", res)
 	assert.IsType(t, repo.ErrNotFound, err)
 
 	// Test case 3: Repo error (other than ErrNotFound)
 	var ErrOther = errors.New("other error")
-	svcRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return("", ErrOther).Times(1)
+	svcRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return("", ErrOther).Times(1)
 
-	res, err = ctrl.FindServiceByName(ctx, name)
+	res, err = ctrl.FindServiceByName(ctx, name, md.Selector{}, "")
 	assert.Equal(t, "", res)
 	assert.IsType(t, ErrOther, err)
 }
@@ -120,7 +341,7 @@ func TestListServices(t *testing.T) {
 	defer ctrlMock.Finish()
 
 	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
-	ctrl := New(svcRepo, make(chan md.Service))
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
 
 	ctx := context.Background()
 	expectedRes := []string{"name1", "name2", "name3"}
@@ -145,29 +366,209 @@ func TestListAddrs(t *testing.T) {
 	defer ctrlMock.Finish()
 
 	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
-	ctrl := New(svcRepo, make(chan md.Service))
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
 
 	ctx := context.Background()
 	expectedRes := []string{"http://localhost:8080", "http://localhost:8081", "http://localhost:8082"}
 	name := "test-svc"
 
 	// Test case 1: Success
-	svcRepo.EXPECT().ListAddrs(gomock.Any(), name).Return(expectedRes, nil).Times(1)
-	res, err := ctrl.ListAddrs(ctx, name)
+	svcRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return(expectedRes, nil).Times(1)
+	res, err := ctrl.ListAddrs(ctx, name, md.Selector{})
 	assert.Equal(t, expectedRes, res)
 
 	// Test case 2: ErrNotFound
-	svcRepo.EXPECT().ListAddrs(gomock.Any(), name).Return([]string{}, repo.ErrNotFound).Times(1)
+	svcRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, repo.ErrNotFound).Times(1)
 
-	res, err = ctrl.ListAddrs(ctx, name)
+	res, err = ctrl.ListAddrs(ctx, name, md.Selector{})
 	assert.Equal(t, []string{}, res)
 	assert.IsType(t, repo.ErrNotFound, err)
 
 	// Test case 3: Repo error (other than ErrNotFound)
 	var ErrOther = errors.New("other error")
-	svcRepo.EXPECT().ListAddrs(gomock.Any(), name).Return([]string{}, ErrOther).Times(1)
+	svcRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, ErrOther).Times(1)
 
-	res, err = ctrl.ListAddrs(ctx, name)
+	res, err = ctrl.ListAddrs(ctx, name, md.Selector{})
 	assert.Equal(t, []string{}, res)
 	assert.IsType(t, ErrOther, err)
 }
+
+func TestWatch(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service, 1), broker.New())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	events := ctrl.Watch(ctx, name)
+	others := ctrl.Watch(ctx, "other-svc")
+
+	// Test case 1: Register and deregister are published
+	svcRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr, Weight: md.DefaultWeight}).Return(nil).Times(1)
+	svcRepo.EXPECT().Deregister(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	assert.Nil(t, ctrl.Register(ctx, &md.Service{Name: name, Address: addr}))
+	assert.Nil(t, ctrl.Deregister(ctx, name, addr))
+
+	evt := <-events
+	assert.Equal(t, md.Registered, evt.Type)
+	assert.Equal(t, name, evt.Name)
+	assert.Equal(t, addr, evt.Address)
+
+	evt = <-events
+	assert.Equal(t, md.Deregistered, evt.Type)
+
+	// Test case 2: Failed mutations are not published
+	svcRepo.EXPECT().Deregister(gomock.Any(), name, addr).Return(repo.ErrNotFound).Times(1)
+	assert.NotNil(t, ctrl.Deregister(ctx, name, addr))
+
+	// Test case 3: Channels are closed on cancel
+	cancel()
+	_, ok := <-events
+	assert.False(t, ok)
+
+	_, ok = <-others
+	assert.False(t, ok)
+}
+
+func TestListInstances(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+	svcs := []md.Service{{Name: "orders", Address: "addr1", IsActive: true}}
+
+	// Test case 1: Success
+	svcRepo.EXPECT().ListInstances(gomock.Any()).Return(svcs, nil).Times(1)
+
+	res, err := ctrl.ListInstances(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, svcs, res)
+
+	// Test case 2: Repo error
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().ListInstances(gomock.Any()).Return(nil, ErrOther).Times(1)
+
+	_, err = ctrl.ListInstances(ctx)
+	assert.Equal(t, ErrOther, err)
+}
+
+func TestExport(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	ctrl := New(svcRepo, make(chan md.Service), broker.New())
+
+	ctx := context.Background()
+
+	// Test case 1: Instances are grouped by service
+	svcRepo.EXPECT().ListInstances(gomock.Any()).Return([]md.Service{
+		{Name: "payments", Address: "addr3", IsActive: true, Weight: 1},
+		{Name: "orders", Address: "addr2", Weight: 0},
+		{Name: "orders", Address: "addr1", IsActive: true, Weight: 2},
+	}, nil).Times(1)
+
+	res, err := ctrl.Export(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, md.SnapshotVersion, res.Version)
+	assert.Len(t, res.Services, 2)
+	assert.Equal(t, "orders", res.Services[0].Name)
+	assert.Equal(t, "addr1", res.Services[0].Instances[0].Address)
+	assert.Equal(t, 2, *res.Services[0].Instances[0].Weight)
+	assert.False(t, *res.Services[0].Instances[1].IsActive)
+	assert.Equal(t, "payments", res.Services[1].Name)
+
+	// Test case 2: Repo error
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().ListInstances(gomock.Any()).Return(nil, ErrOther).Times(1)
+
+	res, err = ctrl.Export(ctx)
+	assert.Nil(t, res)
+	assert.IsType(t, ErrOther, err)
+}
+
+func TestImport(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	newAddrChan := make(chan md.Service)
+	ctrl := New(svcRepo, newAddrChan, broker.New())
+
+	ctx := context.Background()
+	events := ctrl.Watch(ctx, "")
+	inactive := false
+	snap := &md.Snapshot{
+		Version: md.SnapshotVersion,
+		Services: []md.SnapshotService{
+			{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1"}, {Address: "addr2", IsActive: &inactive}}},
+		},
+	}
+
+	// Test case 1: Every restored instance is handed to the checker
+	scheduled := make(chan []string)
+	go func() {
+		var addrs []string
+		for i := 0; i < 2; i++ {
+			addrs = append(addrs, (<-newAddrChan).Address)
+		}
+		scheduled <- addrs
+	}()
+	svcRepo.EXPECT().Restore(gomock.Any(), gomock.Any(), false).DoAndReturn(
+		func(_ context.Context, svcs []md.Service, _ bool) error {
+			assert.Len(t, svcs, 2)
+			assert.True(t, svcs[0].IsActive)
+			assert.Equal(t, md.DefaultWeight, svcs[0].Weight)
+			assert.False(t, svcs[1].IsActive)
+			return nil
+		},
+	).Times(1)
+
+	n, err := ctrl.Import(ctx, snap, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"addr1", "addr2"}, <-scheduled)
+	assert.Equal(t, md.Registered, (<-events).Type)
+	assert.Equal(t, md.Registered, (<-events).Type)
+
+	// Test case 2: Replace deregisters instances missing from the snapshot
+	go func() {
+		for i := 0; i < 2; i++ {
+			<-newAddrChan
+		}
+	}()
+	svcRepo.EXPECT().ListInstances(gomock.Any()).Return([]md.Service{{Name: "orders", Address: "addr1"}, {Name: "payments", Address: "addr3"}}, nil).Times(1)
+	svcRepo.EXPECT().Restore(gomock.Any(), gomock.Any(), true).Return(nil).Times(1)
+
+	n, err = ctrl.Import(ctx, snap, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	evt := <-events
+	assert.Equal(t, md.Deregistered, evt.Type)
+	assert.Equal(t, "addr3", evt.Address)
+
+	// Test case 3: Repo error
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().Restore(gomock.Any(), gomock.Any(), false).Return(ErrOther).Times(1)
+
+	n, err = ctrl.Import(ctx, snap, false)
+	assert.Equal(t, 0, n)
+	assert.IsType(t, ErrOther, err)
+
+	// Test case 4: Canceled while the checker is busy
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	svcRepo.EXPECT().Restore(gomock.Any(), gomock.Any(), false).Return(nil).Times(1)
+
+	_, err = ctrl.Import(cctx, snap, false)
+	assert.ErrorIs(t, err, context.Canceled)
+}
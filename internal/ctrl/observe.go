@@ -0,0 +1,70 @@
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/service-discovery/internal/metrics"
+	"github.com/JMURv/service-discovery/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const (
+	opRegister          = "register"
+	opDeregister        = "deregister"
+	opHeartbeat         = "heartbeat"
+	opUpdateWeight      = "update_weight"
+	opGetInstance       = "get_instance"
+	opGetInstanceHealth = "get_instance_health"
+	opFind              = "find"
+	opListServices      = "list_services"
+	opListAddrs         = "list_addrs"
+	opListInstances     = "list_instances"
+	opExport            = "export"
+	opImport            = "import"
+)
+
+// operation is a controller operation being measured and traced.
+type operation struct {
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+// begin starts an operation, end it deferred with the named error of the operation.
+// The returned context carries the span of the operation down to the repository.
+func begin(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	ctx, span := tracing.Start(ctx, "ctrl."+name, attrs...)
+	return ctx, &operation{name: name, start: time.Now(), span: span}
+}
+
+func (o *operation) end(err *error) {
+	result := metrics.ResultOK
+	switch {
+	case *err == nil:
+	case errors.Is(*err, ErrNotFound):
+		result = metrics.ResultNotFound
+	case errors.Is(*err, ErrAlreadyExists):
+		result = metrics.ResultAlreadyExists
+	default:
+		result = metrics.ResultError
+	}
+
+	metrics.ObserveOperation(o.name, result, o.start)
+
+	// Unknown and duplicated instances are answers, only other errors fail the span
+	o.span.SetAttributes(attribute.String("discovery.result", result))
+	if result == metrics.ResultError {
+		tracing.End(o.span, *err)
+		return
+	}
+	tracing.End(o.span, nil)
+}
+
+func svcAttrs(name, addr string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("discovery.service", name),
+		attribute.String("discovery.address", addr),
+	}
+}
@@ -0,0 +1,337 @@
+// Package dns answers DNS queries out of the registry, for clients that only understand DNS.
+//
+// Active instances of a service are served under:
+//
+//	orders.service.discovery.           A, AAAA and SRV records
+//	canary.orders.service.discovery.    instances tagged canary only
+//	_orders._tcp.service.discovery.     SRV records, RFC 2782 style
+//
+// SRV targets of instances registered by IP are names under addr.<domain>,
+// resolved in the additional section.
+package dns
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDomain = "discovery."
+	defaultTTL    = 5
+	// lookupTimeout bounds the registry lookup of a single query
+	lookupTimeout = 2 * time.Second
+	// shutdownTimeout bounds how long Close waits for in-flight queries
+	shutdownTimeout = 10 * time.Second
+)
+
+type Ctrl interface {
+	ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error)
+}
+
+type Handler struct {
+	ctrl   Ctrl
+	domain string
+	ttl    uint32
+
+	mu   sync.Mutex
+	srvs []*dns.Server
+}
+
+// New serves the registry under domain, answers may be cached for ttl seconds.
+func New(ctrl Ctrl, domain string, ttl int) *Handler {
+	if domain == "" {
+		domain = defaultDomain
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	return &Handler{
+		ctrl:   ctrl,
+		domain: dns.CanonicalName(domain),
+		ttl:    uint32(ttl),
+	}
+}
+
+// Start serves UDP and TCP on the port, it returns once either of them stops.
+// The other one is shut down then, the errors of both are returned.
+func (h *Handler) Start(port int) error {
+	addr := fmt.Sprintf(":%v", port)
+	srvs := []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: h},
+		{Addr: addr, Net: "tcp", Handler: h},
+	}
+
+	started := make(chan struct{}, len(srvs))
+	done := make(chan error, len(srvs))
+	for _, srv := range srvs {
+		// Only listening servers can be shut down, miekg/dns refuses the others
+		srv.NotifyStartedFunc = func() {
+			h.mu.Lock()
+			h.srvs = append(h.srvs, srv)
+			h.mu.Unlock()
+			started <- struct{}{}
+		}
+		go func() {
+			done <- srv.ListenAndServe()
+		}()
+	}
+
+	// A server starting after the other one stopped is shut down right away
+	var errs []error
+	for stopped := 0; stopped < len(srvs); {
+		select {
+		case <-started:
+			if stopped == 0 {
+				continue
+			}
+		case err := <-done:
+			stopped++
+			errs = append(errs, err)
+		}
+		errs = append(errs, h.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Close shuts down the listening servers.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	srvs := h.srvs
+	h.srvs = nil
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, srv := range srvs {
+		if err := srv.ShutdownContext(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Handler) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	res := h.answer(req)
+
+	size := dns.MinMsgSize
+	if w.LocalAddr().Network() == "tcp" {
+		size = dns.MaxMsgSize
+	} else if opt := req.IsEdns0(); opt != nil {
+		size = int(opt.UDPSize())
+	}
+	res.Truncate(size)
+
+	if err := w.WriteMsg(res); err != nil {
+		zap.L().Debug("failed to write dns answer", zap.Error(err))
+	}
+}
+
+func (h *Handler) answer(req *dns.Msg) *dns.Msg {
+	res := new(dns.Msg)
+	res.SetReply(req)
+	res.Authoritative = true
+	res.RecursionAvailable = false
+
+	if len(req.Question) != 1 {
+		res.Rcode = dns.RcodeFormatError
+		return res
+	}
+
+	q := req.Question[0]
+	name := dns.CanonicalName(q.Name)
+	if !dns.IsSubDomain(h.domain, name) {
+		res.Rcode = dns.RcodeRefused
+		return res
+	}
+
+	labels := dns.SplitDomainName(strings.TrimSuffix(name, h.domain))
+	switch {
+	case len(labels) == 0:
+		if q.Qtype == dns.TypeSOA || q.Qtype == dns.TypeANY {
+			res.Answer = append(res.Answer, h.soa())
+		} else {
+			res.Ns = append(res.Ns, h.soa())
+		}
+	case labels[len(labels)-1] == "service":
+		h.answerService(res, q, labels[:len(labels)-1])
+	case labels[len(labels)-1] == "addr" && len(labels) == 2:
+		h.answerAddr(res, q, labels[0])
+	default:
+		h.notFound(res)
+	}
+
+	return res
+}
+
+// answerService answers <name>, <tag>.<name> and _<name>._<proto> under service.<domain>.
+func (h *Handler) answerService(res *dns.Msg, q dns.Question, labels []string) {
+	var name string
+	var sel md.Selector
+	switch {
+	case len(labels) == 2 && strings.HasPrefix(labels[0], "_") && strings.HasPrefix(labels[1], "_"):
+		name = strings.TrimPrefix(labels[0], "_")
+	case len(labels) == 2:
+		name, sel.Tags = labels[1], []string{labels[0]}
+	case len(labels) == 1:
+		name = labels[0]
+	default:
+		h.notFound(res)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	addrs, err := h.ctrl.ListAddrs(ctx, name, sel)
+	if errors.Is(err, ctrl.ErrNotFound) {
+		h.notFound(res)
+		return
+	} else if err != nil {
+		zap.L().Debug("failed to list addresses", zap.String("name", name), zap.Error(err))
+		res.Rcode = dns.RcodeServerFailure
+		return
+	}
+
+	// Spread clients over the instances, the list may be shared with the caller
+	addrs = slices.Clone(addrs)
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	for _, raw := range addrs {
+		host, port, ok := splitAddr(raw)
+		if !ok {
+			continue
+		}
+
+		if rr := h.ipRecord(q.Name, q.Qtype, host); rr != nil {
+			res.Answer = append(res.Answer, rr)
+		}
+
+		if (q.Qtype == dns.TypeSRV || q.Qtype == dns.TypeANY) && port > 0 {
+			target := dns.Fqdn(host)
+			if ip := net.ParseIP(host); ip != nil {
+				target = h.addrName(ip)
+				if rr := h.ipRecord(target, dns.TypeANY, host); rr != nil {
+					res.Extra = append(res.Extra, rr)
+				}
+			}
+
+			res.Answer = append(res.Answer, &dns.SRV{
+				Hdr:      h.header(q.Name, dns.TypeSRV),
+				Priority: 1,
+				Weight:   1,
+				Port:     uint16(port),
+				Target:   target,
+			})
+		}
+	}
+
+	if len(res.Answer) == 0 {
+		res.Ns = append(res.Ns, h.soa())
+	}
+}
+
+// answerAddr answers the names of SRV targets, <hex encoded ip>.addr.<domain>.
+func (h *Handler) answerAddr(res *dns.Msg, q dns.Question, label string) {
+	raw, err := hex.DecodeString(label)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		h.notFound(res)
+		return
+	}
+
+	if rr := h.ipRecord(q.Name, q.Qtype, net.IP(raw).String()); rr != nil {
+		res.Answer = append(res.Answer, rr)
+	} else {
+		res.Ns = append(res.Ns, h.soa())
+	}
+}
+
+// ipRecord returns the A or AAAA record of host when it is an IP matching qtype, nil otherwise.
+func (h *Handler) ipRecord(name string, qtype uint16, host string) dns.RR {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		if qtype != dns.TypeA && qtype != dns.TypeANY {
+			return nil
+		}
+		return &dns.A{Hdr: h.header(name, dns.TypeA), A: ip4}
+	}
+
+	if qtype != dns.TypeAAAA && qtype != dns.TypeANY {
+		return nil
+	}
+	return &dns.AAAA{Hdr: h.header(name, dns.TypeAAAA), AAAA: ip}
+}
+
+func (h *Handler) addrName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return hex.EncodeToString(ip) + ".addr." + h.domain
+}
+
+func (h *Handler) header(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: h.ttl}
+}
+
+// notFound answers NXDOMAIN, the SOA lets resolvers cache the negative answer.
+func (h *Handler) notFound(res *dns.Msg) {
+	res.Rcode = dns.RcodeNameError
+	res.Ns = append(res.Ns, h.soa())
+}
+
+func (h *Handler) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     h.header(h.domain, dns.TypeSOA),
+		Ns:      "ns." + h.domain,
+		Mbox:    "hostmaster." + h.domain,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  h.ttl,
+	}
+}
+
+// splitAddr accepts the address forms instances register with: host, host:port and scheme://host:port.
+func splitAddr(raw string) (string, int, bool) {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			return "", 0, false
+		}
+
+		port, _ := strconv.Atoi(u.Port())
+		return u.Hostname(), port, true
+	}
+
+	host, rawPort, err := net.SplitHostPort(raw)
+	if err != nil {
+		// No port, an IPv6 address is accepted with or without brackets
+		return strings.Trim(raw, "[]"), 0, raw != ""
+	}
+
+	port, err := strconv.Atoi(rawPort)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, false
+	}
+	return host, port, true
+}
@@ -0,0 +1,187 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/mocks"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net"
+	"testing"
+	"time"
+)
+
+func query(name string, qtype uint16) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	return req
+}
+
+func TestAnswer(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo, "", 30)
+
+	name := "orders"
+	addrs := []string{"10.0.0.1:8080", "http://[2001:db8::1]:9090", "orders-2.internal:8080", "10.0.0.3"}
+
+	// Test case 1: A records of instances registered by IPv4
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return(addrs, nil).Times(1)
+
+	res := hdl.answer(query("orders.service.discovery.", dns.TypeA))
+	assert.Equal(t, dns.RcodeSuccess, res.Rcode)
+	assert.True(t, res.Authoritative)
+	require.Len(t, res.Answer, 2)
+	ips := []string{res.Answer[0].(*dns.A).A.String(), res.Answer[1].(*dns.A).A.String()}
+	assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.3"}, ips)
+	assert.Equal(t, uint32(30), res.Answer[0].Header().Ttl)
+
+	// Test case 2: AAAA records
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return(addrs, nil).Times(1)
+
+	res = hdl.answer(query("ORDERS.service.discovery.", dns.TypeAAAA))
+	require.Len(t, res.Answer, 1)
+	assert.Equal(t, "2001:db8::1", res.Answer[0].(*dns.AAAA).AAAA.String())
+
+	// Test case 3: SRV records of instances with a port, IP targets resolved in the additional section
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return(addrs, nil).Times(1)
+
+	res = hdl.answer(query("_orders._tcp.service.discovery.", dns.TypeSRV))
+	require.Len(t, res.Answer, 3)
+	targets := map[string]uint16{}
+	for _, rr := range res.Answer {
+		srv := rr.(*dns.SRV)
+		targets[srv.Target] = srv.Port
+	}
+	assert.Equal(t, map[string]uint16{
+		"0a000001.addr.discovery.":                         8080,
+		"20010db8000000000000000000000001.addr.discovery.": 9090,
+		"orders-2.internal.":                               8080,
+	}, targets)
+	assert.Len(t, res.Extra, 2)
+
+	// Test case 4: Tag filter
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{Tags: []string{"canary"}}).Return([]string{"10.0.0.1:8080"}, nil).Times(1)
+
+	res = hdl.answer(query("canary.orders.service.discovery.", dns.TypeA))
+	assert.Len(t, res.Answer, 1)
+
+	// Test case 5: ErrNotFound
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), "payments", md.Selector{}).Return([]string{}, ctrl.ErrNotFound).Times(1)
+
+	res = hdl.answer(query("payments.service.discovery.", dns.TypeA))
+	assert.Equal(t, dns.RcodeNameError, res.Rcode)
+	require.Len(t, res.Ns, 1)
+	assert.IsType(t, &dns.SOA{}, res.Ns[0])
+
+	// Test case 6: ErrInternalError
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, errors.New("other error")).Times(1)
+
+	res = hdl.answer(query("orders.service.discovery.", dns.TypeA))
+	assert.Equal(t, dns.RcodeServerFailure, res.Rcode)
+
+	// Test case 7: No instance answers the query type
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{"orders-2.internal:8080"}, nil).Times(1)
+
+	res = hdl.answer(query("orders.service.discovery.", dns.TypeA))
+	assert.Equal(t, dns.RcodeSuccess, res.Rcode)
+	assert.Empty(t, res.Answer)
+	assert.Len(t, res.Ns, 1)
+
+	// Test case 8: SRV targets
+	res = hdl.answer(query("0a000001.addr.discovery.", dns.TypeA))
+	require.Len(t, res.Answer, 1)
+	assert.Equal(t, "10.0.0.1", res.Answer[0].(*dns.A).A.String())
+
+	res = hdl.answer(query("zz.addr.discovery.", dns.TypeA))
+	assert.Equal(t, dns.RcodeNameError, res.Rcode)
+
+	// Test case 9: Names out of the zone
+	res = hdl.answer(query("example.com.", dns.TypeA))
+	assert.Equal(t, dns.RcodeRefused, res.Rcode)
+
+	res = hdl.answer(query("orders.node.discovery.", dns.TypeA))
+	assert.Equal(t, dns.RcodeNameError, res.Rcode)
+}
+
+// instances is a Ctrl listing the same addresses for every service.
+type instances []string
+
+func (i instances) ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error) {
+	return i, nil
+}
+
+func TestServe(t *testing.T) {
+	// Many instances, more than fit an UDP answer without EDNS
+	var addrs instances
+	for i := 0; i < 100; i++ {
+		addrs = append(addrs, net.IPv4(10, 0, 0, byte(i)).String()+":8080")
+	}
+	hdl := New(addrs, "consul", 0)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	udp := &dns.Server{PacketConn: pc, Handler: hdl}
+	tcp := &dns.Server{Listener: ln, Handler: hdl}
+	go udp.ActivateAndServe()
+	go tcp.ActivateAndServe()
+	defer udp.Shutdown()
+	defer tcp.Shutdown()
+
+	// Test case 1: UDP answers are truncated
+	res, _, err := (&dns.Client{Net: "udp"}).Exchange(query("orders.service.consul.", dns.TypeA), pc.LocalAddr().String())
+	require.NoError(t, err)
+	assert.True(t, res.Truncated)
+	assert.NotEmpty(t, res.Answer)
+	assert.Less(t, len(res.Answer), 100)
+
+	// Test case 2: TCP answers are complete
+	res, _, err = (&dns.Client{Net: "tcp"}).Exchange(query("orders.service.consul.", dns.TypeA), ln.Addr().String())
+	require.NoError(t, err)
+	assert.False(t, res.Truncated)
+	assert.Len(t, res.Answer, 100)
+	assert.Equal(t, uint32(defaultTTL), res.Answer[0].Header().Ttl)
+}
+
+func TestStart(t *testing.T) {
+	hdl := New(instances{"10.0.0.1:8080"}, "", 0)
+
+	// Test case 1: The port taken for TCP, UDP is released again
+	ln, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	assert.Error(t, hdl.Start(port))
+	assert.NoError(t, ln.Close())
+	pc, err := net.ListenPacket("udp", fmt.Sprintf(":%v", port))
+	require.NoError(t, err)
+	assert.NoError(t, pc.Close())
+
+	// Test case 2: Close stops both
+	errs := make(chan error, 1)
+	go func() {
+		errs <- hdl.Start(port)
+	}()
+
+	addr := fmt.Sprintf("127.0.0.1:%v", port)
+	require.Eventually(t, func() bool {
+		_, _, err := (&dns.Client{Net: "tcp"}).Exchange(query("orders.service.discovery.", dns.TypeA), addr)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	res, _, err := (&dns.Client{Net: "udp"}).Exchange(query("orders.service.discovery.", dns.TypeA), addr)
+	require.NoError(t, err)
+	assert.Len(t, res.Answer, 1)
+
+	assert.NoError(t, hdl.Close())
+	assert.NoError(t, <-errs)
+}
@@ -1,28 +1,45 @@
 package grpc
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	pb "github.com/JMURv/service-discovery/api/pb"
 	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/snapshot"
+	"github.com/JMURv/service-discovery/internal/validation"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
-	"log"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"net"
+	"time"
 )
 
 type Ctrl interface {
-	Register(ctx context.Context, name, addr string) error
+	Register(ctx context.Context, svc *md.Service) error
 	Deregister(ctx context.Context, name, addr string) error
-	FindServiceByName(ctx context.Context, name string) (string, error)
+	Heartbeat(ctx context.Context, name, addr string) error
+	UpdateWeight(ctx context.Context, name, addr string, weight int) error
+	GetInstance(ctx context.Context, name, addr string) (*md.Service, error)
+	GetInstanceHealth(ctx context.Context, name, addr string) (*md.Health, error)
+	FindServiceByName(ctx context.Context, name string, sel md.Selector, key string) (string, error)
 	ListServices(ctx context.Context) ([]string, error)
-	ListAddrs(ctx context.Context, name string) ([]string, error)
+	ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error)
+	ListInstances(ctx context.Context) ([]md.Service, error)
+	Watch(ctx context.Context, name string) <-chan md.ServiceEvent
+	Export(ctx context.Context) (*md.Snapshot, error)
+	Import(ctx context.Context, snap *md.Snapshot, replace bool) (int, error)
 }
 
+// shutdownTimeout bounds how long Close waits for in-flight calls
+const shutdownTimeout = 10 * time.Second
+
 type Handler struct {
 	pb.ServiceDiscoveryServer
 	srv  *grpc.Server
@@ -30,28 +47,45 @@ type Handler struct {
 }
 
 func New(ctrl Ctrl) *Handler {
-	srv := grpc.NewServer()
+	// Spans continue the trace context of the incoming metadata
+	srv := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()))
 	reflection.Register(srv)
-	return &Handler{
+	h := &Handler{
 		ctrl: ctrl,
 		srv:  srv,
 	}
+	pb.RegisterServiceDiscoveryServer(srv, h)
+	return h
 }
 
-func (h *Handler) Start(port int) {
-	pb.RegisterServiceDiscoveryServer(h.srv, h)
+func (h *Handler) Start(port int) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		return err
 	}
 
-	if err := h.srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
-		log.Fatalf("failed to serve: %v", err)
+	if err = h.srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		zap.L().Debug("Server error", zap.Error(err))
+		return err
 	}
+	return nil
 }
 
+// Close waits for in-flight calls to finish. Watch streams never end on
+// their own, so connections still open after shutdownTimeout are closed.
 func (h *Handler) Close() error {
-	h.srv.GracefulStop()
+	done := make(chan struct{})
+	go func() {
+		h.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		zap.L().Warn("graceful shutdown timed out, closing open connections")
+		h.srv.Stop()
+	}
 	return nil
 }
 
@@ -59,9 +93,30 @@ func (h *Handler) Register(ctx context.Context, req *pb.NameAndAddressMsg) (*pb.
 	if req == nil || req.Name == "" || req.Address == "" {
 		zap.L().Error("failed to decode request")
 		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	} else if req.Weight < 0 {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrInvalidWeight))
+		return nil, status.Errorf(codes.InvalidArgument, validation.ErrInvalidWeight.Error())
+	} else if req.Ttl < 0 {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrInvalidTTL))
+		return nil, status.Errorf(codes.InvalidArgument, validation.ErrInvalidTTL.Error())
 	}
 
-	err := h.ctrl.Register(ctx, req.Name, req.Address)
+	check := checkFromProto(req.Check)
+	if err := validation.ValidateCheck(check, int(req.Ttl)); err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	err := h.ctrl.Register(ctx, &md.Service{
+		Name:     req.Name,
+		Address:  req.Address,
+		Weight:   int(req.Weight),
+		Version:  req.Version,
+		Tags:     req.Tags,
+		Metadata: req.Metadata,
+		TTL:      int(req.Ttl),
+		Check:    check,
+	})
 	if err != nil && errors.Is(err, ctrl.ErrAlreadyExists) {
 		return nil, status.Errorf(codes.AlreadyExists, err.Error())
 	} else if err != nil {
@@ -87,13 +142,97 @@ func (h *Handler) Deregister(ctx context.Context, req *pb.NameAndAddressMsg) (*p
 	return &pb.Empty{}, nil
 }
 
+func (h *Handler) Heartbeat(ctx context.Context, req *pb.NameAndAddressMsg) (*pb.Empty, error) {
+	if req == nil || req.Name == "" || req.Address == "" {
+		zap.L().Error("failed to decode request")
+		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	}
+
+	err := h.ctrl.Heartbeat(ctx, req.Name, req.Address)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	return &pb.Empty{}, nil
+}
+
+func (h *Handler) UpdateWeight(ctx context.Context, req *pb.WeightMsg) (*pb.Empty, error) {
+	if req == nil || req.Name == "" || req.Address == "" {
+		zap.L().Error("failed to decode request")
+		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	} else if req.Weight < 0 {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrInvalidWeight))
+		return nil, status.Errorf(codes.InvalidArgument, validation.ErrInvalidWeight.Error())
+	}
+
+	err := h.ctrl.UpdateWeight(ctx, req.Name, req.Address, int(req.Weight))
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	return &pb.Empty{}, nil
+}
+
+func (h *Handler) GetInstance(ctx context.Context, req *pb.NameAndAddressMsg) (*pb.InstanceMsg, error) {
+	if req == nil || req.Name == "" || req.Address == "" {
+		zap.L().Error("failed to decode request")
+		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	}
+
+	svc, err := h.ctrl.GetInstance(ctx, req.Name, req.Address)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	return &pb.InstanceMsg{
+		Name:     svc.Name,
+		Address:  svc.Address,
+		Version:  svc.Version,
+		Tags:     svc.Tags,
+		Metadata: svc.Metadata,
+		Weight:   int32(svc.Weight),
+		Ttl:      int32(svc.TTL),
+		Check:    checkToProto(svc.Check),
+		IsActive: svc.IsActive,
+		Health:   healthToProto(&svc.Health),
+	}, nil
+}
+
+func (h *Handler) GetInstanceHealth(ctx context.Context, req *pb.NameAndAddressMsg) (*pb.HealthMsg, error) {
+	if req == nil || req.Name == "" || req.Address == "" {
+		zap.L().Error("failed to decode request")
+		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	}
+
+	res, err := h.ctrl.GetInstanceHealth(ctx, req.Name, req.Address)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	return healthToProto(res), nil
+}
+
 func (h *Handler) FindService(ctx context.Context, req *pb.ServiceNameMsg) (*pb.ServiceAddressMsg, error) {
 	if req == nil || req.Name == "" {
 		zap.L().Error("failed to decode request")
 		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
 	}
 
-	res, err := h.ctrl.FindServiceByName(ctx, req.Name)
+	sel, err := validation.ParseSelector(req.Selector, req.Tags)
+	if err != nil {
+		zap.L().Debug("failed to parse selector", zap.String("selector", req.Selector), zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	res, err := h.ctrl.FindServiceByName(ctx, req.Name, sel, req.HashKey)
 	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
 		return nil, status.Errorf(codes.NotFound, err.Error())
 	} else if err != nil {
@@ -122,7 +261,13 @@ func (h *Handler) ListAddrs(ctx context.Context, req *pb.ServiceNameMsg) (*pb.Li
 		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
 	}
 
-	res, err := h.ctrl.ListAddrs(ctx, req.Name)
+	sel, err := validation.ParseSelector(req.Selector, req.Tags)
+	if err != nil {
+		zap.L().Debug("failed to parse selector", zap.String("selector", req.Selector), zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	res, err := h.ctrl.ListAddrs(ctx, req.Name, sel)
 	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
 		return nil, status.Errorf(codes.NotFound, err.Error())
 	} else if err != nil {
@@ -133,3 +278,145 @@ func (h *Handler) ListAddrs(ctx context.Context, req *pb.ServiceNameMsg) (*pb.Li
 		Address: res,
 	}, nil
 }
+
+func (h *Handler) Watch(req *pb.ServiceNameMsg, stream pb.ServiceDiscovery_WatchServer) error {
+	if req == nil {
+		zap.L().Error("failed to decode request")
+		return status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	}
+
+	for evt := range h.ctrl.Watch(stream.Context(), req.Name) {
+		if err := stream.Send(&pb.ServiceEventMsg{
+			Type:      pb.EventType(pb.EventType_value[string(evt.Type)]),
+			Name:      evt.Name,
+			Address:   evt.Address,
+			Timestamp: timestamppb.New(evt.Timestamp),
+		}); err != nil {
+			zap.L().Debug("failed to send event", zap.String("name", req.Name), zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) ExportSnapshot(ctx context.Context, req *pb.ExportSnapshotMsg) (*pb.SnapshotMsg, error) {
+	if req == nil {
+		zap.L().Error("failed to decode request")
+		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	}
+
+	format, err := snapshot.ParseFormat(req.Format)
+	if err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	snap, err := h.ctrl.Export(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	var buf bytes.Buffer
+	if err = snapshot.Encode(&buf, snap, format); err != nil {
+		zap.L().Error("failed to encode snapshot", zap.Error(err))
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	return &pb.SnapshotMsg{
+		Format: string(format),
+		Data:   buf.Bytes(),
+	}, nil
+}
+
+func (h *Handler) ImportSnapshot(ctx context.Context, req *pb.ImportSnapshotMsg) (*pb.ImportResultMsg, error) {
+	if req == nil {
+		zap.L().Error("failed to decode request")
+		return nil, status.Errorf(codes.InvalidArgument, ctrl.ErrDecodeRequest.Error())
+	}
+
+	format, err := snapshot.ParseFormat(req.Format)
+	if err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	snap, err := snapshot.Decode(bytes.NewReader(req.Data), format)
+	if err != nil {
+		zap.L().Debug("failed to decode snapshot", zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	if err = validation.ValidateSnapshot(snap); err != nil {
+		zap.L().Debug("failed to decode snapshot", zap.Error(err))
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	n, err := h.ctrl.Import(ctx, snap, req.Replace)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, ctrl.ErrInternalError.Error())
+	}
+
+	return &pb.ImportResultMsg{
+		Imported: int32(n),
+	}, nil
+}
+
+func checkFromProto(req *pb.CheckMsg) *md.Check {
+	if req == nil {
+		return nil
+	}
+
+	check := &md.Check{
+		Type:        md.CheckType(req.Type),
+		Path:        req.Path,
+		Interval:    int(req.Interval),
+		Timeout:     int(req.Timeout),
+		GRPCService: req.GrpcService,
+	}
+	for _, code := range req.Statuses {
+		check.Statuses = append(check.Statuses, int(code))
+	}
+
+	return check
+}
+
+func checkToProto(check *md.Check) *pb.CheckMsg {
+	if check == nil {
+		return nil
+	}
+
+	res := &pb.CheckMsg{
+		Type:        string(check.Type),
+		Path:        check.Path,
+		Interval:    int32(check.Interval),
+		Timeout:     int32(check.Timeout),
+		GrpcService: check.GRPCService,
+	}
+	for _, code := range check.Statuses {
+		res.Statuses = append(res.Statuses, int32(code))
+	}
+
+	return res
+}
+
+func healthToProto(health *md.Health) *pb.HealthMsg {
+	return &pb.HealthMsg{
+		State:            string(health.State),
+		Reason:           health.Reason,
+		Successes:        int32(health.Successes),
+		Failures:         int32(health.Failures),
+		Since:            timestampOrNil(health.Since),
+		QuarantinedUntil: timestampOrNil(health.QuarantinedUntil),
+		LastCheck:        timestampOrNil(health.LastCheck),
+		LastResult:       health.LastResult,
+		LastError:        health.LastError,
+	}
+}
+
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -5,11 +5,20 @@ import (
 	"errors"
 	pb "github.com/JMURv/service-discovery/api/pb"
 	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/tracing/tracingtest"
+	"github.com/JMURv/service-discovery/internal/validation"
 	"github.com/JMURv/service-discovery/mocks"
+	md "github.com/JMURv/service-discovery/pkg/model"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
 	"testing"
 	"time"
 )
@@ -26,13 +35,13 @@ func TestRegister(t *testing.T) {
 	addr := "http://localhost:8080"
 
 	// Test case 1: Success
-	ctrlRepo.EXPECT().Register(gomock.Any(), name, addr).Return(nil).Times(1)
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr}).Return(nil).Times(1)
 
 	_, err := hdl.Register(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
 	assert.Nil(t, err)
 
 	// Test case 2: ErrAlreadyExists
-	ctrlRepo.EXPECT().Register(gomock.Any(), name, addr).Return(ctrl.ErrAlreadyExists).Times(1)
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr}).Return(ctrl.ErrAlreadyExists).Times(1)
 
 	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
 	s, ok := status.FromError(err)
@@ -44,7 +53,7 @@ func TestRegister(t *testing.T) {
 
 	// Test case 3: ErrInternalError
 	var ErrOther = errors.New("other error")
-	ctrlRepo.EXPECT().Register(gomock.Any(), name, addr).Return(ErrOther).Times(1)
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr}).Return(ErrOther).Times(1)
 
 	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
 	s, ok = status.FromError(err)
@@ -54,7 +63,55 @@ func TestRegister(t *testing.T) {
 	assert.Equal(t, s.Code(), codes.Internal)
 	assert.Equal(t, s.Message(), ctrl.ErrInternalError.Error())
 
-	// Test case 4: ErrDecodeRequest
+	// Test case 4: Metadata is passed through
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{
+		Name:     name,
+		Address:  addr,
+		Version:  "v2",
+		Tags:     []string{"canary"},
+		Metadata: map[string]string{"zone": "eu-1"},
+	}).Return(nil).Times(1)
+
+	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{
+		Name:     name,
+		Address:  addr,
+		Version:  "v2",
+		Tags:     []string{"canary"},
+		Metadata: map[string]string{"zone": "eu-1"},
+	})
+	assert.Nil(t, err)
+
+	// Test case 5: Check spec
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{
+		Name:    name,
+		Address: addr,
+		Check:   &md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{204}, Interval: 10},
+	}).Return(nil).Times(1)
+
+	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{
+		Name:    name,
+		Address: addr,
+		Check:   &pb.CheckMsg{Type: "http", Path: "/ready", Statuses: []int32{204}, Interval: 10},
+	})
+	assert.Nil(t, err)
+
+	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr, Check: &pb.CheckMsg{Type: "ttl"}})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+
+	// Test case 6: Negative ttl
+	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr, Ttl: -1})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), validation.ErrInvalidTTL.Error())
+
+	// Test case 7: ErrDecodeRequest
 	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{Name: ""})
 	s, ok = status.FromError(err)
 	if !ok {
@@ -63,7 +120,7 @@ func TestRegister(t *testing.T) {
 	assert.Equal(t, s.Code(), codes.InvalidArgument)
 	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
 
-	// Test case 5: ErrDecodeRequest
+	// Test case 8: ErrDecodeRequest
 	_, err = hdl.Register(ctx, &pb.NameAndAddressMsg{Address: ""})
 	s, ok = status.FromError(err)
 	if !ok {
@@ -132,6 +189,249 @@ func TestDeregister(t *testing.T) {
 	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
 }
 
+func TestHeartbeat(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	_, err := hdl.Heartbeat(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	assert.Nil(t, err)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(ctrl.ErrNotFound).Times(1)
+
+	_, err = hdl.Heartbeat(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.NotFound)
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(ErrOther).Times(1)
+
+	_, err = hdl.Heartbeat(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.Internal)
+	assert.Equal(t, s.Message(), ctrl.ErrInternalError.Error())
+
+	// Test case 4: ErrDecodeRequest
+	_, err = hdl.Heartbeat(ctx, &pb.NameAndAddressMsg{Name: name})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
+}
+
+func TestUpdateWeight(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 0).Return(nil).Times(1)
+
+	_, err := hdl.UpdateWeight(ctx, &pb.WeightMsg{Name: name, Address: addr, Weight: 0})
+	assert.Nil(t, err)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 5).Return(ctrl.ErrNotFound).Times(1)
+
+	_, err = hdl.UpdateWeight(ctx, &pb.WeightMsg{Name: name, Address: addr, Weight: 5})
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.NotFound)
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 5).Return(ErrOther).Times(1)
+
+	_, err = hdl.UpdateWeight(ctx, &pb.WeightMsg{Name: name, Address: addr, Weight: 5})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.Internal)
+	assert.Equal(t, s.Message(), ctrl.ErrInternalError.Error())
+
+	// Test case 4: Negative weight
+	_, err = hdl.UpdateWeight(ctx, &pb.WeightMsg{Name: name, Address: addr, Weight: -1})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), validation.ErrInvalidWeight.Error())
+
+	// Test case 5: ErrDecodeRequest
+	_, err = hdl.UpdateWeight(ctx, &pb.WeightMsg{Name: name})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
+}
+
+func TestGetInstance(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+	since := time.Now()
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().GetInstance(gomock.Any(), name, addr).Return(&md.Service{
+		Name:    name,
+		Address: addr,
+		Weight:  2,
+		Check:   &md.Check{Type: md.CheckHTTP, Statuses: []int{200}},
+		Health: md.Health{
+			State:            md.HealthQuarantined,
+			Reason:           "flapping",
+			Failures:         1,
+			Since:            since,
+			QuarantinedUntil: since.Add(time.Minute),
+		},
+	}, nil).Times(1)
+
+	res, err := hdl.GetInstance(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), res.Weight)
+	assert.Equal(t, []int32{200}, res.Check.Statuses)
+	assert.False(t, res.IsActive)
+	assert.Equal(t, "quarantined", res.Health.State)
+	assert.Equal(t, "flapping", res.Health.Reason)
+	assert.Equal(t, int32(1), res.Health.Failures)
+	assert.True(t, since.Equal(res.Health.Since.AsTime()))
+	assert.True(t, since.Add(time.Minute).Equal(res.Health.QuarantinedUntil.AsTime()))
+
+	// Test case 2: Unchecked instance
+	ctrlRepo.EXPECT().GetInstance(gomock.Any(), name, addr).Return(&md.Service{Name: name, Address: addr, IsActive: true}, nil).Times(1)
+
+	res, err = hdl.GetInstance(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	assert.Nil(t, err)
+	assert.Nil(t, res.Check)
+	assert.Nil(t, res.Health.Since)
+	assert.Nil(t, res.Health.QuarantinedUntil)
+
+	// Test case 3: ErrNotFound
+	ctrlRepo.EXPECT().GetInstance(gomock.Any(), name, addr).Return(nil, ctrl.ErrNotFound).Times(1)
+
+	_, err = hdl.GetInstance(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.NotFound)
+
+	// Test case 4: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().GetInstance(gomock.Any(), name, addr).Return(nil, ErrOther).Times(1)
+
+	_, err = hdl.GetInstance(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.Internal)
+
+	// Test case 5: ErrDecodeRequest
+	_, err = hdl.GetInstance(ctx, &pb.NameAndAddressMsg{Name: name})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
+}
+
+func TestGetInstanceHealth(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+	lastCheck := time.Now()
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().GetInstanceHealth(gomock.Any(), name, addr).Return(&md.Health{
+		State:     md.HealthFailing,
+		Failures:  3,
+		LastCheck: lastCheck,
+		LastError: "connection refused",
+	}, nil).Times(1)
+
+	res, err := hdl.GetInstanceHealth(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	assert.Nil(t, err)
+	assert.Equal(t, "failing", res.State)
+	assert.Equal(t, int32(3), res.Failures)
+	assert.True(t, lastCheck.Equal(res.LastCheck.AsTime()))
+	assert.False(t, res.LastResult)
+	assert.Equal(t, "connection refused", res.LastError)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().GetInstanceHealth(gomock.Any(), name, addr).Return(nil, ctrl.ErrNotFound).Times(1)
+
+	_, err = hdl.GetInstanceHealth(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.NotFound)
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().GetInstanceHealth(gomock.Any(), name, addr).Return(nil, ErrOther).Times(1)
+
+	_, err = hdl.GetInstanceHealth(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.Internal)
+
+	// Test case 4: ErrDecodeRequest
+	_, err = hdl.GetInstanceHealth(ctx, &pb.NameAndAddressMsg{Address: addr})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+}
+
 func TestFindService(t *testing.T) {
 	ctrlMock := gomock.NewController(t)
 	defer ctrlMock.Finish()
@@ -144,13 +444,13 @@ func TestFindService(t *testing.T) {
 	addr := "http://localhost:8080"
 
 	// Test case 1: Success
-	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return(addr, nil).Times(1)
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return(addr, nil).Times(1)
 
 	_, err := hdl.FindService(ctx, &pb.ServiceNameMsg{Name: name})
 	assert.Nil(t, err)
 
 	// Test case 2: ErrNotFound
-	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return("", ctrl.ErrNotFound).Times(1)
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return("", ctrl.ErrNotFound).Times(1)
 
 	_, err = hdl.FindService(ctx, &pb.ServiceNameMsg{Name: name})
 	s, ok := status.FromError(err)
@@ -162,7 +462,7 @@ func TestFindService(t *testing.T) {
 
 	// Test case 3: ErrInternalError
 	var ErrOther = errors.New("other error")
-	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return("", ErrOther).Times(1)
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return("", ErrOther).Times(1)
 
 	_, err = hdl.FindService(ctx, &pb.ServiceNameMsg{Name: name})
 	s, ok = status.FromError(err)
@@ -172,7 +472,24 @@ func TestFindService(t *testing.T) {
 	assert.Equal(t, s.Code(), codes.Internal)
 	assert.Equal(t, s.Message(), ctrl.ErrInternalError.Error())
 
-	// Test case 4: ErrDecodeRequest - missing name
+	// Test case 4: Selector and tags
+	sel := md.Selector{Labels: map[string]string{"version": "v2"}, Tags: []string{"canary"}}
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, sel, "").Return(addr, nil).Times(1)
+
+	res, err := hdl.FindService(ctx, &pb.ServiceNameMsg{Name: name, Selector: "version=v2", Tags: []string{"canary"}})
+	assert.Nil(t, err)
+	assert.Equal(t, addr, res.Address)
+
+	// Test case 5: Invalid selector
+	_, err = hdl.FindService(ctx, &pb.ServiceNameMsg{Name: name, Selector: "=v2"})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), validation.ErrInvalidSelector.Error())
+
+	// Test case 6: ErrDecodeRequest - missing name
 	_, err = hdl.FindService(ctx, &pb.ServiceNameMsg{Name: ""})
 	s, ok = status.FromError(err)
 	if !ok {
@@ -227,14 +544,14 @@ func TestListAddrs(t *testing.T) {
 	expectedRes := &pb.ListAddrsMsg{Address: addrs}
 
 	// Test case 1: Success
-	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name).Return(addrs, nil).Times(1)
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return(addrs, nil).Times(1)
 
 	res, err := hdl.ListAddrs(ctx, &pb.ServiceNameMsg{Name: name})
 	assert.Nil(t, err)
 	assert.Equal(t, expectedRes, res)
 
 	// Test case 2: ErrNotFound
-	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name).Return([]string{}, ctrl.ErrNotFound).Times(1)
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, ctrl.ErrNotFound).Times(1)
 
 	_, err = hdl.ListAddrs(ctx, &pb.ServiceNameMsg{Name: name})
 	s, ok := status.FromError(err)
@@ -246,7 +563,7 @@ func TestListAddrs(t *testing.T) {
 
 	// Test case 3: ErrInternalError
 	var ErrOther = errors.New("other error")
-	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name).Return([]string{}, ErrOther).Times(1)
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, ErrOther).Times(1)
 
 	_, err = hdl.ListAddrs(ctx, &pb.ServiceNameMsg{Name: name})
 	s, ok = status.FromError(err)
@@ -266,6 +583,200 @@ func TestListAddrs(t *testing.T) {
 	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
 }
 
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*pb.ServiceEventMsg
+	err  error
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(msg *pb.ServiceEventMsg) error {
+	s.sent = append(s.sent, msg)
+	return s.err
+}
+
+func TestWatch(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	name := "test-svc"
+	addr := "http://localhost:8080"
+	now := time.Now()
+
+	// Test case 1: Success
+	events := make(chan md.ServiceEvent, 2)
+	events <- md.ServiceEvent{Type: md.Registered, Name: name, Address: addr, Timestamp: now}
+	events <- md.ServiceEvent{Type: md.Deactivated, Name: name, Address: addr, Timestamp: now}
+	close(events)
+
+	stream := &watchStream{ctx: context.Background()}
+	ctrlRepo.EXPECT().Watch(gomock.Any(), name).Return((<-chan md.ServiceEvent)(events)).Times(1)
+
+	err := hdl.Watch(&pb.ServiceNameMsg{Name: name}, stream)
+	assert.Nil(t, err)
+	assert.Len(t, stream.sent, 2)
+	assert.Equal(t, pb.EventType_REGISTERED, stream.sent[0].Type)
+	assert.Equal(t, pb.EventType_DEACTIVATED, stream.sent[1].Type)
+	assert.Equal(t, addr, stream.sent[1].Address)
+	assert.Equal(t, now.UnixNano(), stream.sent[1].Timestamp.AsTime().UnixNano())
+
+	// Test case 2: Send error
+	var ErrOther = errors.New("other error")
+	events = make(chan md.ServiceEvent, 1)
+	events <- md.ServiceEvent{Type: md.Activated, Name: name, Address: addr, Timestamp: now}
+
+	stream = &watchStream{ctx: context.Background(), err: ErrOther}
+	ctrlRepo.EXPECT().Watch(gomock.Any(), "").Return((<-chan md.ServiceEvent)(events)).Times(1)
+
+	err = hdl.Watch(&pb.ServiceNameMsg{}, stream)
+	assert.Equal(t, ErrOther, err)
+
+	// Test case 3: ErrDecodeRequest
+	err = hdl.Watch(nil, stream)
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+	assert.Equal(t, s.Message(), ctrl.ErrDecodeRequest.Error())
+}
+
+func TestExportSnapshot(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	snap := md.NewSnapshot([]md.Service{{Name: "test-svc", Address: "http://localhost:8080", IsActive: true, Weight: 1}}, time.Now())
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().Export(gomock.Any()).Return(snap, nil).Times(1)
+
+	res, err := hdl.ExportSnapshot(ctx, &pb.ExportSnapshotMsg{Format: "yaml"})
+	assert.Nil(t, err)
+	assert.Equal(t, "yaml", res.Format)
+	assert.Contains(t, string(res.Data), "address: http://localhost:8080")
+
+	// Test case 2: ErrUnsupportedFormat
+	_, err = hdl.ExportSnapshot(ctx, &pb.ExportSnapshotMsg{Format: "xml"})
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.InvalidArgument)
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().Export(gomock.Any()).Return(nil, ErrOther).Times(1)
+
+	_, err = hdl.ExportSnapshot(ctx, &pb.ExportSnapshotMsg{})
+	s, ok = status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.Internal)
+	assert.Equal(t, s.Message(), ctrl.ErrInternalError.Error())
+}
+
+func TestImportSnapshot(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	data := []byte(`{"version": 1, "services": [{"name": "test-svc", "instances": [{"address": "http://localhost:8080"}]}]}`)
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().Import(gomock.Any(), gomock.Any(), true).Return(1, nil).Times(1)
+
+	res, err := hdl.ImportSnapshot(ctx, &pb.ImportSnapshotMsg{Data: data, Replace: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), res.Imported)
+
+	// Test case 2: Malformed and invalid snapshots
+	for _, req := range []*pb.ImportSnapshotMsg{
+		nil,
+		{Format: "xml", Data: data},
+		{Data: []byte(`{"version": 1, "unknown": true}`)},
+		{Data: []byte(`{"version": 2}`)},
+		{Format: "yaml", Data: []byte("version: 1\nservices:\n  - name: test-svc\n    instances:\n      - weight: 1\n")},
+	} {
+		_, err = hdl.ImportSnapshot(ctx, req)
+		s, ok := status.FromError(err)
+		if !ok {
+			t.Fatalf("expected status error, got %v", err)
+		}
+		assert.Equal(t, s.Code(), codes.InvalidArgument)
+	}
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().Import(gomock.Any(), gomock.Any(), false).Return(0, ErrOther).Times(1)
+
+	_, err = hdl.ImportSnapshot(ctx, &pb.ImportSnapshotMsg{Data: data})
+	s, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	assert.Equal(t, s.Code(), codes.Internal)
+	assert.Equal(t, s.Message(), ctrl.ErrInternalError.Error())
+}
+
+// services answers ListServices with the trace ID of the call, the mock is not used
+// from the goroutines of the server
+type services struct {
+	Ctrl
+}
+
+func (services) ListServices(ctx context.Context) ([]string, error) {
+	return []string{trace.SpanContextFromContext(ctx).TraceID().String()}, nil
+}
+
+func TestTracing(t *testing.T) {
+	exp := tracingtest.Install(t)
+	hdl := New(services{})
+
+	lis := bufconn.Listen(1 << 20)
+	go hdl.srv.Serve(lis)
+	defer hdl.srv.Stop()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Test case 1: Calls continue the trace of the caller
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	res, err := pb.NewServiceDiscoveryClient(conn).ListServices(ctx, &pb.Empty{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{traceID}, res.GetName())
+
+	assert.Eventually(t, func() bool {
+		return tracingtest.Find(exp, "service_discovery.ServiceDiscovery/ListServices") != nil
+	}, time.Second, 10*time.Millisecond)
+	span := tracingtest.Find(exp, "service_discovery.ServiceDiscovery/ListServices")
+	assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+}
+
 func TestStart(t *testing.T) {
 	ctrlMock := gomock.NewController(t)
 	defer ctrlMock.Finish()
@@ -273,9 +784,25 @@ func TestStart(t *testing.T) {
 	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
 	hdl := New(ctrlRepo)
 
-	go hdl.Start(8080)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- hdl.Start(8080)
+	}()
 	time.Sleep(500 * time.Millisecond)
 
 	err := hdl.Close()
 	assert.Nil(t, err)
+	assert.Nil(t, <-errs)
+}
+
+func TestStartPortInUse(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	lis, err := net.Listen("tcp", ":0")
+	assert.Nil(t, err)
+	defer lis.Close()
+
+	hdl := New(mocks.NewMockCtrl(ctrlMock))
+	assert.Error(t, hdl.Start(lis.Addr().(*net.TCPAddr).Port))
 }
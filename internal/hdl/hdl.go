@@ -1,6 +1,7 @@
 package hdl
 
 type Handler interface {
-	Start(port int)
+	// Start blocks serving on the port until the handler is closed
+	Start(port int) error
 	Close() error
 }
@@ -1,58 +1,91 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/JMURv/service-discovery/internal/ctrl"
 	"github.com/JMURv/service-discovery/internal/hdl/grpc"
+	"github.com/JMURv/service-discovery/internal/metrics"
+	"github.com/JMURv/service-discovery/internal/snapshot"
 	"github.com/JMURv/service-discovery/internal/validation"
 	md "github.com/JMURv/service-discovery/pkg/model"
 	utils "github.com/JMURv/service-discovery/pkg/utils/http"
 	"github.com/goccy/go-json"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 )
 
+// shutdownTimeout bounds how long Close waits for in-flight requests
+const shutdownTimeout = 10 * time.Second
+
 type Handler struct {
 	srv  *http.Server
 	ctrl grpc.Ctrl
 }
 
 func New(ctrl grpc.Ctrl) *Handler {
-	return &Handler{
+	h := &Handler{
 		ctrl: ctrl,
 	}
-}
 
-func (h *Handler) Start(port int) {
 	r := mux.NewRouter()
 	r.HandleFunc("/health-check", h.healthCheck).Methods(http.MethodGet)
 	r.HandleFunc("/register", h.register).Methods(http.MethodPost)
 	r.HandleFunc("/deregister", h.deregister).Methods(http.MethodPost)
+	r.HandleFunc("/heartbeat", h.heartbeat).Methods(http.MethodPost)
+	r.HandleFunc("/update-weight", h.updateWeight).Methods(http.MethodPost)
 	r.HandleFunc("/find", h.find).Methods(http.MethodPost)
+	r.HandleFunc("/instance", h.getInstance).Methods(http.MethodPost)
+	r.HandleFunc("/instance-health", h.getInstanceHealth).Methods(http.MethodPost)
 
 	r.HandleFunc("/list-svcs", h.listSvcs).Methods(http.MethodGet)
 	r.HandleFunc("/list-addrs", h.listAddrs).Methods(http.MethodPost)
 
+	r.HandleFunc("/snapshot", h.exportSnapshot).Methods(http.MethodGet)
+	r.HandleFunc("/snapshot", h.importSnapshot).Methods(http.MethodPost)
+
+	r.HandleFunc("/prometheus/sd", h.prometheusSD).Methods(http.MethodGet)
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
 	h.srv = &http.Server{
-		Handler:      r,
-		Addr:         fmt.Sprintf(":%v", port),
+		Handler:      traced(r),
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	return h
+}
+
+func (h *Handler) Start(port int) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		return err
+	}
 
-	err := h.srv.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
+	if err = h.srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		zap.L().Debug("Server error", zap.Error(err))
+		return err
 	}
+	return nil
+}
+
+// ServeHTTP routes a request without a listener, e.g. behind httptest.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.srv.Handler.ServeHTTP(w, r)
 }
 
 func (h *Handler) Close() error {
-	if err := h.srv.Shutdown(context.Background()); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := h.srv.Shutdown(ctx); err != nil {
 		return err
 	}
 	return nil
@@ -76,7 +109,7 @@ func (h *Handler) listSvcs(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) listAddrs(w http.ResponseWriter, r *http.Request) {
-	req := &md.Service{}
+	req := &md.ServiceQuery{}
 	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
 		zap.L().Debug("failed to decode request", zap.Error(err))
 		utils.ErrResponse(w, http.StatusBadRequest, err)
@@ -89,8 +122,18 @@ func (h *Handler) listAddrs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	svcs, err := h.ctrl.ListAddrs(r.Context(), req.Name)
-	if err != nil && errors.Is(err, ctrl.ErrAlreadyExists) {
+	sel, err := validation.ParseSelector(req.Selector, req.Tags)
+	if err != nil {
+		zap.L().Debug("failed to parse selector", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	svcs, err := h.ctrl.ListAddrs(r.Context(), req.Name, sel)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil && errors.Is(err, ctrl.ErrAlreadyExists) {
 		utils.ErrResponse(w, http.StatusConflict, err)
 		return
 	} else if err != nil {
@@ -117,9 +160,23 @@ func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
 		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingAddress))
 		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingAddress)
 		return
+	} else if req.Weight < 0 {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrInvalidWeight))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrInvalidWeight)
+		return
+	} else if req.TTL < 0 {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrInvalidTTL))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrInvalidTTL)
+		return
 	}
 
-	err := h.ctrl.Register(r.Context(), req.Name, req.Address)
+	if err := validation.ValidateCheck(req.Check, req.TTL); err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.ctrl.Register(r.Context(), req)
 	if err != nil && errors.Is(err, ctrl.ErrAlreadyExists) {
 		utils.ErrResponse(w, http.StatusConflict, err)
 		return
@@ -161,7 +218,101 @@ func (h *Handler) deregister(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessResponse(w, http.StatusOK, "OK")
 }
 
-func (h *Handler) find(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) heartbeat(w http.ResponseWriter, r *http.Request) {
+	req := &md.Service{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Name == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingName))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingName)
+		return
+	} else if req.Address == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingAddress))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingAddress)
+		return
+	}
+
+	err := h.ctrl.Heartbeat(r.Context(), req.Name, req.Address)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, "OK")
+}
+
+func (h *Handler) updateWeight(w http.ResponseWriter, r *http.Request) {
+	req := &md.Service{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Name == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingName))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingName)
+		return
+	} else if req.Address == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingAddress))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingAddress)
+		return
+	} else if req.Weight < 0 {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrInvalidWeight))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrInvalidWeight)
+		return
+	}
+
+	err := h.ctrl.UpdateWeight(r.Context(), req.Name, req.Address, req.Weight)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, "OK")
+}
+
+func (h *Handler) getInstance(w http.ResponseWriter, r *http.Request) {
+	req := &md.Service{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Name == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingName))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingName)
+		return
+	} else if req.Address == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingAddress))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingAddress)
+		return
+	}
+
+	res, err := h.ctrl.GetInstance(r.Context(), req.Name, req.Address)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, res)
+}
+
+func (h *Handler) getInstanceHealth(w http.ResponseWriter, r *http.Request) {
 	req := &md.Service{}
 	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
 		zap.L().Debug("failed to decode request", zap.Error(err))
@@ -173,9 +324,46 @@ func (h *Handler) find(w http.ResponseWriter, r *http.Request) {
 		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingName))
 		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingName)
 		return
+	} else if req.Address == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingAddress))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingAddress)
+		return
+	}
+
+	res, err := h.ctrl.GetInstanceHealth(r.Context(), req.Name, req.Address)
+	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, res)
+}
+
+func (h *Handler) find(w http.ResponseWriter, r *http.Request) {
+	req := &md.ServiceQuery{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Name == "" {
+		zap.L().Debug("failed to decode request", zap.Error(validation.ErrMissingName))
+		utils.ErrResponse(w, http.StatusBadRequest, validation.ErrMissingName)
+		return
+	}
+
+	sel, err := validation.ParseSelector(req.Selector, req.Tags)
+	if err != nil {
+		zap.L().Debug("failed to parse selector", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
 	}
 
-	res, err := h.ctrl.FindServiceByName(r.Context(), req.Name)
+	res, err := h.ctrl.FindServiceByName(r.Context(), req.Name, sel, req.HashKey)
 	if err != nil && errors.Is(err, ctrl.ErrNotFound) {
 		utils.ErrResponse(w, http.StatusNotFound, err)
 		return
@@ -186,3 +374,92 @@ func (h *Handler) find(w http.ResponseWriter, r *http.Request) {
 
 	utils.SuccessResponse(w, http.StatusOK, res)
 }
+
+// exportSnapshot writes the snapshot as is, so the response can be saved
+// and posted back to /snapshot
+func (h *Handler) exportSnapshot(w http.ResponseWriter, r *http.Request) {
+	format, err := snapshot.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	snap, err := h.ctrl.Export(r.Context())
+	if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, ctrl.ErrInternalError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err = snapshot.Encode(&buf, snap, format); err != nil {
+		zap.L().Error("failed to encode snapshot", zap.Error(err))
+		utils.ErrResponse(w, http.StatusInternalServerError, ctrl.ErrInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// prometheusSD serves the instances as a Prometheus http_sd_config,
+// limited to the services given as repeated ?service= parameters.
+func (h *Handler) prometheusSD(w http.ResponseWriter, r *http.Request) {
+	svcs, err := h.ctrl.ListInstances(r.Context())
+	if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, ctrl.ErrInternalError)
+		return
+	}
+
+	if names := r.URL.Query()["service"]; len(names) > 0 {
+		svcs = slices.DeleteFunc(svcs, func(svc md.Service) bool {
+			return !slices.Contains(names, svc.Name)
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(md.NewTargetGroups(svcs)); err != nil {
+		zap.L().Debug("failed to write targets", zap.Error(err))
+	}
+}
+
+func (h *Handler) importSnapshot(w http.ResponseWriter, r *http.Request) {
+	format, err := snapshot.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		zap.L().Debug("failed to decode request", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var replace bool
+	if raw := r.URL.Query().Get("replace"); raw != "" {
+		if replace, err = strconv.ParseBool(raw); err != nil {
+			zap.L().Debug("failed to decode request", zap.Error(err))
+			utils.ErrResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	snap, err := snapshot.Decode(r.Body, format)
+	if err != nil {
+		zap.L().Debug("failed to decode snapshot", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = validation.ValidateSnapshot(snap); err != nil {
+		zap.L().Debug("failed to decode snapshot", zap.Error(err))
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	n, err := h.ctrl.Import(r.Context(), snap, replace)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, ctrl.ErrInternalError)
+		return
+	}
+
+	utils.SuccessResponse(w, http.StatusOK, map[string]int{"imported": n})
+}
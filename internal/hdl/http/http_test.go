@@ -5,10 +5,14 @@ import (
 	"context"
 	"errors"
 	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/tracing/tracingtest"
 	"github.com/JMURv/service-discovery/mocks"
+	md "github.com/JMURv/service-discovery/pkg/model"
 	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/mock/gomock"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -27,7 +31,7 @@ func TestRegister(t *testing.T) {
 	addr := "http://localhost:8080"
 
 	// Test case 1: Success
-	ctrlRepo.EXPECT().Register(gomock.Any(), name, addr).Return(nil).Times(1)
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr}).Return(nil).Times(1)
 
 	payload, _ := json.Marshal(map[string]string{"name": name, "address": addr})
 	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(payload))
@@ -39,7 +43,7 @@ func TestRegister(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Result().StatusCode)
 
 	// Test case 2: ErrAlreadyExists
-	ctrlRepo.EXPECT().Register(gomock.Any(), name, addr).Return(ctrl.ErrAlreadyExists).Times(1)
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr}).Return(ctrl.ErrAlreadyExists).Times(1)
 
 	payload, _ = json.Marshal(map[string]string{"name": name, "address": addr})
 	req = httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(payload))
@@ -52,7 +56,7 @@ func TestRegister(t *testing.T) {
 
 	// Test case 3: ErrInternalError
 	var ErrOther = errors.New("other error")
-	ctrlRepo.EXPECT().Register(gomock.Any(), name, addr).Return(ErrOther).Times(1)
+	ctrlRepo.EXPECT().Register(gomock.Any(), &md.Service{Name: name, Address: addr}).Return(ErrOther).Times(1)
 
 	payload, _ = json.Marshal(map[string]string{"name": name, "address": addr})
 	req = httptest.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(payload))
@@ -173,6 +177,208 @@ func TestDeregister(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
 }
 
+func TestHeartbeat(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(nil).Times(1)
+
+	payload, _ := json.Marshal(map[string]string{"name": name, "address": addr})
+	req := httptest.NewRequest(http.MethodPost, "/heartbeat", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	hdl.heartbeat(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().Heartbeat(gomock.Any(), name, addr).Return(ctrl.ErrNotFound).Times(1)
+
+	req = httptest.NewRequest(http.MethodPost, "/heartbeat", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.heartbeat(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+	// Test case 3: ErrDecodeRequest
+	payload, _ = json.Marshal(map[string]string{"name": name})
+	req = httptest.NewRequest(http.MethodPost, "/heartbeat", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.heartbeat(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestUpdateWeight(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 25).Return(nil).Times(1)
+
+	payload, _ := json.Marshal(map[string]any{"name": name, "address": addr, "weight": 25})
+	req := httptest.NewRequest(http.MethodPost, "/update-weight", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	hdl.updateWeight(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().UpdateWeight(gomock.Any(), name, addr, 25).Return(ctrl.ErrNotFound).Times(1)
+
+	req = httptest.NewRequest(http.MethodPost, "/update-weight", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.updateWeight(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+	// Test case 3: Negative weight
+	payload, _ = json.Marshal(map[string]any{"name": name, "address": addr, "weight": -5})
+	req = httptest.NewRequest(http.MethodPost, "/update-weight", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.updateWeight(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	// Test case 4: ErrDecodeRequest
+	payload, _ = json.Marshal(map[string]any{"name": name, "weight": 5})
+	req = httptest.NewRequest(http.MethodPost, "/update-weight", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.updateWeight(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestGetInstance(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().GetInstance(gomock.Any(), name, addr).Return(&md.Service{
+		Name:    name,
+		Address: addr,
+		Health:  md.Health{State: md.HealthFailing, Reason: "2 consecutive failed checks", Failures: 2},
+	}, nil).Times(1)
+
+	payload, _ := json.Marshal(map[string]string{"name": name, "address": addr})
+	req := httptest.NewRequest(http.MethodPost, "/instance", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	hdl.getInstance(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Contains(t, w.Body.String(), `"state":"failing"`)
+	assert.Contains(t, w.Body.String(), `"reason":"2 consecutive failed checks"`)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().GetInstance(gomock.Any(), name, addr).Return(nil, ctrl.ErrNotFound).Times(1)
+
+	req = httptest.NewRequest(http.MethodPost, "/instance", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.getInstance(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+	// Test case 3: ErrDecodeRequest
+	payload, _ = json.Marshal(map[string]string{"name": name})
+	req = httptest.NewRequest(http.MethodPost, "/instance", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.getInstance(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestGetInstanceHealth(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	ctx := context.Background()
+	name := "test-svc"
+	addr := "http://localhost:8080"
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().GetInstanceHealth(gomock.Any(), name, addr).Return(&md.Health{
+		State:      md.HealthPassing,
+		Successes:  4,
+		LastResult: true,
+	}, nil).Times(1)
+
+	payload, _ := json.Marshal(map[string]string{"name": name, "address": addr})
+	req := httptest.NewRequest(http.MethodPost, "/instance-health", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w := httptest.NewRecorder()
+	hdl.getInstanceHealth(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Contains(t, w.Body.String(), `"state":"passing"`)
+	assert.Contains(t, w.Body.String(), `"last_result":true`)
+
+	// Test case 2: ErrNotFound
+	ctrlRepo.EXPECT().GetInstanceHealth(gomock.Any(), name, addr).Return(nil, ctrl.ErrNotFound).Times(1)
+
+	req = httptest.NewRequest(http.MethodPost, "/instance-health", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.getInstanceHealth(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+	// Test case 3: ErrDecodeRequest
+	payload, _ = json.Marshal(map[string]string{"address": addr})
+	req = httptest.NewRequest(http.MethodPost, "/instance-health", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.getInstanceHealth(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
 func TestFindSvc(t *testing.T) {
 	ctrlMock := gomock.NewController(t)
 	defer ctrlMock.Finish()
@@ -185,7 +391,7 @@ func TestFindSvc(t *testing.T) {
 	addr := "http://localhost:8080"
 
 	// Test case 1: Success
-	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return(addr, nil).Times(1)
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return(addr, nil).Times(1)
 
 	payload, _ := json.Marshal(map[string]string{"name": name})
 	req := httptest.NewRequest(http.MethodPost, "/find", bytes.NewBuffer(payload))
@@ -197,7 +403,7 @@ func TestFindSvc(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
 
 	// Test case 2: ErrNotFound
-	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return("", ctrl.ErrNotFound).Times(1)
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return("", ctrl.ErrNotFound).Times(1)
 
 	payload, _ = json.Marshal(map[string]string{"name": name})
 	req = httptest.NewRequest(http.MethodPost, "/find", bytes.NewBuffer(payload))
@@ -210,7 +416,7 @@ func TestFindSvc(t *testing.T) {
 
 	// Test case 3: ErrInternalError
 	var ErrOther = errors.New("other error")
-	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name).Return("", ErrOther).Times(1)
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, md.Selector{}, "").Return("", ErrOther).Times(1)
 
 	payload, _ = json.Marshal(map[string]string{"name": name})
 	req = httptest.NewRequest(http.MethodPost, "/find", bytes.NewBuffer(payload))
@@ -250,6 +456,29 @@ func TestFindSvc(t *testing.T) {
 	w = httptest.NewRecorder()
 	hdl.find(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	// Test case 7: Selector and tags
+	sel := md.Selector{Labels: map[string]string{"version": "v2", "zone": "eu-1"}, Tags: []string{"canary"}}
+	ctrlRepo.EXPECT().FindServiceByName(gomock.Any(), name, sel, "").Return(addr, nil).Times(1)
+
+	payload, _ = json.Marshal(map[string]any{"name": name, "selector": "version=v2,zone=eu-1", "tags": []string{"canary"}})
+	req = httptest.NewRequest(http.MethodPost, "/find", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.find(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	// Test case 8: Invalid selector
+	payload, _ = json.Marshal(map[string]any{"name": name, "selector": "version"})
+	req = httptest.NewRequest(http.MethodPost, "/find", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.find(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
 }
 
 func TestListAddrs(t *testing.T) {
@@ -264,7 +493,7 @@ func TestListAddrs(t *testing.T) {
 	expRes := []string{"http://localhost:8080", "http://localhost:8081"}
 
 	// Test case 1: Success
-	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name).Return(expRes, nil).Times(1)
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return(expRes, nil).Times(1)
 
 	payload, _ := json.Marshal(map[string]string{"name": name})
 	req := httptest.NewRequest(http.MethodPost, "/list-addrs", bytes.NewBuffer(payload))
@@ -276,7 +505,7 @@ func TestListAddrs(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
 
 	// Test case 2: ErrAlreadyExists
-	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name).Return([]string{}, ctrl.ErrAlreadyExists).Times(1)
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, ctrl.ErrAlreadyExists).Times(1)
 
 	payload, _ = json.Marshal(map[string]string{"name": name})
 	req = httptest.NewRequest(http.MethodPost, "/list-addrs", bytes.NewBuffer(payload))
@@ -289,7 +518,7 @@ func TestListAddrs(t *testing.T) {
 
 	// Test case 3: ErrInternalError
 	var ErrOther = errors.New("other error")
-	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name).Return([]string{}, ErrOther).Times(1)
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, ErrOther).Times(1)
 
 	payload, _ = json.Marshal(map[string]string{"name": name})
 	req = httptest.NewRequest(http.MethodPost, "/list-addrs", bytes.NewBuffer(payload))
@@ -329,6 +558,18 @@ func TestListAddrs(t *testing.T) {
 	w = httptest.NewRecorder()
 	hdl.listAddrs(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	// Test case 7: ErrNotFound
+	ctrlRepo.EXPECT().ListAddrs(gomock.Any(), name, md.Selector{}).Return([]string{}, ctrl.ErrNotFound).Times(1)
+
+	payload, _ = json.Marshal(map[string]string{"name": name})
+	req = httptest.NewRequest(http.MethodPost, "/list-addrs", bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	w = httptest.NewRecorder()
+	hdl.listAddrs(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
 }
 
 func TestListSvcs(t *testing.T) {
@@ -376,6 +617,208 @@ func TestListSvcs(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
 }
 
+func TestExportSnapshot(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	snap := md.NewSnapshot([]md.Service{{Name: "test-svc", Address: "http://localhost:8080", IsActive: true, Weight: 1}}, time.Now())
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().Export(gomock.Any()).Return(snap, nil).Times(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/snapshot?format=yaml", nil)
+	w := httptest.NewRecorder()
+	hdl.exportSnapshot(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "application/yaml", w.Result().Header.Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "address: http://localhost:8080")
+
+	// Test case 2: ErrUnsupportedFormat
+	req = httptest.NewRequest(http.MethodGet, "/snapshot?format=xml", nil)
+	w = httptest.NewRecorder()
+	hdl.exportSnapshot(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().Export(gomock.Any()).Return(nil, ErrOther).Times(1)
+
+	req = httptest.NewRequest(http.MethodGet, "/snapshot", nil)
+	w = httptest.NewRecorder()
+	hdl.exportSnapshot(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestPrometheusSD(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	svcs := []md.Service{
+		{Name: "orders", Address: "10.0.0.2:8080", IsActive: true, Version: "v2", Metadata: map[string]string{"zone": "eu-1"}},
+		{Name: "orders", Address: "10.0.0.1:8080", IsActive: true, Version: "v2", Metadata: map[string]string{"zone": "eu-1"}},
+		{Name: "orders", Address: "https://10.0.0.3:8443", Version: "v2", Tags: []string{"canary"}, Metadata: map[string]string{"metrics.path": "/m"}},
+		{Name: "payments", Address: "10.0.1.1:9090", IsActive: true, Health: md.Health{State: md.HealthPassing}},
+	}
+
+	// Test case 1: Instances sharing their labels are grouped
+	ctrlRepo.EXPECT().ListInstances(gomock.Any()).Return(svcs, nil).Times(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/prometheus/sd", nil)
+	w := httptest.NewRecorder()
+	hdl.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+
+	var groups []md.TargetGroup
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&groups))
+	assert.Equal(t, []md.TargetGroup{
+		{
+			Targets: []string{"10.0.0.1:8080", "10.0.0.2:8080"},
+			Labels: map[string]string{
+				md.PrometheusServiceLabel:           "orders",
+				md.PrometheusActiveLabel:            "true",
+				md.PrometheusVersionLabel:           "v2",
+				md.PrometheusMetadataLabel + "zone": "eu-1",
+			},
+		},
+		{
+			Targets: []string{"10.0.0.3:8443"},
+			Labels: map[string]string{
+				md.PrometheusServiceLabel:                   "orders",
+				md.PrometheusActiveLabel:                    "false",
+				md.PrometheusVersionLabel:                   "v2",
+				md.PrometheusTagsLabel:                      ",canary,",
+				md.PrometheusMetadataLabel + "metrics_path": "/m",
+				"__scheme__":                                "https",
+			},
+		},
+		{
+			Targets: []string{"10.0.1.1:9090"},
+			Labels: map[string]string{
+				md.PrometheusServiceLabel: "payments",
+				md.PrometheusActiveLabel:  "true",
+				md.PrometheusHealthLabel:  "passing",
+			},
+		},
+	}, groups)
+
+	// Test case 2: Filtered by service
+	ctrlRepo.EXPECT().ListInstances(gomock.Any()).Return(svcs, nil).Times(1)
+
+	req = httptest.NewRequest(http.MethodGet, "/prometheus/sd?service=payments&service=billing", nil)
+	w = httptest.NewRecorder()
+	hdl.prometheusSD(w, req)
+	groups = nil
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&groups))
+	assert.Len(t, groups, 1)
+	assert.Equal(t, []string{"10.0.1.1:9090"}, groups[0].Targets)
+
+	// Test case 3: Empty registry
+	ctrlRepo.EXPECT().ListInstances(gomock.Any()).Return(nil, nil).Times(1)
+
+	req = httptest.NewRequest(http.MethodGet, "/prometheus/sd", nil)
+	w = httptest.NewRecorder()
+	hdl.prometheusSD(w, req)
+	assert.Equal(t, "[]\n", w.Body.String())
+
+	// Test case 4: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().ListInstances(gomock.Any()).Return(nil, ErrOther).Times(1)
+
+	req = httptest.NewRequest(http.MethodGet, "/prometheus/sd", nil)
+	w = httptest.NewRecorder()
+	hdl.prometheusSD(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestImportSnapshot(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	payload := `{"version": 1, "services": [{"name": "test-svc", "instances": [{"address": "http://localhost:8080"}]}]}`
+
+	// Test case 1: Success
+	ctrlRepo.EXPECT().Import(gomock.Any(), gomock.Any(), true).Return(1, nil).Times(1)
+
+	req := httptest.NewRequest(http.MethodPost, "/snapshot?replace=true", bytes.NewBufferString(payload))
+	w := httptest.NewRecorder()
+	hdl.importSnapshot(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.JSONEq(t, `{"data": {"imported": 1}}`, w.Body.String())
+
+	// Test case 2: Malformed and invalid snapshots
+	for _, tc := range []struct{ query, body string }{
+		{"?format=xml", payload},
+		{"?replace=maybe", payload},
+		{"", `{"version": 1, "unknown": true}`},
+		{"", `{"version": 2}`},
+		{"?format=yaml", "version: 1\nservices:\n  - name: test-svc\n    instances:\n      - weight: 1\n"},
+	} {
+		req = httptest.NewRequest(http.MethodPost, "/snapshot"+tc.query, bytes.NewBufferString(tc.body))
+		w = httptest.NewRecorder()
+		hdl.importSnapshot(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+
+	// Test case 3: ErrInternalError
+	var ErrOther = errors.New("other error")
+	ctrlRepo.EXPECT().Import(gomock.Any(), gomock.Any(), false).Return(0, ErrOther).Times(1)
+
+	req = httptest.NewRequest(http.MethodPost, "/snapshot", bytes.NewBufferString(payload))
+	w = httptest.NewRecorder()
+	hdl.importSnapshot(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestTracing(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	exp := tracingtest.Install(t)
+	ctrlRepo := mocks.NewMockCtrl(ctrlMock)
+	hdl := New(ctrlRepo)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	// Test case 1: Requests continue the trace of the caller under the route name
+	ctrlRepo.EXPECT().ListServices(gomock.Any()).DoAndReturn(func(ctx context.Context) ([]string, error) {
+		assert.Equal(t, traceID, trace.SpanContextFromContext(ctx).TraceID().String())
+		return []string{}, nil
+	}).Times(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/list-svcs", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	w := httptest.NewRecorder()
+	hdl.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	span := tracingtest.Find(exp, "GET /list-svcs")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+		assert.True(t, span.Parent.IsRemote())
+	}
+
+	// Test case 2: Health checks are not traced
+	exp.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/health-check", nil)
+
+	w = httptest.NewRecorder()
+	hdl.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Empty(t, exp.GetSpans())
+}
+
 func TestStart(t *testing.T) {
 	ctrlMock := gomock.NewController(t)
 	defer ctrlMock.Finish()
@@ -416,3 +859,15 @@ func TestClose(t *testing.T) {
 		defer resp.Body.Close()
 	}
 }
+
+func TestStartPortInUse(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	lis, err := net.Listen("tcp", ":0")
+	assert.Nil(t, err)
+	defer lis.Close()
+
+	hdl := New(mocks.NewMockCtrl(ctrlMock))
+	assert.Error(t, hdl.Start(lis.Addr().(*net.TCPAddr).Port))
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// untraced paths are polled, tracing them would bury the API calls
+var untraced = map[string]bool{
+	"/health-check": true,
+	"/metrics":      true,
+}
+
+// traced starts a span for every request, continuing the trace of the caller.
+func traced(r *mux.Router) http.Handler {
+	r.Use(routeSpan)
+	return otelhttp.NewHandler(
+		r, "http",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untraced[r.URL.Path]
+		}),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// routeSpan names the span of the request after the matched route,
+// the route is only known once the router matched it.
+func routeSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tpl, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + tpl)
+			span.SetAttributes(semconv.HTTPRoute(tpl))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,195 @@
+// Package metrics holds the Prometheus metrics of the discovery server.
+// Collectors are registered on the default registry, served by Handler.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"time"
+)
+
+const namespace = "discovery"
+
+// Results of controller operations
+const (
+	ResultOK            = "ok"
+	ResultNotFound      = "not_found"
+	ResultAlreadyExists = "already_exists"
+	ResultError         = "error"
+)
+
+// Results of health checks
+const (
+	CheckPassed = "passed"
+	CheckFailed = "failed"
+)
+
+var (
+	Operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Controller operations by result.",
+	}, []string{"operation", "result"})
+
+	OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of controller operations by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "result"})
+
+	Checks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "health_checks_total",
+		Help:      "Health checks by protocol and outcome.",
+	}, []string{"protocol", "result"})
+
+	CheckDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "health_check_duration_seconds",
+		Help:      "Latency of health checks by protocol and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"protocol", "result"})
+
+	DroppedNotifications = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checker_notifications_dropped_total",
+		Help:      "Registrations the checker was not notified of because it was busy, they are picked up on the next resync.",
+	})
+)
+
+// ObserveOperation records a controller operation started at start.
+func ObserveOperation(op, result string, start time.Time) {
+	Operations.WithLabelValues(op, result).Inc()
+	OperationDuration.WithLabelValues(op, result).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCheck records a health check of the protocol started at start.
+func ObserveCheck(protocol md.CheckType, err error, start time.Time) {
+	result := CheckPassed
+	if err != nil {
+		result = CheckFailed
+	}
+
+	Checks.WithLabelValues(string(protocol), result).Inc()
+	CheckDuration.WithLabelValues(string(protocol), result).Observe(time.Since(start).Seconds())
+}
+
+// DroppedNotification records a registration the checker was not notified of.
+func DroppedNotification() {
+	DroppedNotifications.Inc()
+}
+
+type Lister interface {
+	ListInstances(ctx context.Context) ([]md.Service, error)
+}
+
+// instances reports the registered instances of every service when scraped,
+// so the gauges are right whichever replica or cluster node changed the registry.
+type instances struct {
+	lister Lister
+	desc   *prometheus.Desc
+}
+
+// NewInstances returns the collector of the instance gauges, register it once.
+func NewInstances(lister Lister) prometheus.Collector {
+	return &instances{
+		lister: lister,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "instances"),
+			"Registered instances by service and activity state.",
+			[]string{"service", "state"}, nil,
+		),
+	}
+}
+
+// scrapeTimeout bounds the registry lookup of a scrape
+const scrapeTimeout = 5 * time.Second
+
+func (c *instances) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *instances) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	svcs, err := c.lister.ListInstances(ctx)
+	if err != nil {
+		zap.L().Debug("failed to list instances", zap.Error(err))
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	type counts struct{ active, inactive int }
+	byService := make(map[string]*counts)
+	for _, svc := range svcs {
+		n, ok := byService[svc.Name]
+		if !ok {
+			n = &counts{}
+			byService[svc.Name] = n
+		}
+
+		if svc.IsActive {
+			n.active++
+		} else {
+			n.inactive++
+		}
+	}
+
+	for name, n := range byService {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n.active), name, "active")
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n.inactive), name, "inactive")
+	}
+}
+
+// Handler serves the default registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// shutdownTimeout bounds how long Close waits for in-flight scrapes
+const shutdownTimeout = 10 * time.Second
+
+// Server serves /metrics on a port of its own, for deployments without the HTTP API.
+type Server struct {
+	srv *http.Server
+}
+
+func NewServer() *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &Server{
+		srv: &http.Server{
+			Handler:      mux,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start(port int) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
+	if err != nil {
+		return err
+	}
+
+	if err = s.srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type lister struct {
+	svcs []md.Service
+	err  error
+}
+
+func (l *lister) ListInstances(ctx context.Context) ([]md.Service, error) {
+	return l.svcs, l.err
+}
+
+func TestInstances(t *testing.T) {
+	l := &lister{svcs: []md.Service{
+		{Name: "orders", Address: "addr1", IsActive: true},
+		{Name: "orders", Address: "addr2", IsActive: true},
+		{Name: "orders", Address: "addr3"},
+		{Name: "payments", Address: "addr4", IsActive: true},
+	}}
+	c := NewInstances(l)
+
+	// Test case 1: Instances are counted per service and state
+	err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP discovery_instances Registered instances by service and activity state.
+# TYPE discovery_instances gauge
+discovery_instances{service="orders",state="active"} 2
+discovery_instances{service="orders",state="inactive"} 1
+discovery_instances{service="payments",state="active"} 1
+discovery_instances{service="payments",state="inactive"} 0
+`))
+	assert.NoError(t, err)
+
+	// Test case 2: Registry error
+	l.err = errors.New("other error")
+	_, err = testutil.CollectAndLint(c)
+	assert.Error(t, err)
+}
+
+func TestObserve(t *testing.T) {
+	before := testutil.ToFloat64(Checks.WithLabelValues("http", CheckFailed))
+	ObserveCheck(md.CheckHTTP, errors.New("connection refused"), time.Now())
+	assert.Equal(t, before+1, testutil.ToFloat64(Checks.WithLabelValues("http", CheckFailed)))
+
+	before = testutil.ToFloat64(Operations.WithLabelValues("register", ResultOK))
+	ObserveOperation("register", ResultOK, time.Now())
+	assert.Equal(t, before+1, testutil.ToFloat64(Operations.WithLabelValues("register", ResultOK)))
+}
+
+func TestServer(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewServer().srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Contains(t, w.Body.String(), "discovery_operations_total")
+}
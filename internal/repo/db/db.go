@@ -3,33 +3,106 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/balancer"
 	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/tracing"
+	"github.com/JMURv/service-discovery/pkg/config"
 	md "github.com/JMURv/service-discovery/pkg/model"
 	"github.com/glebarez/sqlite"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
-	"sync"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
 )
 
 type Repository struct {
-	mu      sync.Mutex
-	conn    *gorm.DB
-	rrIndex map[string]int
+	conn     *gorm.DB
+	balancer balancer.Balancer
 }
 
-func New() *Repository {
-	conn, err := gorm.Open(sqlite.Open("discovery.db"), &gorm.Config{})
+const (
+	defaultPath = "discovery.db"
+	memoryPath  = ":memory:"
+)
+
+// New opens the SQLite database described by the configuration, defaults are used when it is nil.
+func New(conf *config.SQLiteConfig, bal balancer.Balancer) (*Repository, error) {
+	if conf == nil {
+		conf = &config.SQLiteConfig{}
+	}
+
+	path := conf.Path
+	if path == "" {
+		path = defaultPath
+	}
+
+	if path != memoryPath {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create the database directory: %w", err)
+		}
+	}
+
+	r, err := Open(sqlite.Open(dsn(path, conf)), bal)
 	if err != nil {
-		zap.L().Fatal("failed to connect to the database", zap.Error(err))
+		return nil, err
 	}
-	if err = conn.AutoMigrate(&md.Service{}); err != nil {
-		zap.L().Fatal("failed to migrate the database", zap.Error(err))
+
+	// Every connection to an in-memory database opens a database of its own
+	if path == memoryPath {
+		db, err := r.conn.DB()
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(1)
 	}
 
-	return &Repository{
-		conn:    conn,
-		rrIndex: make(map[string]int),
+	return r, nil
+}
+
+// dsn appends the configured pragmas, the driver runs them on every new connection.
+func dsn(path string, conf *config.SQLiteConfig) string {
+	var pragmas []string
+	if conf.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", conf.BusyTimeout))
+	}
+	if conf.JournalMode != "" {
+		pragmas = append(pragmas, fmt.Sprintf("journal_mode(%s)", conf.JournalMode))
 	}
+	for _, name := range slices.Sorted(maps.Keys(conf.Pragmas)) {
+		pragmas = append(pragmas, fmt.Sprintf("%s(%s)", name, conf.Pragmas[name]))
+	}
+
+	if len(pragmas) == 0 {
+		return path
+	}
+	return path + "?" + url.Values{"_pragma": pragmas}.Encode()
+}
+
+// Open connects to the database behind the dialector and migrates the schema.
+// Duplicated keys are translated by the dialector, so concurrent registrations
+// of the same instance by several replicas fail with repo.ErrAlreadyExists.
+// Statements of traced operations are traced as children of their spans.
+func Open(dialector gorm.Dialector, bal balancer.Balancer) (*Repository, error) {
+	conn, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	if err = conn.Use(tracing.Gorm()); err != nil {
+		return nil, fmt.Errorf("failed to trace the database: %w", err)
+	}
+	if err = conn.AutoMigrate(&md.Service{}, &lease{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate the database: %w", err)
+	}
+
+	return &Repository{
+		conn:     conn,
+		balancer: bal,
+	}, nil
 }
 
 func (r *Repository) Close() error {
@@ -47,81 +120,102 @@ func (r *Repository) Close() error {
 	return nil
 }
 
-func (r *Repository) Register(ctx context.Context, name, addr string) error {
-	var svc md.Service
+func (r *Repository) Register(ctx context.Context, svc *md.Service) error {
+	return r.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing md.Service
+		if err := tx.Where("name = ? AND address = ?", svc.Name, svc.Address).
+			First(&existing).Error; err == nil {
+			return repo.ErrAlreadyExists
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
 
-	if err := r.conn.WithContext(ctx).
-		Where("name = ? AND address = ?", name, addr).
-		First(&svc).Error; err == nil {
-		return repo.ErrAlreadyExists
-	}
+		service := md.Service{
+			Name:     svc.Name,
+			Address:  svc.Address,
+			IsActive: true,
+			Weight:   svc.Weight,
+			Version:  svc.Version,
+			Tags:     svc.Tags,
+			Metadata: svc.Metadata,
+			TTL:      svc.TTL,
+			Check:    svc.Check,
 
-	service := md.Service{Name: name, Address: addr}
-	if err := r.conn.WithContext(ctx).Create(&service).Error; err != nil {
-		return err
-	}
-	return nil
+			LastHeartbeat: time.Now(),
+		}
+		if err := tx.Create(&service).Error; err != nil && errors.Is(err, gorm.ErrDuplicatedKey) {
+			return repo.ErrAlreadyExists
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 func (r *Repository) Deregister(ctx context.Context, name, addr string) error {
-	var service md.Service
-
-	if err := r.conn.WithContext(ctx).
-		Where("name = ? AND address = ?", name, addr).
-		First(&service).Error; err != nil {
-		return repo.ErrNotFound
-	}
+	return r.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var service md.Service
+		if err := tx.Where("name = ? AND address = ?", name, addr).
+			First(&service).Error; err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+			return repo.ErrNotFound
+		} else if err != nil {
+			return err
+		}
 
-	if err := r.conn.WithContext(ctx).Delete(&service).Error; err != nil {
-		return err
-	}
-	return nil
+		return tx.Delete(&service).Error
+	})
 }
 
-func (r *Repository) FindServiceByName(ctx context.Context, name string) (string, error) {
-	var svcs []md.Service
+func (r *Repository) FindServiceByName(ctx context.Context, name string, sel md.Selector, key string) (string, error) {
+	var all []md.Service
 
 	if err := r.conn.WithContext(ctx).
 		Where("name = ? AND is_active = true", name).
-		Find(&svcs).Error; err != nil || len(svcs) == 0 {
+		Find(&all).Error; err != nil {
 		return "", repo.ErrNotFound
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	svcs := filter(all, sel)
+	if len(svcs) == 0 {
+		return "", repo.ErrNotFound
+	}
 
-	currentIndex := r.rrIndex[name]
-	selectedAddr := svcs[currentIndex].Address
+	selectedSvc, err := r.balancer.Pick(name, key, svcs)
+	if errors.Is(err, balancer.ErrNoInstances) {
+		return "", repo.ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
 
-	r.rrIndex[name] = (currentIndex + 1) % len(svcs)
-	return selectedAddr, nil
+	return selectedSvc.Address, nil
 }
 
 func (r *Repository) ListServices(ctx context.Context) ([]string, error) {
-	var svcs []md.Service
+	names := make([]string, 0)
 	if err := r.conn.WithContext(ctx).
-		Find(&svcs).Error; err != nil {
+		Model(&md.Service{}).
+		Distinct("name").
+		Order("name").
+		Pluck("name", &names).Error; err != nil {
 		return nil, err
 	}
 
-	names := make([]string, len(svcs))
-	for i, svc := range svcs {
-		names[i] = svc.Name
-	}
-
 	return names, nil
 }
 
-func (r *Repository) ListAddrs(ctx context.Context, name string) ([]string, error) {
-	var svcs []md.Service
+func (r *Repository) ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error) {
+	var all []md.Service
 	if err := r.conn.WithContext(ctx).
-		Where("name = ?", name).
-		Find(&svcs).Error; err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, repo.ErrNotFound
-	} else if err != nil {
+		Where("name = ? AND is_active = true AND weight > 0", name).
+		Find(&all).Error; err != nil {
 		return nil, err
 	}
 
+	svcs := filter(all, sel)
+	if len(svcs) == 0 {
+		return []string{}, repo.ErrNotFound
+	}
+
 	addrs := make([]string, len(svcs))
 	for i, svc := range svcs {
 		addrs[i] = svc.Address
@@ -130,39 +224,138 @@ func (r *Repository) ListAddrs(ctx context.Context, name string) ([]string, erro
 	return addrs, nil
 }
 
-func (r *Repository) DeactivateSvc(ctx context.Context, name, addr string) error {
-	var svc md.Service
+// ListInstances returns every registered instance, including inactive ones.
+func (r *Repository) ListInstances(ctx context.Context) ([]md.Service, error) {
+	var svcs []md.Service
+	if err := r.conn.WithContext(ctx).
+		Order("id").
+		Find(&svcs).Error; err != nil {
+		return nil, err
+	}
+
+	return svcs, nil
+}
+
+// Restore writes the given instances as they are in a single transaction,
+// overwriting registered ones with the same name and address.
+// With replace every other instance is deregistered.
+func (r *Repository) Restore(ctx context.Context, svcs []md.Service, replace bool) error {
+	return r.conn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if replace {
+			if err := tx.Where("1 = 1").Delete(&md.Service{}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, svc := range svcs {
+			if err := tx.Where("name = ? AND address = ?", svc.Name, svc.Address).
+				Delete(&md.Service{}).Error; err != nil {
+				return err
+			}
+
+			service := svc
+			service.Model = gorm.Model{}
+			if err := tx.Create(&service).Error; err != nil {
+				return err
+			}
 
+			// Zero weight is replaced by the column default on insert
+			if svc.Weight == 0 {
+				if err := tx.Model(&service).Update("weight", 0).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) Heartbeat(ctx context.Context, name, addr string) error {
+	res := r.conn.WithContext(ctx).
+		Model(&md.Service{}).
+		Where("name = ? AND address = ?", name, addr).
+		Update("last_heartbeat", time.Now())
+	if res.Error != nil {
+		return res.Error
+	} else if res.RowsAffected == 0 {
+		return repo.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) GetService(ctx context.Context, name, addr string) (*md.Service, error) {
+	var svc md.Service
 	if err := r.conn.WithContext(ctx).
 		Where("name = ? AND address = ?", name, addr).
 		First(&svc).Error; err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
-		return repo.ErrNotFound
+		return nil, repo.ErrNotFound
 	} else if err != nil {
-		return err
+		return nil, err
 	}
 
-	svc.IsActive = false
-	if err := r.conn.WithContext(ctx).Save(&svc).Error; err != nil {
-		return err
+	return &svc, nil
+}
+
+func (r *Repository) UpdateWeight(ctx context.Context, name, addr string, weight int) error {
+	res := r.conn.WithContext(ctx).
+		Model(&md.Service{}).
+		Where("name = ? AND address = ?", name, addr).
+		Update("weight", weight)
+	if res.Error != nil {
+		return res.Error
+	} else if res.RowsAffected == 0 {
+		return repo.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) SetHealth(ctx context.Context, name, addr string, health md.Health) error {
+	res := r.conn.WithContext(ctx).
+		Model(&md.Service{}).
+		Where("name = ? AND address = ?", name, addr).
+		Select("health").
+		Updates(&md.Service{Health: health})
+	if res.Error != nil {
+		return res.Error
+	} else if res.RowsAffected == 0 {
+		return repo.ErrNotFound
 	}
 
 	return nil
 }
 
+func (r *Repository) DeactivateSvc(ctx context.Context, name, addr string) error {
+	return r.setActive(ctx, name, addr, false)
+}
+
 func (r *Repository) ActivateSvc(ctx context.Context, name, addr string) error {
-	var svc md.Service
+	return r.setActive(ctx, name, addr, true)
+}
 
-	if err := r.conn.WithContext(ctx).
+// setActive writes the column alone, so concurrent heartbeats and weight updates are kept.
+func (r *Repository) setActive(ctx context.Context, name, addr string, active bool) error {
+	res := r.conn.WithContext(ctx).
+		Model(&md.Service{}).
 		Where("name = ? AND address = ?", name, addr).
-		First(&svc).Error; err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+		Update("is_active", active)
+	if res.Error != nil {
+		return res.Error
+	} else if res.RowsAffected == 0 {
 		return repo.ErrNotFound
-	} else if err != nil {
-		return err
 	}
 
-	svc.IsActive = true
-	if err := r.conn.WithContext(ctx).Save(&svc).Error; err != nil {
-		return err
-	}
 	return nil
 }
+
+// filter is applied in Go since tags and metadata are stored as JSON columns
+func filter(svcs []md.Service, sel md.Selector) []md.Service {
+	res := make([]md.Service, 0, len(svcs))
+	for i := range svcs {
+		if sel.Matches(&svcs[i]) {
+			res = append(res, svcs[i])
+		}
+	}
+	return res
+}
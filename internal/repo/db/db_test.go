@@ -1,20 +1,319 @@
 package db
 
-//func TestRegister(t *testing.T) {
-//	mockDB, mock, err := sqlmock.New()
-//	assert.NoError(t, err)
-//	defer mockDB.Close()
-//
-//	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-//	assert.NoError(t, err)
-//
-//	if err = db.AutoMigrate(&md.Service{}); err != nil {
-//		zap.L().Fatal("failed to migrate the database", zap.Error(err))
-//	}
-//
-//	repo := Repository{conn: db}
-//
-//	t.Run("Success case", func(t *testing.T) {
-//
-//	})
-//}
+import (
+	"context"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/repo/repotest"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestRepo(t *testing.T) *Repository {
+	r, err := New(&config.SQLiteConfig{Path: ":memory:"}, repotest.Balancer())
+	assert.NoError(t, err)
+
+	return r
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Configured path and pragmas", func(t *testing.T) {
+		path := filepath.Join(dir, "data", "discovery.db")
+		r, err := New(&config.SQLiteConfig{
+			Path:        path,
+			JournalMode: "WAL",
+			BusyTimeout: 1234,
+			Pragmas:     map[string]string{"synchronous": "NORMAL"},
+		}, balancer.NewRoundRobin())
+		assert.NoError(t, err)
+		defer r.Close()
+
+		assert.FileExists(t, path)
+
+		var mode string
+		assert.NoError(t, r.conn.Raw("PRAGMA journal_mode").Scan(&mode).Error)
+		assert.Equal(t, "wal", mode)
+
+		var timeout, synchronous int
+		assert.NoError(t, r.conn.Raw("PRAGMA busy_timeout").Scan(&timeout).Error)
+		assert.Equal(t, 1234, timeout)
+		assert.NoError(t, r.conn.Raw("PRAGMA synchronous").Scan(&synchronous).Error)
+		assert.Equal(t, 1, synchronous)
+	})
+
+	t.Run("Invalid path", func(t *testing.T) {
+		file := filepath.Join(dir, "file")
+		assert.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		_, err := New(&config.SQLiteConfig{Path: filepath.Join(file, "discovery.db")}, balancer.NewRoundRobin())
+		assert.Error(t, err)
+	})
+}
+
+func TestDSN(t *testing.T) {
+	assert.Equal(t, "discovery.db", dsn("discovery.db", &config.SQLiteConfig{}))
+	assert.Equal(
+		t,
+		"/data/discovery.db?_pragma=busy_timeout%285000%29&_pragma=journal_mode%28WAL%29&_pragma=foreign_keys%281%29&_pragma=synchronous%28NORMAL%29",
+		dsn("/data/discovery.db", &config.SQLiteConfig{
+			JournalMode: "WAL",
+			BusyTimeout: 5000,
+			Pragmas:     map[string]string{"synchronous": "NORMAL", "foreign_keys": "1"},
+		}),
+	)
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	t.Run("Persist metadata", func(t *testing.T) {
+		err := r.Register(ctx, &md.Service{
+			Name:     "orders",
+			Address:  "addr1",
+			Version:  "v2",
+			Tags:     []string{"canary", "grpc"},
+			Metadata: map[string]string{"zone": "eu-1"},
+			Check:    &md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{200, 204}},
+		})
+		assert.NoError(t, err)
+
+		var svc md.Service
+		assert.NoError(t, r.conn.Where("name = ? AND address = ?", "orders", "addr1").First(&svc).Error)
+		assert.Equal(t, "v2", svc.Version)
+		assert.Equal(t, []string{"canary", "grpc"}, svc.Tags)
+		assert.Equal(t, map[string]string{"zone": "eu-1"}, svc.Metadata)
+		assert.Equal(t, &md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{200, 204}}, svc.Check)
+	})
+
+	t.Run("Unique name and address", func(t *testing.T) {
+		assert.Equal(t, repo.ErrAlreadyExists, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+
+		err := r.conn.Create(&md.Service{Name: "orders", Address: "addr1"}).Error
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr1"}))
+	})
+
+	t.Run("Register again after deregister", func(t *testing.T) {
+		assert.NoError(t, r.Deregister(ctx, "orders", "addr1"))
+		assert.Equal(t, repo.ErrNotFound, r.Deregister(ctx, "orders", "addr1"))
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+	})
+}
+
+func TestFindServiceByName(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	assert.NoError(t, r.conn.Create(&md.Service{Name: "orders", Address: "addr1", IsActive: true, Version: "v1"}).Error)
+	assert.NoError(t, r.conn.Create(&md.Service{Name: "orders", Address: "addr2", IsActive: true, Version: "v2", Tags: []string{"canary"}}).Error)
+
+	t.Run("Filter by selector", func(t *testing.T) {
+		addr, err := r.FindServiceByName(ctx, "orders", md.Selector{Labels: map[string]string{"version": "v2"}}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "addr2", addr)
+
+		addrs, err := r.ListAddrs(ctx, "orders", md.Selector{Tags: []string{"canary"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"addr2"}, addrs)
+
+		_, err = r.FindServiceByName(ctx, "orders", md.Selector{Labels: map[string]string{"version": "v3"}}, "")
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+}
+
+func TestUpdateWeight(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Weight: 5}))
+
+	t.Run("Update weight", func(t *testing.T) {
+		var svc md.Service
+		assert.NoError(t, r.conn.Where("address = ?", "addr1").First(&svc).Error)
+		assert.Equal(t, 5, svc.Weight)
+
+		assert.NoError(t, r.UpdateWeight(ctx, "orders", "addr1", 0))
+		assert.NoError(t, r.conn.Where("address = ?", "addr1").First(&svc).Error)
+		assert.Equal(t, 0, svc.Weight)
+
+		assert.Equal(t, repo.ErrNotFound, r.UpdateWeight(ctx, "orders", "addr2", 1))
+	})
+}
+
+func TestSetHealth(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+
+	t.Run("Set health", func(t *testing.T) {
+		health := md.Health{
+			State:            md.HealthQuarantined,
+			Reason:           "flapping",
+			Successes:        1,
+			Since:            time.Now().UTC(),
+			QuarantinedUntil: time.Now().UTC().Add(time.Minute),
+		}
+		assert.NoError(t, r.SetHealth(ctx, "orders", "addr1", health))
+
+		svc, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.Equal(t, health.State, svc.Health.State)
+		assert.Equal(t, health.Reason, svc.Health.Reason)
+		assert.Equal(t, health.Successes, svc.Health.Successes)
+		assert.True(t, health.QuarantinedUntil.Equal(svc.Health.QuarantinedUntil))
+
+		assert.Equal(t, repo.ErrNotFound, r.SetHealth(ctx, "orders", "addr2", health))
+	})
+}
+
+func TestActivate(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", TTL: 10}))
+
+	var updates []string
+	assert.NoError(t, r.conn.Callback().Update().After("gorm:update").Register("test:updates", func(tx *gorm.DB) {
+		updates = append(updates, tx.Statement.SQL.String())
+	}))
+
+	// Test case 1: Only the state is written, concurrent heartbeats and weight updates are kept
+	assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr1"))
+	assert.NoError(t, r.ActivateSvc(ctx, "orders", "addr1"))
+	if assert.Len(t, updates, 2) {
+		for _, update := range updates {
+			assert.Contains(t, update, "`is_active`")
+			assert.NotContains(t, update, "`last_heartbeat`")
+			assert.NotContains(t, update, "`weight`")
+		}
+	}
+
+	// Test case 2: Unknown instance
+	assert.Equal(t, repo.ErrNotFound, r.DeactivateSvc(ctx, "orders", "addr2"))
+	assert.Equal(t, repo.ErrNotFound, r.ActivateSvc(ctx, "orders", "addr2"))
+}
+
+func TestListInstances(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr2"}))
+	assert.NoError(t, r.DeactivateSvc(ctx, "payments", "addr2"))
+
+	svcs, err := r.ListInstances(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, svcs, 2)
+	assert.Equal(t, "addr1", svcs[0].Address)
+	assert.Equal(t, "addr2", svcs[1].Address)
+}
+
+func TestHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "worker", Address: "addr1", TTL: 10}))
+
+	t.Run("Renew lease", func(t *testing.T) {
+		svc, err := r.GetService(ctx, "worker", "addr1")
+		assert.NoError(t, err)
+		assert.Equal(t, 10, svc.TTL)
+		assert.False(t, svc.LeaseExpired(time.Now()))
+
+		last := svc.LastHeartbeat
+		assert.NoError(t, r.Heartbeat(ctx, "worker", "addr1"))
+
+		svc, err = r.GetService(ctx, "worker", "addr1")
+		assert.NoError(t, err)
+		assert.True(t, svc.LastHeartbeat.After(last))
+
+		assert.Equal(t, repo.ErrNotFound, r.Heartbeat(ctx, "worker", "addr2"))
+
+		_, err = r.GetService(ctx, "worker", "addr2")
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) ctrl.ServiceDiscoveryRepo {
+		return newTestRepo(t)
+	})
+}
+
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	defer r.Close()
+
+	now := time.Now()
+	a := r.Lease("checker", "a", time.Minute)
+	b := r.Lease("checker", "b", time.Minute)
+
+	// Test case 1: The first holder takes the lease and renews it
+	held, err := a.acquire(ctx, now)
+	assert.NoError(t, err)
+	assert.True(t, held)
+	held, err = a.acquire(ctx, now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.True(t, held)
+
+	// Test case 2: Others wait until it expires
+	held, err = b.acquire(ctx, now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.False(t, held)
+	held, err = b.acquire(ctx, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+
+	// Test case 3: A released lease is taken over right away
+	held, err = a.acquire(ctx, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, held)
+	b.release()
+	held, err = a.acquire(ctx, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+}
+
+func TestLeaseCampaign(t *testing.T) {
+	r := newTestRepo(t)
+	defer r.Close()
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	a := r.Lease("checker", "a", 150*time.Millisecond).Campaign(ctxA)
+	assert.True(t, <-a)
+
+	b := r.Lease("checker", "b", 150*time.Millisecond).Campaign(ctxB)
+	select {
+	case <-b:
+		t.Fatal("lease taken while held")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// The leader steps down, the standby takes over
+	cancelA()
+	for range a {
+	}
+	select {
+	case leading := <-b:
+		assert.True(t, leading)
+	case <-time.After(time.Second):
+		t.Fatal("lease not taken over")
+	}
+}
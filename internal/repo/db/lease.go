@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// lease is a row held by the replica running a singleton job, until ExpiresAt.
+type lease struct {
+	Name      string `gorm:"primaryKey"`
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// Lease elects one holder among the replicas sharing the database.
+type Lease struct {
+	repo   *Repository
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+// Lease returns the lease name, taken by holder for ttl and renewed every third of it.
+func (r *Repository) Lease(name, holder string, ttl time.Duration) *Lease {
+	return &Lease{repo: r, name: name, holder: holder, ttl: ttl}
+}
+
+// acquire takes the lease when it is free or expired, and renews it when already held.
+func (l *Lease) acquire(ctx context.Context, now time.Time) (bool, error) {
+	db := l.repo.conn.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&lease{Name: l.name, Holder: l.holder, ExpiresAt: now.Add(l.ttl)}).Error
+	if err != nil {
+		return false, err
+	}
+
+	res := db.Model(&lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", l.name, l.holder, now).
+		Updates(map[string]any{"holder": l.holder, "expires_at": now.Add(l.ttl)})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// release expires the lease, so another replica takes over without waiting for the ttl.
+func (l *Lease) release() {
+	err := l.repo.conn.Model(&lease{}).
+		Where("name = ? AND holder = ?", l.name, l.holder).
+		Update("expires_at", time.Time{}).Error
+	if err != nil {
+		zap.L().Debug("failed to release the lease", zap.String("lease", l.name), zap.Error(err))
+	}
+}
+
+// Campaign sends true once the lease is taken and false once it is lost.
+// The lease is released and the channel closed once ctx is done.
+func (l *Lease) Campaign(ctx context.Context) <-chan bool {
+	ch := make(chan bool, 1)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		var leading bool
+		var expires time.Time
+		for {
+			now := time.Now()
+			held, err := l.acquire(ctx, now)
+			if err != nil {
+				// The database may be back before the lease expires, keep leading until then
+				zap.L().Debug("failed to renew the lease", zap.String("lease", l.name), zap.Error(err))
+				held = leading && now.Before(expires.Add(-l.ttl/3))
+			} else if held {
+				expires = now.Add(l.ttl)
+			}
+
+			if held != leading {
+				leading = held
+				select {
+				case ch <- leading:
+				case <-ctx.Done():
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				if leading {
+					l.release()
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch
+}
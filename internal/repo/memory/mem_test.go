@@ -2,23 +2,28 @@ package memory
 
 import (
 	"context"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/ctrl"
 	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/repo/repotest"
+	md "github.com/JMURv/service-discovery/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestRepository(t *testing.T) {
 	ctx := context.Background()
-	r := New()
+	r := New(balancer.NewRoundRobin())
 
 	t.Run("Register services", func(t *testing.T) {
-		err := r.Register(ctx, "service1", "addr1")
+		err := r.Register(ctx, &md.Service{Name: "service1", Address: "addr1"})
 		assert.NoError(t, err)
 
-		err = r.Register(ctx, "service1", "addr1")
+		err = r.Register(ctx, &md.Service{Name: "service1", Address: "addr1"})
 		assert.Equal(t, repo.ErrAlreadyExists, err)
 
-		err = r.Register(ctx, "service1", "addr2")
+		err = r.Register(ctx, &md.Service{Name: "service1", Address: "addr2"})
 		assert.NoError(t, err)
 	})
 
@@ -35,30 +40,30 @@ func TestRepository(t *testing.T) {
 		err = r.Deregister(ctx, "service1", "addr2")
 		assert.NoError(t, err)
 
-		_, err = r.FindServiceByName(ctx, "service1")
+		_, err = r.FindServiceByName(ctx, "service1", md.Selector{}, "")
 		assert.Equal(t, repo.ErrNotFound, err)
 	})
 
 	t.Run("Find service with round-robin", func(t *testing.T) {
-		r.Register(ctx, "service2", "addr3")
-		r.Register(ctx, "service2", "addr4")
+		r.Register(ctx, &md.Service{Name: "service2", Address: "addr3"})
+		r.Register(ctx, &md.Service{Name: "service2", Address: "addr4"})
 
-		addr, err := r.FindServiceByName(ctx, "service2")
+		addr, err := r.FindServiceByName(ctx, "service2", md.Selector{}, "")
 		assert.NoError(t, err)
 		assert.Equal(t, "addr3", addr)
 
-		addr, err = r.FindServiceByName(ctx, "service2")
+		addr, err = r.FindServiceByName(ctx, "service2", md.Selector{}, "")
 		assert.NoError(t, err)
 		assert.Equal(t, "addr4", addr)
 
-		addr, err = r.FindServiceByName(ctx, "service2")
+		addr, err = r.FindServiceByName(ctx, "service2", md.Selector{}, "")
 		assert.NoError(t, err)
 		assert.Equal(t, "addr3", addr)
 	})
 
 	t.Run("List services", func(t *testing.T) {
-		r.Register(ctx, "service3", "addr5")
-		r.Register(ctx, "service4", "addr6")
+		r.Register(ctx, &md.Service{Name: "service3", Address: "addr5"})
+		r.Register(ctx, &md.Service{Name: "service4", Address: "addr6"})
 
 		services, err := r.ListServices(ctx)
 		assert.NoError(t, err)
@@ -66,22 +71,22 @@ func TestRepository(t *testing.T) {
 	})
 
 	t.Run("List addresses for a service", func(t *testing.T) {
-		addrs, err := r.ListAddrs(ctx, "service2")
+		addrs, err := r.ListAddrs(ctx, "service2", md.Selector{})
 		assert.NoError(t, err)
 		assert.ElementsMatch(t, []string{"addr3", "addr4"}, addrs)
 
-		addrs, err = r.ListAddrs(ctx, "non-existing-service")
+		addrs, err = r.ListAddrs(ctx, "non-existing-service", md.Selector{})
 		assert.Equal(t, repo.ErrNotFound, err)
 		assert.Empty(t, addrs)
 	})
 
 	t.Run("Deactivate service", func(t *testing.T) {
-		r.Register(ctx, "service5", "addr7")
+		r.Register(ctx, &md.Service{Name: "service5", Address: "addr7"})
 
 		err := r.DeactivateSvc(ctx, "service5", "addr7")
 		assert.NoError(t, err)
 
-		addr, err := r.FindServiceByName(ctx, "service5")
+		addr, err := r.FindServiceByName(ctx, "service5", md.Selector{}, "")
 		assert.Equal(t, repo.ErrNotFound, err)
 		assert.Empty(t, addr)
 
@@ -91,29 +96,29 @@ func TestRepository(t *testing.T) {
 		err = r.DeactivateSvc(ctx, "service5", "non-existing-addr")
 		assert.Equal(t, repo.ErrNotFound, err)
 
-		err = r.Register(ctx, "service5", "addr8")
+		err = r.Register(ctx, &md.Service{Name: "service5", Address: "addr8"})
 		assert.NoError(t, err)
 
 		err = r.DeactivateSvc(ctx, "service5", "addr8")
 		assert.NoError(t, err)
 
-		addr, err = r.FindServiceByName(ctx, "service5")
+		addr, err = r.FindServiceByName(ctx, "service5", md.Selector{}, "")
 		assert.Equal(t, repo.ErrNotFound, err)
 	})
 
 	t.Run("Activate service", func(t *testing.T) {
-		r.Register(ctx, "service6", "addr9")
+		r.Register(ctx, &md.Service{Name: "service6", Address: "addr9"})
 		err := r.DeactivateSvc(ctx, "service6", "addr9")
 		assert.NoError(t, err)
 
-		addr, err := r.FindServiceByName(ctx, "service6")
+		addr, err := r.FindServiceByName(ctx, "service6", md.Selector{}, "")
 		assert.Equal(t, repo.ErrNotFound, err)
 		assert.Empty(t, addr)
 
 		err = r.ActivateSvc(ctx, "service6", "addr9")
 		assert.NoError(t, err)
 
-		addr, err = r.FindServiceByName(ctx, "service6")
+		addr, err = r.FindServiceByName(ctx, "service6", md.Selector{}, "")
 		assert.NoError(t, err)
 		assert.Equal(t, "addr9", addr)
 
@@ -124,9 +129,112 @@ func TestRepository(t *testing.T) {
 		assert.Equal(t, repo.ErrNotFound, err)
 	})
 
+	t.Run("Persist metadata", func(t *testing.T) {
+		err := r.Register(ctx, &md.Service{
+			Name:     "service7",
+			Address:  "addr10",
+			Version:  "v2",
+			Tags:     []string{"canary"},
+			Metadata: map[string]string{"zone": "eu-1"},
+		})
+		assert.NoError(t, err)
+
+		svc := r.services[len(r.services)-1]
+		assert.Equal(t, "v2", svc.Version)
+		assert.Equal(t, []string{"canary"}, svc.Tags)
+		assert.Equal(t, map[string]string{"zone": "eu-1"}, svc.Metadata)
+	})
+
+	t.Run("Filter by selector", func(t *testing.T) {
+		r.Register(ctx, &md.Service{Name: "service8", Address: "addr11", Version: "v1", Metadata: map[string]string{"zone": "eu-1"}})
+		r.Register(ctx, &md.Service{Name: "service8", Address: "addr12", Version: "v2", Metadata: map[string]string{"zone": "eu-1"}, Tags: []string{"canary"}})
+		r.Register(ctx, &md.Service{Name: "service8", Address: "addr13", Version: "v2", Metadata: map[string]string{"zone": "us-1"}})
+
+		addrs, err := r.ListAddrs(ctx, "service8", md.Selector{Labels: map[string]string{"version": "v2"}})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"addr12", "addr13"}, addrs)
+
+		addrs, err = r.ListAddrs(ctx, "service8", md.Selector{Labels: map[string]string{"version": "v2", "zone": "eu-1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"addr12"}, addrs)
+
+		addr, err := r.FindServiceByName(ctx, "service8", md.Selector{Tags: []string{"canary"}}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "addr12", addr)
+
+		addr, err = r.FindServiceByName(ctx, "service8", md.Selector{Labels: map[string]string{"zone": "ap-1"}}, "")
+		assert.Equal(t, repo.ErrNotFound, err)
+		assert.Empty(t, addr)
+	})
+
+	t.Run("Update weight", func(t *testing.T) {
+		err := r.UpdateWeight(ctx, "service8", "addr12", 0)
+		assert.NoError(t, err)
+
+		// Drained instances are not listed
+		addrs, err := r.ListAddrs(ctx, "service8", md.Selector{Tags: []string{"canary"}})
+		assert.Equal(t, repo.ErrNotFound, err)
+		assert.Empty(t, addrs)
+		assert.Equal(t, 0, r.services[len(r.services)-2].Weight)
+
+		err = r.UpdateWeight(ctx, "service8", "non-existing-addr", 1)
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		err := r.Register(ctx, &md.Service{Name: "service9", Address: "addr14", TTL: 1})
+		assert.NoError(t, err)
+
+		svc, err := r.GetService(ctx, "service9", "addr14")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, svc.TTL)
+		assert.False(t, svc.LeaseExpired(time.Now()))
+		assert.True(t, svc.LeaseExpired(time.Now().Add(2*time.Second)))
+
+		last := svc.LastHeartbeat
+		assert.NoError(t, r.Heartbeat(ctx, "service9", "addr14"))
+
+		svc, err = r.GetService(ctx, "service9", "addr14")
+		assert.NoError(t, err)
+		assert.True(t, svc.LastHeartbeat.After(last))
+
+		err = r.Heartbeat(ctx, "service9", "non-existing-addr")
+		assert.Equal(t, repo.ErrNotFound, err)
+
+		_, err = r.GetService(ctx, "service9", "non-existing-addr")
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("Set health", func(t *testing.T) {
+		health := md.Health{State: md.HealthFailing, Reason: "2 consecutive failed checks", Failures: 2}
+		assert.NoError(t, r.SetHealth(ctx, "service9", "addr14", health))
+
+		svc, err := r.GetService(ctx, "service9", "addr14")
+		assert.NoError(t, err)
+		assert.Equal(t, health, svc.Health)
+
+		err = r.SetHealth(ctx, "service9", "non-existing-addr", health)
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("List instances", func(t *testing.T) {
+		assert.NoError(t, r.DeactivateSvc(ctx, "service9", "addr14"))
+
+		svcs, err := r.ListInstances(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, svcs, len(r.services))
+		assert.Contains(t, svcs, r.services[len(r.services)-1])
+	})
+
 	t.Run("Close", func(t *testing.T) {
 		err := r.Close()
 		assert.Nil(t, err)
 	})
 
 }
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) ctrl.ServiceDiscoveryRepo {
+		return New(repotest.Balancer())
+	})
+}
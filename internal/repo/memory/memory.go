@@ -2,44 +2,89 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"github.com/JMURv/service-discovery/internal/balancer"
 	"github.com/JMURv/service-discovery/internal/repo"
 	md "github.com/JMURv/service-discovery/pkg/model"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 )
 
 type Repository struct {
 	sync.RWMutex
 	services []md.Service
-	rrIndex  map[string]int
+	balancer balancer.Balancer
+
+	// Set by Open when the registry is persisted to a snapshot file
+	path      string
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func New() *Repository {
+func New(bal balancer.Balancer) *Repository {
 	return &Repository{
 		services: make([]md.Service, 0, 10),
-		rrIndex:  make(map[string]int),
+		balancer: bal,
 	}
 }
 
+// Close writes a final snapshot when the registry is persisted.
 func (r *Repository) Close() error {
-	r.Lock()
-	defer r.Unlock()
+	var err error
+	r.closeOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+			<-r.done
+		}
+		if r.path != "" {
+			err = r.save()
+		}
 
-	r.services = nil
-	r.rrIndex = nil
-	return nil
+		r.Lock()
+		r.services = nil
+		r.Unlock()
+	})
+	return err
+}
+
+func (r *Repository) Register(ctx context.Context, svc *md.Service) error {
+	return r.RegisterAt(ctx, svc, time.Now())
 }
 
-func (r *Repository) Register(_ context.Context, name, addr string) error {
+// RegisterAt registers an instance with its first heartbeat at the given time,
+// replicas take it from the replicated command rather than their own clock.
+func (r *Repository) RegisterAt(_ context.Context, svc *md.Service, at time.Time) error {
 	r.Lock()
 	defer r.Unlock()
 
 	for _, registered := range r.services {
-		if registered.Address == addr {
+		if registered.Name == svc.Name && registered.Address == svc.Address {
 			return repo.ErrAlreadyExists
 		}
 	}
 
-	r.services = append(r.services, md.Service{Name: name, Address: addr, IsActive: true})
+	// Zero weight gets the default, as the column default does in the db repository
+	weight := svc.Weight
+	if weight == 0 {
+		weight = md.DefaultWeight
+	}
+
+	r.services = append(r.services, md.Service{
+		Name:     svc.Name,
+		Address:  svc.Address,
+		IsActive: true,
+		Weight:   weight,
+		Version:  svc.Version,
+		Tags:     svc.Tags,
+		Metadata: svc.Metadata,
+		TTL:      svc.TTL,
+		Check:    svc.Check,
+
+		LastHeartbeat: at,
+	})
 	return nil
 }
 
@@ -50,7 +95,6 @@ func (r *Repository) Deregister(_ context.Context, name, addr string) error {
 	for i, v := range r.services {
 		if v.Name == name && v.Address == addr {
 			r.services = append(r.services[:i], r.services[i+1:]...)
-			delete(r.rrIndex, name)
 			return nil
 		}
 	}
@@ -58,13 +102,13 @@ func (r *Repository) Deregister(_ context.Context, name, addr string) error {
 	return repo.ErrNotFound
 }
 
-func (r *Repository) FindServiceByName(_ context.Context, name string) (string, error) {
+func (r *Repository) FindServiceByName(_ context.Context, name string, sel md.Selector, key string) (string, error) {
 	r.RLock()
 	defer r.RUnlock()
 
 	var availableServices []md.Service
 	for _, svc := range r.services {
-		if svc.Name == name && svc.IsActive {
+		if svc.Name == name && svc.IsActive && sel.Matches(&svc) {
 			availableServices = append(availableServices, svc)
 		}
 	}
@@ -73,10 +117,13 @@ func (r *Repository) FindServiceByName(_ context.Context, name string) (string,
 		return "", repo.ErrNotFound
 	}
 
-	currentIndex := r.rrIndex[name]
-	selectedSvc := availableServices[currentIndex]
+	selectedSvc, err := r.balancer.Pick(name, key, availableServices)
+	if errors.Is(err, balancer.ErrNoInstances) {
+		return "", repo.ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
 
-	r.rrIndex[name] = (currentIndex + 1) % len(availableServices)
 	return selectedSvc.Address, nil
 }
 
@@ -89,21 +136,16 @@ func (r *Repository) ListServices(_ context.Context) ([]string, error) {
 		namesMap[svc.Name] = struct{}{}
 	}
 
-	names := make([]string, 0, len(namesMap))
-	for name := range namesMap {
-		names = append(names, name)
-	}
-
-	return names, nil
+	return slices.Sorted(maps.Keys(namesMap)), nil
 }
 
-func (r *Repository) ListAddrs(_ context.Context, name string) ([]string, error) {
+func (r *Repository) ListAddrs(_ context.Context, name string, sel md.Selector) ([]string, error) {
 	r.RLock()
 	defer r.RUnlock()
 
 	var addrs []string
 	for _, svc := range r.services {
-		if svc.Name == name && svc.IsActive {
+		if svc.Name == name && svc.IsActive && svc.Weight > 0 && sel.Matches(&svc) {
 			addrs = append(addrs, svc.Address)
 		}
 	}
@@ -115,6 +157,100 @@ func (r *Repository) ListAddrs(_ context.Context, name string) ([]string, error)
 	return addrs, nil
 }
 
+// ListInstances returns every registered instance, including inactive ones.
+func (r *Repository) ListInstances(_ context.Context) ([]md.Service, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	svcs := make([]md.Service, len(r.services))
+	copy(svcs, r.services)
+	return svcs, nil
+}
+
+// Restore writes the given instances as they are, overwriting registered ones
+// with the same name and address. With replace every other instance is dropped.
+func (r *Repository) Restore(_ context.Context, svcs []md.Service, replace bool) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if replace {
+		r.services = make([]md.Service, 0, len(svcs))
+	}
+
+	for _, svc := range svcs {
+		idx := slices.IndexFunc(r.services, func(registered md.Service) bool {
+			return registered.Name == svc.Name && registered.Address == svc.Address
+		})
+		if idx == -1 {
+			r.services = append(r.services, svc)
+		} else {
+			r.services[idx] = svc
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) Heartbeat(ctx context.Context, name, addr string) error {
+	return r.HeartbeatAt(ctx, name, addr, time.Now())
+}
+
+// HeartbeatAt records a heartbeat received at the given time.
+func (r *Repository) HeartbeatAt(_ context.Context, name, addr string, at time.Time) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, svc := range r.services {
+		if svc.Name == name && svc.Address == addr {
+			r.services[i].LastHeartbeat = at
+			return nil
+		}
+	}
+
+	return repo.ErrNotFound
+}
+
+func (r *Repository) GetService(_ context.Context, name, addr string) (*md.Service, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, svc := range r.services {
+		if svc.Name == name && svc.Address == addr {
+			return &svc, nil
+		}
+	}
+
+	return nil, repo.ErrNotFound
+}
+
+func (r *Repository) UpdateWeight(_ context.Context, name, addr string, weight int) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, svc := range r.services {
+		if svc.Name == name && svc.Address == addr {
+			r.services[i].Weight = weight
+			return nil
+		}
+	}
+
+	return repo.ErrNotFound
+}
+
+func (r *Repository) SetHealth(_ context.Context, name, addr string, health md.Health) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, svc := range r.services {
+		if svc.Name == name && svc.Address == addr {
+			r.services[i].Health = health
+			return nil
+		}
+	}
+
+	return repo.ErrNotFound
+}
+
 func (r *Repository) DeactivateSvc(_ context.Context, name, addr string) error {
 	r.Lock()
 	defer r.Unlock()
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/snapshot"
+	"github.com/JMURv/service-discovery/internal/validation"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Open returns a repository persisted to the configured snapshot file.
+// The file is reloaded here, rewritten every interval and on Close.
+// It uses the snapshot format, so it can be imported into any other backend.
+func Open(conf *config.MemoryConfig, bal balancer.Balancer) (*Repository, error) {
+	r := New(bal)
+	if conf == nil || conf.SnapshotPath == "" {
+		return r, nil
+	}
+
+	r.path = conf.SnapshotPath
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the snapshot directory: %w", err)
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	if conf.SnapshotInterval > 0 {
+		r.persist(time.Duration(conf.SnapshotInterval) * time.Second)
+	}
+	return r, nil
+}
+
+func (r *Repository) persist(interval time.Duration) {
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if err := r.save(); err != nil {
+					zap.L().Error("failed to write snapshot", zap.String("path", r.path), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// load fails on a corrupt snapshot rather than starting with an empty registry
+// that would overwrite it on the next save.
+func (r *Repository) load() error {
+	f, err := os.Open(r.path)
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	snap, err := snapshot.Decode(f, snapshot.JSON)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot %v: %w", r.path, err)
+	}
+	if err = validation.ValidateSnapshot(snap); err != nil {
+		return fmt.Errorf("failed to read snapshot %v: %w", r.path, err)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.services = snap.Instances(time.Now())
+	zap.L().Info("loaded snapshot", zap.String("path", r.path), zap.Int("instances", len(r.services)))
+	return nil
+}
+
+// save replaces the snapshot file atomically, so a crash while writing
+// leaves the previous snapshot in place.
+func (r *Repository) save() error {
+	r.RLock()
+	snap := md.NewSnapshot(r.services, time.Now().UTC())
+	r.RUnlock()
+
+	var buf bytes.Buffer
+	if err := snapshot.Encode(&buf, snap, snapshot.JSON); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.path)
+}
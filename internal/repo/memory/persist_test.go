@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/pkg/config"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data", "registry.json")
+	conf := &config.MemoryConfig{SnapshotPath: path}
+
+	t.Run("Without snapshot path", func(t *testing.T) {
+		r, err := Open(&config.MemoryConfig{}, balancer.NewRoundRobin())
+		assert.NoError(t, err)
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.NoError(t, r.Close())
+
+		_, err = os.Stat(path)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("Restore on startup", func(t *testing.T) {
+		r, err := Open(conf, balancer.NewRoundRobin())
+		assert.NoError(t, err)
+
+		health := md.Health{State: md.HealthFailing, Failures: 2}
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Weight: 1, Tags: []string{"canary"}}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2", Weight: 1}))
+		assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr2"))
+		assert.NoError(t, r.SetHealth(ctx, "orders", "addr2", health))
+		assert.NoError(t, r.Close())
+		assert.NoError(t, r.Close())
+
+		r, err = Open(conf, balancer.NewRoundRobin())
+		assert.NoError(t, err)
+		defer r.Close()
+
+		svc, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.True(t, svc.IsActive)
+		assert.Equal(t, []string{"canary"}, svc.Tags)
+
+		svc, err = r.GetService(ctx, "orders", "addr2")
+		assert.NoError(t, err)
+		assert.False(t, svc.IsActive)
+		assert.Equal(t, health, svc.Health)
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("Corrupt snapshot", func(t *testing.T) {
+		corrupt := filepath.Join(dir, "corrupt.json")
+		assert.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+		_, err := Open(&config.MemoryConfig{SnapshotPath: corrupt}, balancer.NewRoundRobin())
+		assert.Error(t, err)
+	})
+}
+
+func TestPersist(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	r, err := Open(&config.MemoryConfig{SnapshotPath: path}, balancer.NewRoundRobin())
+	assert.NoError(t, err)
+	r.persist(10 * time.Millisecond)
+	defer r.Close()
+
+	assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+
+	// Written without closing, as it would be before a crash
+	assert.Eventually(t, func() bool {
+		restored, err := Open(&config.MemoryConfig{SnapshotPath: path}, balancer.NewRoundRobin())
+		if err != nil {
+			return false
+		}
+		_, err = restored.GetService(ctx, "orders", "addr1")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
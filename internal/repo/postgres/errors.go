@@ -0,0 +1,5 @@
+package postgres
+
+import "errors"
+
+var ErrMissingConfig = errors.New("missing postgres configuration")
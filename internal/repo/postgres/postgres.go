@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/repo/db"
+	"github.com/JMURv/service-discovery/pkg/config"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// New connects to Postgres with the configured pool settings.
+// Queries are shared with the SQLite repository, several discovery replicas
+// may use the same database since (name, address) is unique within it.
+func New(conf *config.PostgresConfig, bal balancer.Balancer) (*db.Repository, error) {
+	if conf == nil {
+		return nil, ErrMissingConfig
+	}
+
+	conn, err := sql.Open("pgx", dsn(conf))
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxOpenConns(conf.MaxOpenConns)
+	conn.SetMaxIdleConns(conf.MaxIdleConns)
+	conn.SetConnMaxLifetime(time.Duration(conf.ConnMaxLifetime) * time.Second)
+	conn.SetConnMaxIdleTime(time.Duration(conf.ConnMaxIdleTime) * time.Second)
+
+	r, err := db.Open(postgres.New(postgres.Config{Conn: conn}), bal)
+	if err != nil {
+		if cerr := conn.Close(); cerr != nil {
+			return nil, fmt.Errorf("%w, failed to close connection: %v", err, cerr)
+		}
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func dsn(conf *config.PostgresConfig) string {
+	if conf.DSN != "" {
+		return conf.DSN
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(conf.User, conf.Password),
+		Host:   conf.Host + ":" + strconv.Itoa(conf.Port),
+		Path:   conf.Database,
+	}
+	if conf.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {conf.SSLMode}}.Encode()
+	}
+
+	return u.String()
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"database/sql"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/repo/repotest"
+	"github.com/JMURv/service-discovery/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	// Test case 1: Built from fields
+	assert.Equal(t, "postgres://discovery:p%40ss@db:5432/registry?sslmode=disable", dsn(&config.PostgresConfig{
+		Host:     "db",
+		Port:     5432,
+		User:     "discovery",
+		Password: "p@ss",
+		Database: "registry",
+		SSLMode:  "disable",
+	}))
+
+	// Test case 2: DSN takes precedence
+	assert.Equal(t, "host=db user=discovery", dsn(&config.PostgresConfig{DSN: "host=db user=discovery", Host: "other"}))
+}
+
+func TestNew(t *testing.T) {
+	// Test case 1: Missing configuration
+	_, err := New(nil, balancer.NewRoundRobin())
+	assert.Equal(t, ErrMissingConfig, err)
+
+	// Test case 2: Unreachable database
+	_, err = New(&config.PostgresConfig{DSN: "postgres://discovery@127.0.0.1:1/registry?connect_timeout=1"}, balancer.NewRoundRobin())
+	assert.Error(t, err)
+}
+
+// TestConformance runs against a disposable database given by POSTGRES_TEST_DSN
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	repotest.Run(t, func(t *testing.T) ctrl.ServiceDiscoveryRepo {
+		r, err := New(&config.PostgresConfig{DSN: dsn}, repotest.Balancer())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := sql.Open("pgx", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if _, err = conn.Exec("TRUNCATE TABLE services"); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	})
+}
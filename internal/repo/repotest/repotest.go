@@ -0,0 +1,307 @@
+// Package repotest is a conformance suite for ctrl.ServiceDiscoveryRepo implementations.
+// Every backend runs it from its own tests, so switching the db in configuration
+// does not change how instances are registered, listed or routed.
+package repotest
+
+import (
+	"context"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/repo"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty repository balancing with Balancer.
+type Factory func(t *testing.T) ctrl.ServiceDiscoveryRepo
+
+// Balancer returns the round-robin router the server balances with by default,
+// it leaves drained instances out.
+func Balancer() balancer.Balancer {
+	// Without configuration the router cannot fail
+	r, _ := balancer.New(nil)
+	return r
+}
+
+func Run(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+
+	t.Run("Register", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		check := &md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{200, 204}}
+		assert.NoError(t, r.Register(ctx, &md.Service{
+			Name:     "orders",
+			Address:  "addr1",
+			Weight:   3,
+			Version:  "v2",
+			Tags:     []string{"canary"},
+			Metadata: map[string]string{"zone": "eu-1"},
+			TTL:      10,
+			Check:    check,
+		}))
+
+		svc, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.True(t, svc.IsActive)
+		assert.Equal(t, 3, svc.Weight)
+		assert.Equal(t, "v2", svc.Version)
+		assert.Equal(t, []string{"canary"}, svc.Tags)
+		assert.Equal(t, map[string]string{"zone": "eu-1"}, svc.Metadata)
+		assert.Equal(t, 10, svc.TTL)
+		assert.Equal(t, check, svc.Check)
+		assert.WithinDuration(t, time.Now(), svc.LastHeartbeat, time.Minute)
+
+		_, err = r.GetService(ctx, "orders", "addr2")
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("Name and address are unique together", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.Equal(t, repo.ErrAlreadyExists, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr1"}))
+	})
+
+	t.Run("Deregister", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr1"}))
+
+		assert.NoError(t, r.Deregister(ctx, "orders", "addr1"))
+		assert.Equal(t, repo.ErrNotFound, r.Deregister(ctx, "orders", "addr1"))
+		assert.Equal(t, repo.ErrNotFound, r.Deregister(ctx, "unknown", "addr1"))
+
+		_, err := r.GetService(ctx, "orders", "addr1")
+		assert.Equal(t, repo.ErrNotFound, err)
+		_, err = r.GetService(ctx, "payments", "addr1")
+		assert.NoError(t, err)
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+	})
+
+	t.Run("List services", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		names, err := r.ListServices(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, names)
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr1"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr3"}))
+		assert.NoError(t, r.DeactivateSvc(ctx, "payments", "addr1"))
+
+		names, err = r.ListServices(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"orders", "payments"}, names)
+	})
+
+	t.Run("List addresses of active instances", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Version: "v1"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2", Version: "v2", Tags: []string{"canary"}}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr3", Version: "v2"}))
+		assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr3"))
+
+		addrs, err := r.ListAddrs(ctx, "orders", md.Selector{})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"addr1", "addr2"}, addrs)
+
+		addrs, err = r.ListAddrs(ctx, "orders", md.Selector{Labels: map[string]string{"version": "v2"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"addr2"}, addrs)
+
+		addrs, err = r.ListAddrs(ctx, "orders", md.Selector{Tags: []string{"stable"}})
+		assert.Equal(t, repo.ErrNotFound, err)
+		assert.Empty(t, addrs)
+
+		addrs, err = r.ListAddrs(ctx, "unknown", md.Selector{})
+		assert.Equal(t, repo.ErrNotFound, err)
+		assert.Empty(t, addrs)
+
+		assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr1"))
+		assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr2"))
+		_, err = r.ListAddrs(ctx, "orders", md.Selector{})
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("List instances", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		svcs, err := r.ListInstances(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, svcs)
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr2"}))
+		assert.NoError(t, r.DeactivateSvc(ctx, "payments", "addr2"))
+
+		svcs, err = r.ListInstances(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, svcs, 2)
+		assert.Equal(t, "addr1", svcs[0].Address)
+		assert.True(t, svcs[0].IsActive)
+		assert.Equal(t, "addr2", svcs[1].Address)
+		assert.False(t, svcs[1].IsActive)
+	})
+
+	t.Run("Find service", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		_, err := r.FindServiceByName(ctx, "orders", md.Selector{}, "")
+		assert.Equal(t, repo.ErrNotFound, err)
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Metadata: map[string]string{"zone": "eu-1"}}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2", Metadata: map[string]string{"zone": "us-1"}}))
+
+		seen := make(map[string]bool)
+		for i := 0; i < 4; i++ {
+			addr, err := r.FindServiceByName(ctx, "orders", md.Selector{}, "")
+			assert.NoError(t, err)
+			seen[addr] = true
+		}
+		assert.Equal(t, map[string]bool{"addr1": true, "addr2": true}, seen)
+
+		addr, err := r.FindServiceByName(ctx, "orders", md.Selector{Labels: map[string]string{"zone": "us-1"}}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "addr2", addr)
+
+		assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr2"))
+		for i := 0; i < 2; i++ {
+			addr, err = r.FindServiceByName(ctx, "orders", md.Selector{}, "")
+			assert.NoError(t, err)
+			assert.Equal(t, "addr1", addr)
+		}
+
+		_, err = r.FindServiceByName(ctx, "orders", md.Selector{Labels: map[string]string{"zone": "us-1"}}, "")
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("Drained instances", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2"}))
+		assert.NoError(t, r.UpdateWeight(ctx, "orders", "addr2", 0))
+
+		addrs, err := r.ListAddrs(ctx, "orders", md.Selector{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"addr1"}, addrs)
+		for i := 0; i < 2; i++ {
+			addr, err := r.FindServiceByName(ctx, "orders", md.Selector{}, "")
+			assert.NoError(t, err)
+			assert.Equal(t, "addr1", addr)
+		}
+
+		// Every instance drained is the same as none registered
+		assert.NoError(t, r.UpdateWeight(ctx, "orders", "addr1", 0))
+		addrs, err = r.ListAddrs(ctx, "orders", md.Selector{})
+		assert.Equal(t, repo.ErrNotFound, err)
+		assert.Empty(t, addrs)
+		_, err = r.FindServiceByName(ctx, "orders", md.Selector{}, "")
+		assert.Equal(t, repo.ErrNotFound, err)
+	})
+
+	t.Run("Activate and deactivate", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1"}))
+
+		assert.NoError(t, r.DeactivateSvc(ctx, "orders", "addr1"))
+		svc, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.False(t, svc.IsActive)
+
+		assert.NoError(t, r.ActivateSvc(ctx, "orders", "addr1"))
+		svc, err = r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.True(t, svc.IsActive)
+
+		assert.Equal(t, repo.ErrNotFound, r.DeactivateSvc(ctx, "orders", "addr2"))
+		assert.Equal(t, repo.ErrNotFound, r.ActivateSvc(ctx, "orders", "addr2"))
+	})
+
+	t.Run("Update instance", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Weight: 1, TTL: 10}))
+		before, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+		health := md.Health{State: md.HealthFailing, Reason: "2 consecutive failed checks", Failures: 2, LastError: "timeout"}
+		assert.NoError(t, r.Heartbeat(ctx, "orders", "addr1"))
+		assert.NoError(t, r.UpdateWeight(ctx, "orders", "addr1", 0))
+		assert.NoError(t, r.SetHealth(ctx, "orders", "addr1", health))
+
+		svc, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.True(t, svc.LastHeartbeat.After(before.LastHeartbeat))
+		assert.Equal(t, 0, svc.Weight)
+		assert.Equal(t, health, svc.Health)
+
+		assert.Equal(t, repo.ErrNotFound, r.Heartbeat(ctx, "orders", "addr2"))
+		assert.Equal(t, repo.ErrNotFound, r.UpdateWeight(ctx, "orders", "addr2", 1))
+		assert.Equal(t, repo.ErrNotFound, r.SetHealth(ctx, "orders", "addr2", health))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		r := newRepo(t)
+		defer r.Close()
+
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Weight: 1}))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "payments", Address: "addr1", Weight: 1}))
+
+		health := md.Health{State: md.HealthFailing, Reason: "2 consecutive failed checks", Failures: 2}
+		heartbeat := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+		restored := []md.Service{
+			{Name: "orders", Address: "addr1", IsActive: false, Weight: 0, Version: "v2", Health: health, LastHeartbeat: heartbeat},
+			{Name: "orders", Address: "addr2", IsActive: true, Weight: 4, Tags: []string{"canary"}, LastHeartbeat: heartbeat},
+		}
+		assert.NoError(t, r.Restore(ctx, restored, false))
+
+		svc, err := r.GetService(ctx, "orders", "addr1")
+		assert.NoError(t, err)
+		assert.False(t, svc.IsActive)
+		assert.Equal(t, 0, svc.Weight)
+		assert.Equal(t, "v2", svc.Version)
+		assert.Equal(t, health, svc.Health)
+		assert.True(t, heartbeat.Equal(svc.LastHeartbeat))
+
+		addrs, err := r.ListAddrs(ctx, "orders", md.Selector{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"addr2"}, addrs)
+
+		_, err = r.GetService(ctx, "payments", "addr1")
+		assert.NoError(t, err)
+
+		assert.NoError(t, r.Restore(ctx, restored[1:], true))
+		svcs, err := r.ListInstances(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, svcs, 1)
+		assert.Equal(t, "addr2", svcs[0].Address)
+		assert.Equal(t, 4, svcs[0].Weight)
+		assert.Equal(t, []string{"canary"}, svcs[0].Tags)
+
+		// Restored instances can be deregistered and registered again
+		assert.NoError(t, r.Deregister(ctx, "orders", "addr2"))
+		assert.NoError(t, r.Register(ctx, &md.Service{Name: "orders", Address: "addr2"}))
+	})
+}
@@ -0,0 +1,147 @@
+// Package traced traces the calls of the controller to its repository.
+package traced
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/tracing"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Repository starts a span around every call to the wrapped repository.
+// Queries of the SQLite and Postgres repositories are traced as its children.
+type Repository struct {
+	repo   ctrl.ServiceDiscoveryRepo
+	system attribute.KeyValue
+}
+
+// New wraps a repository, system names the backend in the spans, e.g. "sqlite".
+func New(repo ctrl.ServiceDiscoveryRepo, system string) *Repository {
+	return &Repository{
+		repo:   repo,
+		system: attribute.String("discovery.repo", system),
+	}
+}
+
+func (r *Repository) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "repo."+method, append(attrs, r.system)...)
+}
+
+// end ends the span, unknown and duplicated instances are answers rather than failures.
+func end(span trace.Span, err error) {
+	if errors.Is(err, repo.ErrNotFound) || errors.Is(err, repo.ErrAlreadyExists) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func svcAttrs(name, addr string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("discovery.service", name),
+		attribute.String("discovery.address", addr),
+	}
+}
+
+func (r *Repository) Register(ctx context.Context, svc *md.Service) error {
+	ctx, span := r.start(ctx, "Register", svcAttrs(svc.Name, svc.Address)...)
+	err := r.repo.Register(ctx, svc)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) Deregister(ctx context.Context, name, addr string) error {
+	ctx, span := r.start(ctx, "Deregister", svcAttrs(name, addr)...)
+	err := r.repo.Deregister(ctx, name, addr)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) Heartbeat(ctx context.Context, name, addr string) error {
+	ctx, span := r.start(ctx, "Heartbeat", svcAttrs(name, addr)...)
+	err := r.repo.Heartbeat(ctx, name, addr)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) GetService(ctx context.Context, name, addr string) (*md.Service, error) {
+	ctx, span := r.start(ctx, "GetService", svcAttrs(name, addr)...)
+	svc, err := r.repo.GetService(ctx, name, addr)
+	end(span, err)
+	return svc, err
+}
+
+func (r *Repository) FindServiceByName(ctx context.Context, name string, sel md.Selector, key string) (string, error) {
+	ctx, span := r.start(ctx, "FindServiceByName", attribute.String("discovery.service", name))
+	addr, err := r.repo.FindServiceByName(ctx, name, sel, key)
+	end(span, err)
+	return addr, err
+}
+
+func (r *Repository) ListServices(ctx context.Context) ([]string, error) {
+	ctx, span := r.start(ctx, "ListServices")
+	names, err := r.repo.ListServices(ctx)
+	end(span, err)
+	return names, err
+}
+
+func (r *Repository) ListAddrs(ctx context.Context, name string, sel md.Selector) ([]string, error) {
+	ctx, span := r.start(ctx, "ListAddrs", attribute.String("discovery.service", name))
+	addrs, err := r.repo.ListAddrs(ctx, name, sel)
+	span.SetAttributes(attribute.Int("discovery.addrs", len(addrs)))
+	end(span, err)
+	return addrs, err
+}
+
+func (r *Repository) ListInstances(ctx context.Context) ([]md.Service, error) {
+	ctx, span := r.start(ctx, "ListInstances")
+	svcs, err := r.repo.ListInstances(ctx)
+	span.SetAttributes(attribute.Int("discovery.instances", len(svcs)))
+	end(span, err)
+	return svcs, err
+}
+
+func (r *Repository) Restore(ctx context.Context, svcs []md.Service, replace bool) error {
+	ctx, span := r.start(
+		ctx, "Restore",
+		attribute.Int("discovery.instances", len(svcs)), attribute.Bool("discovery.replace", replace),
+	)
+	err := r.repo.Restore(ctx, svcs, replace)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) UpdateWeight(ctx context.Context, name, addr string, weight int) error {
+	ctx, span := r.start(ctx, "UpdateWeight", svcAttrs(name, addr)...)
+	err := r.repo.UpdateWeight(ctx, name, addr, weight)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) SetHealth(ctx context.Context, name, addr string, health md.Health) error {
+	ctx, span := r.start(ctx, "SetHealth", svcAttrs(name, addr)...)
+	err := r.repo.SetHealth(ctx, name, addr, health)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) DeactivateSvc(ctx context.Context, name, addr string) error {
+	ctx, span := r.start(ctx, "DeactivateSvc", svcAttrs(name, addr)...)
+	err := r.repo.DeactivateSvc(ctx, name, addr)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ActivateSvc(ctx context.Context, name, addr string) error {
+	ctx, span := r.start(ctx, "ActivateSvc", svcAttrs(name, addr)...)
+	err := r.repo.ActivateSvc(ctx, name, addr)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) Close() error {
+	return r.repo.Close()
+}
@@ -0,0 +1,61 @@
+package traced
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/service-discovery/internal/repo"
+	"github.com/JMURv/service-discovery/internal/tracing"
+	"github.com/JMURv/service-discovery/internal/tracing/tracingtest"
+	"github.com/JMURv/service-discovery/mocks"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	ctrlMock := gomock.NewController(t)
+	defer ctrlMock.Finish()
+
+	exp := tracingtest.Install(t)
+	svcRepo := mocks.NewMockServiceDiscoveryRepo(ctrlMock)
+	r := New(svcRepo, "sqlite")
+
+	ctx, parent := tracing.Start(context.Background(), "ctrl.list_addrs")
+
+	// Test case 1: Calls are traced as children of the caller
+	svcRepo.EXPECT().ListAddrs(gomock.Any(), "test-svc", md.Selector{}).Return([]string{"addr1", "addr2"}, nil).Times(1)
+
+	addrs, err := r.ListAddrs(ctx, "test-svc", md.Selector{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"addr1", "addr2"}, addrs)
+
+	span := tracingtest.Find(exp, "repo.ListAddrs")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		assert.Contains(t, span.Attributes, attribute.String("discovery.repo", "sqlite"))
+		assert.Contains(t, span.Attributes, attribute.Int("discovery.addrs", 2))
+	}
+
+	// Test case 2: Unknown instances do not fail the span
+	svcRepo.EXPECT().GetService(gomock.Any(), "test-svc", "addr3").Return(nil, repo.ErrNotFound).Times(1)
+
+	_, err = r.GetService(ctx, "test-svc", "addr3")
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	span = tracingtest.Find(exp, "repo.GetService")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, codes.Unset, span.Status.Code)
+	}
+
+	// Test case 3: Other errors fail the span
+	var ErrOther = errors.New("other error")
+	svcRepo.EXPECT().Heartbeat(gomock.Any(), "test-svc", "addr1").Return(ErrOther).Times(1)
+
+	assert.ErrorIs(t, r.Heartbeat(ctx, "test-svc", "addr1"), ErrOther)
+	span = tracingtest.Find(exp, "repo.Heartbeat")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, codes.Error, span.Status.Code)
+	}
+}
@@ -0,0 +1,5 @@
+package snapshot
+
+import "errors"
+
+var ErrUnsupportedFormat = errors.New("unsupported snapshot format")
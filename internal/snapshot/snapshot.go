@@ -0,0 +1,80 @@
+// Package snapshot reads and writes registry snapshots as JSON or YAML.
+package snapshot
+
+import (
+	"fmt"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/goccy/go-json"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strings"
+)
+
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+)
+
+// ParseFormat accepts "json", "yaml" and "yml", case-insensitive.
+// Empty string defaults to JSON.
+func ParseFormat(raw string) (Format, error) {
+	switch strings.ToLower(raw) {
+	case "", "json":
+		return JSON, nil
+	case "yaml", "yml":
+		return YAML, nil
+	default:
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, raw)
+	}
+}
+
+// ContentType is the media type served for the format.
+func (f Format) ContentType() string {
+	if f == YAML {
+		return "application/yaml"
+	}
+	return "application/json"
+}
+
+func Encode(w io.Writer, snap *md.Snapshot, format Format) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(snap); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, format)
+	}
+}
+
+// Decode rejects unknown fields so that typos in seed files do not go unnoticed.
+func Decode(r io.Reader, format Format) (*md.Snapshot, error) {
+	snap := &md.Snapshot{}
+	switch format {
+	case JSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(snap); err != nil {
+			return nil, err
+		}
+	case YAML:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(snap); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, format)
+	}
+
+	return snap, nil
+}
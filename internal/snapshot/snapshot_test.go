@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"bytes"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	for raw, expected := range map[string]Format{"": JSON, "json": JSON, "YAML": YAML, "yml": YAML} {
+		format, err := ParseFormat(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+
+	_, err := ParseFormat("xml")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	snap := md.NewSnapshot([]md.Service{
+		{
+			Name:     "orders",
+			Address:  "addr1",
+			IsActive: true,
+			Weight:   3,
+			Version:  "v2",
+			Tags:     []string{"canary"},
+			Metadata: map[string]string{"zone": "eu-1"},
+			TTL:      10,
+			Check:    &md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{200}},
+			Health:   md.Health{State: md.HealthFailing, Failures: 2, Since: now, LastCheck: now},
+		},
+		{Name: "orders", Address: "addr2", Weight: 0},
+	}, now)
+
+	for _, format := range []Format{JSON, YAML} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, Encode(&buf, snap, format))
+
+			res, err := Decode(&buf, format)
+			assert.NoError(t, err)
+			assert.Equal(t, snap, res)
+		})
+	}
+
+	assert.ErrorIs(t, Encode(&bytes.Buffer{}, snap, "xml"), ErrUnsupportedFormat)
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("Seed file", func(t *testing.T) {
+		snap, err := Decode(strings.NewReader(`
+version: 1
+services:
+  - name: orders
+    instances:
+      - address: 10.0.0.1:8080
+        tags: [canary]
+`), YAML)
+		assert.NoError(t, err)
+
+		svcs := snap.Instances(time.Now())
+		assert.Len(t, svcs, 1)
+		assert.True(t, svcs[0].IsActive)
+		assert.Equal(t, md.DefaultWeight, svcs[0].Weight)
+		assert.Equal(t, []string{"canary"}, svcs[0].Tags)
+	})
+
+	t.Run("Unknown fields", func(t *testing.T) {
+		_, err := Decode(strings.NewReader(`{"version": 1, "servics": []}`), JSON)
+		assert.Error(t, err)
+
+		_, err = Decode(strings.NewReader("version: 1\nservics: []\n"), YAML)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		_, err := Decode(strings.NewReader(""), "xml")
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
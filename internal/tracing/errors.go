@@ -0,0 +1,5 @@
+package tracing
+
+import "errors"
+
+var ErrUnsupportedExporter = errors.New("unsupported exporter")
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"errors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// gormPlugin starts a span around every statement run through gorm.
+type gormPlugin struct{}
+
+// Gorm returns the gorm plugin tracing the queries, install it with db.Use.
+func Gorm() gorm.Plugin {
+	return gormPlugin{}
+}
+
+func (gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	)
+}
+
+// before only traces statements of traced operations, the health checks
+// and the lease renewals would otherwise start a trace of their own every few seconds.
+func before(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx := tx.Statement.Context
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+
+		_, span := Start(
+			ctx, "db."+op,
+			semconv.DBSystemKey.String(tx.Dialector.Name()),
+			semconv.DBCollectionName(tx.Statement.Table),
+		)
+		tx.InstanceSet(spanKey, span)
+	}
+}
+
+func after(tx *gorm.DB) {
+	v, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+
+	span := v.(trace.Span)
+	// The statement is recorded with placeholders, parameters are left out
+	span.SetAttributes(semconv.DBQueryText(tx.Statement.SQL.String()))
+
+	// A missing record is an answer, not a failure of the query
+	err := tx.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
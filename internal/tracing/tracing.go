@@ -0,0 +1,92 @@
+// Package tracing sets up the OpenTelemetry spans of the discovery server.
+// Spans are dropped until Setup installs an exporter.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/JMURv/service-discovery/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentation    = "github.com/JMURv/service-discovery"
+	defaultServiceName = "service-discovery"
+)
+
+// Tracer returns the tracer of the discovery server. It is looked up on every
+// call, so spans follow the provider installed last.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start starts a span, a child of the span in ctx if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, marking it failed when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Propagator extracts the W3C trace context and baggage of incoming requests.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// Setup installs the tracer provider exporting to the configured exporter.
+// The returned func flushes the pending spans and stops the provider.
+func Setup(ctx context.Context, conf *config.TracingConfig) (func(context.Context) error, error) {
+	exp, err := newExporter(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	name := conf.ServiceName
+	if name == "" {
+		name = defaultServiceName
+	}
+
+	// A zero ratio samples none of the new traces, those of callers are still followed
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(name))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(Propagator())
+
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, conf *config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch conf.Exporter {
+	case config.OTLP, "":
+		opts := []otlptracegrpc.Option{}
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(conf.Endpoint))
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case config.Stdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedExporter, conf.Exporter)
+	}
+}
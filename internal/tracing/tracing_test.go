@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/service-discovery/pkg/config"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// install records the spans of the test, tracingtest cannot be used from this package
+func install(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exp
+}
+
+func find(exp *tracetest.InMemoryExporter, name string) *tracetest.SpanStub {
+	for _, span := range exp.GetSpans() {
+		if span.Name == name {
+			return &span
+		}
+	}
+	return nil
+}
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	// Test case 1: Stdout exporter
+	shutdown, err := Setup(ctx, &config.TracingConfig{Exporter: config.Stdout, SampleRatio: 1})
+	assert.NoError(t, err)
+	_, span := Start(ctx, "sampled")
+	assert.True(t, span.SpanContext().IsSampled())
+	span.End()
+	assert.NoError(t, shutdown(ctx))
+
+	// Test case 2: A zero ratio samples no new trace
+	shutdown, err = Setup(ctx, &config.TracingConfig{Exporter: config.Stdout, SampleRatio: 0})
+	assert.NoError(t, err)
+	_, span = Start(ctx, "dropped")
+	assert.False(t, span.SpanContext().IsSampled())
+	span.End()
+	assert.NoError(t, shutdown(ctx))
+
+	// Test case 3: Unsupported exporter
+	_, err = Setup(ctx, &config.TracingConfig{Exporter: "zipkin"})
+	assert.ErrorIs(t, err, ErrUnsupportedExporter)
+}
+
+func TestEnd(t *testing.T) {
+	exp := install(t)
+	ctx := context.Background()
+
+	_, span := Start(ctx, "ok")
+	End(span, nil)
+	_, span = Start(ctx, "failed")
+	End(span, errors.New("other error"))
+
+	assert.Equal(t, codes.Unset, find(exp, "ok").Status.Code)
+	failed := find(exp, "failed")
+	assert.Equal(t, codes.Error, failed.Status.Code)
+	assert.Equal(t, "other error", failed.Status.Description)
+}
+
+type record struct {
+	ID   int
+	Name string
+}
+
+func TestGorm(t *testing.T) {
+	exp := install(t)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(Gorm()))
+	assert.NoError(t, db.AutoMigrate(&record{}))
+
+	// Test case 1: Statements without a traced caller are not traced
+	ctx := context.Background()
+	assert.NoError(t, db.WithContext(ctx).Create(&record{ID: 1, Name: "orders"}).Error)
+	assert.Empty(t, exp.GetSpans())
+
+	// Test case 2: Statements are children of the caller's span
+	ctx, parent := Start(ctx, "parent")
+	var r record
+	assert.NoError(t, db.WithContext(ctx).First(&r, 1).Error)
+	parent.End()
+
+	query := find(exp, "db.query")
+	if assert.NotNil(t, query) {
+		assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+		assert.Contains(t, query.Attributes, semconv.DBSystemKey.String("sqlite"))
+		assert.Contains(t, query.Attributes, semconv.DBCollectionName("records"))
+		assert.Contains(t, query.Attributes, semconv.DBQueryText("SELECT * FROM `records` WHERE `records`.`id` = ? ORDER BY `records`.`id` LIMIT 1"))
+		assert.Equal(t, codes.Unset, query.Status.Code)
+	}
+
+	// Test case 3: Missing records do not fail the span
+	exp.Reset()
+	ctx, parent = Start(context.Background(), "parent")
+	assert.ErrorIs(t, db.WithContext(ctx).First(&r, 2).Error, gorm.ErrRecordNotFound)
+	parent.End()
+
+	query = find(exp, "db.query")
+	if assert.NotNil(t, query) {
+		assert.Equal(t, codes.Unset, query.Status.Code)
+	}
+}
@@ -0,0 +1,39 @@
+// Package tracingtest records the spans of a test in memory.
+package tracingtest
+
+import (
+	"context"
+	"github.com/JMURv/service-discovery/internal/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+// Install records every span ended until the test is over, the previous
+// provider is restored on cleanup. Tests installing it must not run in parallel.
+func Install(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(tracing.Propagator())
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+		_ = tp.Shutdown(context.Background())
+	})
+	return exp
+}
+
+// Find returns the first recorded span of the name, nil if there is none.
+func Find(exp *tracetest.InMemoryExporter, name string) *tracetest.SpanStub {
+	for _, span := range exp.GetSpans() {
+		if span.Name == name {
+			return &span
+		}
+	}
+	return nil
+}
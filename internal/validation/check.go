@@ -0,0 +1,35 @@
+package validation
+
+import (
+	"fmt"
+	md "github.com/JMURv/service-discovery/pkg/model"
+)
+
+// ValidateCheck validates an optional check spec against the registration it comes with.
+func ValidateCheck(check *md.Check, ttl int) error {
+	if check == nil {
+		return nil
+	}
+
+	switch check.Type {
+	case "", md.CheckHTTP, md.CheckGRPC, md.CheckTCP, md.CheckNone:
+	case md.CheckTTL:
+		if ttl <= 0 {
+			return fmt.Errorf("%w: ttl check requires a ttl", ErrInvalidCheck)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %v", ErrInvalidCheck, check.Type)
+	}
+
+	if check.Interval < 0 || check.Timeout < 0 {
+		return fmt.Errorf("%w: interval and timeout must not be negative", ErrInvalidCheck)
+	}
+
+	for _, code := range check.Statuses {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("%w: invalid status code %v", ErrInvalidCheck, code)
+		}
+	}
+
+	return nil
+}
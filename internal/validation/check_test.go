@@ -0,0 +1,25 @@
+package validation
+
+import (
+	"errors"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateCheck(t *testing.T) {
+	assert.NoError(t, ValidateCheck(nil, 0))
+	assert.NoError(t, ValidateCheck(&md.Check{}, 0))
+	assert.NoError(t, ValidateCheck(&md.Check{Type: md.CheckHTTP, Path: "/ready", Statuses: []int{200, 204}}, 0))
+	assert.NoError(t, ValidateCheck(&md.Check{Type: md.CheckTTL}, 10))
+
+	for _, check := range []*md.Check{
+		{Type: "udp"},
+		{Type: md.CheckTTL},
+		{Type: md.CheckTCP, Interval: -1},
+		{Type: md.CheckGRPC, Timeout: -1},
+		{Type: md.CheckHTTP, Statuses: []int{42}},
+	} {
+		assert.True(t, errors.Is(ValidateCheck(check, 0), ErrInvalidCheck), check)
+	}
+}
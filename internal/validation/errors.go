@@ -4,3 +4,8 @@ import "errors"
 
 var ErrMissingName = errors.New("missing name")
 var ErrMissingAddress = errors.New("missing address")
+var ErrInvalidSelector = errors.New("invalid selector")
+var ErrInvalidWeight = errors.New("weight must not be negative")
+var ErrInvalidTTL = errors.New("ttl must not be negative")
+var ErrInvalidCheck = errors.New("invalid health check")
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
@@ -0,0 +1,37 @@
+package validation
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"strings"
+)
+
+// ParseSelector parses selectors of the form "version=v2,zone=eu-1".
+func ParseSelector(raw string, tags []string) (md.Selector, error) {
+	sel := md.Selector{}
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return md.Selector{}, ErrInvalidSelector
+		}
+		sel.Tags = append(sel.Tags, strings.TrimSpace(tag))
+	}
+
+	if strings.TrimSpace(raw) == "" {
+		return sel, nil
+	}
+
+	sel.Labels = make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			return md.Selector{}, ErrInvalidSelector
+		}
+
+		if _, exists := sel.Labels[k]; exists {
+			return md.Selector{}, ErrInvalidSelector
+		}
+		sel.Labels[k] = v
+	}
+
+	return sel, nil
+}
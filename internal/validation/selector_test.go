@@ -0,0 +1,26 @@
+package validation
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector("", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, md.Selector{}, sel)
+
+	sel, err = ParseSelector(" version=v2, zone = eu-1 ", []string{"canary"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"version": "v2", "zone": "eu-1"}, sel.Labels)
+	assert.Equal(t, []string{"canary"}, sel.Tags)
+
+	for _, raw := range []string{"version", "=v2", "zone=eu-1,zone=eu-2", "zone=eu-1,"} {
+		_, err = ParseSelector(raw, nil)
+		assert.Equal(t, ErrInvalidSelector, err, raw)
+	}
+
+	_, err = ParseSelector("", []string{""})
+	assert.Equal(t, ErrInvalidSelector, err)
+}
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"fmt"
+	md "github.com/JMURv/service-discovery/pkg/model"
+)
+
+// ValidateSnapshot applies the registration rules to every instance of a snapshot.
+func ValidateSnapshot(snap *md.Snapshot) error {
+	if snap.Version != md.SnapshotVersion {
+		return fmt.Errorf("%w: unsupported version %v", ErrInvalidSnapshot, snap.Version)
+	}
+
+	seen := make(map[[2]string]struct{})
+	for _, svc := range snap.Services {
+		if svc.Name == "" {
+			return fmt.Errorf("%w: %w", ErrInvalidSnapshot, ErrMissingName)
+		}
+
+		for _, inst := range svc.Instances {
+			if inst.Address == "" {
+				return fmt.Errorf("%w: %v: %w", ErrInvalidSnapshot, svc.Name, ErrMissingAddress)
+			} else if inst.Weight != nil && *inst.Weight < 0 {
+				return fmt.Errorf("%w: %v/%v: %w", ErrInvalidSnapshot, svc.Name, inst.Address, ErrInvalidWeight)
+			} else if inst.TTL < 0 {
+				return fmt.Errorf("%w: %v/%v: %w", ErrInvalidSnapshot, svc.Name, inst.Address, ErrInvalidTTL)
+			}
+
+			if err := ValidateCheck(inst.Check, inst.TTL); err != nil {
+				return fmt.Errorf("%w: %v/%v: %w", ErrInvalidSnapshot, svc.Name, inst.Address, err)
+			}
+
+			key := [2]string{svc.Name, inst.Address}
+			if _, ok := seen[key]; ok {
+				return fmt.Errorf("%w: %v/%v is listed twice", ErrInvalidSnapshot, svc.Name, inst.Address)
+			}
+			seen[key] = struct{}{}
+		}
+	}
+
+	return nil
+}
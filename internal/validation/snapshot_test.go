@@ -0,0 +1,36 @@
+package validation
+
+import (
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateSnapshot(t *testing.T) {
+	weight := -1
+	snapshot := func(svcs ...md.SnapshotService) *md.Snapshot {
+		return &md.Snapshot{Version: md.SnapshotVersion, Services: svcs}
+	}
+
+	assert.NoError(t, ValidateSnapshot(snapshot()))
+	assert.NoError(t, ValidateSnapshot(snapshot(
+		md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1"}, {Address: "addr2"}}},
+		md.SnapshotService{Name: "payments", Instances: []md.SnapshotInstance{{Address: "addr1", TTL: 10, Check: &md.Check{Type: md.CheckTTL}}}},
+	)))
+
+	for _, snap := range []*md.Snapshot{
+		{Version: 0},
+		snapshot(md.SnapshotService{Instances: []md.SnapshotInstance{{Address: "addr1"}}}),
+		snapshot(md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{}}}),
+		snapshot(md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1", Weight: &weight}}}),
+		snapshot(md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1", TTL: -1}}}),
+		snapshot(md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1", Check: &md.Check{Type: md.CheckTTL}}}}),
+		snapshot(md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1"}, {Address: "addr1"}}}),
+		snapshot(
+			md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1"}}},
+			md.SnapshotService{Name: "orders", Instances: []md.SnapshotInstance{{Address: "addr1"}}},
+		),
+	} {
+		assert.ErrorIs(t, ValidateSnapshot(snap), ErrInvalidSnapshot)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	model "github.com/JMURv/service-discovery/pkg/model"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -53,34 +54,123 @@ func (mr *MockCtrlMockRecorder) Deregister(ctx, name, addr any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deregister", reflect.TypeOf((*MockCtrl)(nil).Deregister), ctx, name, addr)
 }
 
+// Export mocks base method.
+func (m *MockCtrl) Export(ctx context.Context) (*model.Snapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx)
+	ret0, _ := ret[0].(*model.Snapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockCtrlMockRecorder) Export(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockCtrl)(nil).Export), ctx)
+}
+
 // FindServiceByName mocks base method.
-func (m *MockCtrl) FindServiceByName(ctx context.Context, name string) (string, error) {
+func (m *MockCtrl) FindServiceByName(ctx context.Context, name string, sel model.Selector, key string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindServiceByName", ctx, name)
+	ret := m.ctrl.Call(m, "FindServiceByName", ctx, name, sel, key)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindServiceByName indicates an expected call of FindServiceByName.
-func (mr *MockCtrlMockRecorder) FindServiceByName(ctx, name any) *gomock.Call {
+func (mr *MockCtrlMockRecorder) FindServiceByName(ctx, name, sel, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindServiceByName", reflect.TypeOf((*MockCtrl)(nil).FindServiceByName), ctx, name, sel, key)
+}
+
+// GetInstance mocks base method.
+func (m *MockCtrl) GetInstance(ctx context.Context, name, addr string) (*model.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstance", ctx, name, addr)
+	ret0, _ := ret[0].(*model.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstance indicates an expected call of GetInstance.
+func (mr *MockCtrlMockRecorder) GetInstance(ctx, name, addr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstance", reflect.TypeOf((*MockCtrl)(nil).GetInstance), ctx, name, addr)
+}
+
+// GetInstanceHealth mocks base method.
+func (m *MockCtrl) GetInstanceHealth(ctx context.Context, name, addr string) (*model.Health, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstanceHealth", ctx, name, addr)
+	ret0, _ := ret[0].(*model.Health)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstanceHealth indicates an expected call of GetInstanceHealth.
+func (mr *MockCtrlMockRecorder) GetInstanceHealth(ctx, name, addr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceHealth", reflect.TypeOf((*MockCtrl)(nil).GetInstanceHealth), ctx, name, addr)
+}
+
+// Heartbeat mocks base method.
+func (m *MockCtrl) Heartbeat(ctx context.Context, name, addr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Heartbeat", ctx, name, addr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Heartbeat indicates an expected call of Heartbeat.
+func (mr *MockCtrlMockRecorder) Heartbeat(ctx, name, addr any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindServiceByName", reflect.TypeOf((*MockCtrl)(nil).FindServiceByName), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Heartbeat", reflect.TypeOf((*MockCtrl)(nil).Heartbeat), ctx, name, addr)
+}
+
+// Import mocks base method.
+func (m *MockCtrl) Import(ctx context.Context, snap *model.Snapshot, replace bool) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, snap, replace)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockCtrlMockRecorder) Import(ctx, snap, replace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockCtrl)(nil).Import), ctx, snap, replace)
 }
 
 // ListAddrs mocks base method.
-func (m *MockCtrl) ListAddrs(ctx context.Context, name string) ([]string, error) {
+func (m *MockCtrl) ListAddrs(ctx context.Context, name string, sel model.Selector) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAddrs", ctx, name)
+	ret := m.ctrl.Call(m, "ListAddrs", ctx, name, sel)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListAddrs indicates an expected call of ListAddrs.
-func (mr *MockCtrlMockRecorder) ListAddrs(ctx, name any) *gomock.Call {
+func (mr *MockCtrlMockRecorder) ListAddrs(ctx, name, sel any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAddrs", reflect.TypeOf((*MockCtrl)(nil).ListAddrs), ctx, name, sel)
+}
+
+// ListInstances mocks base method.
+func (m *MockCtrl) ListInstances(ctx context.Context) ([]model.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInstances", ctx)
+	ret0, _ := ret[0].([]model.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInstances indicates an expected call of ListInstances.
+func (mr *MockCtrlMockRecorder) ListInstances(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAddrs", reflect.TypeOf((*MockCtrl)(nil).ListAddrs), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstances", reflect.TypeOf((*MockCtrl)(nil).ListInstances), ctx)
 }
 
 // ListServices mocks base method.
@@ -99,15 +189,43 @@ func (mr *MockCtrlMockRecorder) ListServices(ctx any) *gomock.Call {
 }
 
 // Register mocks base method.
-func (m *MockCtrl) Register(ctx context.Context, name, addr string) error {
+func (m *MockCtrl) Register(ctx context.Context, svc *model.Service) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Register", ctx, name, addr)
+	ret := m.ctrl.Call(m, "Register", ctx, svc)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Register indicates an expected call of Register.
-func (mr *MockCtrlMockRecorder) Register(ctx, name, addr any) *gomock.Call {
+func (mr *MockCtrlMockRecorder) Register(ctx, svc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockCtrl)(nil).Register), ctx, svc)
+}
+
+// UpdateWeight mocks base method.
+func (m *MockCtrl) UpdateWeight(ctx context.Context, name, addr string, weight int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWeight", ctx, name, addr, weight)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWeight indicates an expected call of UpdateWeight.
+func (mr *MockCtrlMockRecorder) UpdateWeight(ctx, name, addr, weight any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWeight", reflect.TypeOf((*MockCtrl)(nil).UpdateWeight), ctx, name, addr, weight)
+}
+
+// Watch mocks base method.
+func (m *MockCtrl) Watch(ctx context.Context, name string) <-chan model.ServiceEvent {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx, name)
+	ret0, _ := ret[0].(<-chan model.ServiceEvent)
+	return ret0
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockCtrlMockRecorder) Watch(ctx, name any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockCtrl)(nil).Register), ctx, name, addr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockCtrl)(nil).Watch), ctx, name)
 }
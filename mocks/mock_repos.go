@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	model "github.com/JMURv/service-discovery/pkg/model"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -96,33 +97,77 @@ func (mr *MockServiceDiscoveryRepoMockRecorder) Deregister(ctx, name, addr any)
 }
 
 // FindServiceByName mocks base method.
-func (m *MockServiceDiscoveryRepo) FindServiceByName(ctx context.Context, name string) (string, error) {
+func (m *MockServiceDiscoveryRepo) FindServiceByName(ctx context.Context, name string, sel model.Selector, key string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindServiceByName", ctx, name)
+	ret := m.ctrl.Call(m, "FindServiceByName", ctx, name, sel, key)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindServiceByName indicates an expected call of FindServiceByName.
-func (mr *MockServiceDiscoveryRepoMockRecorder) FindServiceByName(ctx, name any) *gomock.Call {
+func (mr *MockServiceDiscoveryRepoMockRecorder) FindServiceByName(ctx, name, sel, key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindServiceByName", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).FindServiceByName), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindServiceByName", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).FindServiceByName), ctx, name, sel, key)
+}
+
+// GetService mocks base method.
+func (m *MockServiceDiscoveryRepo) GetService(ctx context.Context, name, addr string) (*model.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetService", ctx, name, addr)
+	ret0, _ := ret[0].(*model.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetService indicates an expected call of GetService.
+func (mr *MockServiceDiscoveryRepoMockRecorder) GetService(ctx, name, addr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetService", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).GetService), ctx, name, addr)
+}
+
+// Heartbeat mocks base method.
+func (m *MockServiceDiscoveryRepo) Heartbeat(ctx context.Context, name, addr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Heartbeat", ctx, name, addr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Heartbeat indicates an expected call of Heartbeat.
+func (mr *MockServiceDiscoveryRepoMockRecorder) Heartbeat(ctx, name, addr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Heartbeat", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).Heartbeat), ctx, name, addr)
 }
 
 // ListAddrs mocks base method.
-func (m *MockServiceDiscoveryRepo) ListAddrs(ctx context.Context, name string) ([]string, error) {
+func (m *MockServiceDiscoveryRepo) ListAddrs(ctx context.Context, name string, sel model.Selector) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAddrs", ctx, name)
+	ret := m.ctrl.Call(m, "ListAddrs", ctx, name, sel)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListAddrs indicates an expected call of ListAddrs.
-func (mr *MockServiceDiscoveryRepoMockRecorder) ListAddrs(ctx, name any) *gomock.Call {
+func (mr *MockServiceDiscoveryRepoMockRecorder) ListAddrs(ctx, name, sel any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAddrs", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).ListAddrs), ctx, name, sel)
+}
+
+// ListInstances mocks base method.
+func (m *MockServiceDiscoveryRepo) ListInstances(ctx context.Context) ([]model.Service, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInstances", ctx)
+	ret0, _ := ret[0].([]model.Service)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInstances indicates an expected call of ListInstances.
+func (mr *MockServiceDiscoveryRepoMockRecorder) ListInstances(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAddrs", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).ListAddrs), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInstances", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).ListInstances), ctx)
 }
 
 // ListServices mocks base method.
@@ -141,15 +186,57 @@ func (mr *MockServiceDiscoveryRepoMockRecorder) ListServices(ctx any) *gomock.Ca
 }
 
 // Register mocks base method.
-func (m *MockServiceDiscoveryRepo) Register(ctx context.Context, name, addr string) error {
+func (m *MockServiceDiscoveryRepo) Register(ctx context.Context, svc *model.Service) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Register", ctx, name, addr)
+	ret := m.ctrl.Call(m, "Register", ctx, svc)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Register indicates an expected call of Register.
-func (mr *MockServiceDiscoveryRepoMockRecorder) Register(ctx, name, addr any) *gomock.Call {
+func (mr *MockServiceDiscoveryRepoMockRecorder) Register(ctx, svc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).Register), ctx, svc)
+}
+
+// Restore mocks base method.
+func (m *MockServiceDiscoveryRepo) Restore(ctx context.Context, svcs []model.Service, replace bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, svcs, replace)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockServiceDiscoveryRepoMockRecorder) Restore(ctx, svcs, replace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).Restore), ctx, svcs, replace)
+}
+
+// SetHealth mocks base method.
+func (m *MockServiceDiscoveryRepo) SetHealth(ctx context.Context, name, addr string, health model.Health) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHealth", ctx, name, addr, health)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetHealth indicates an expected call of SetHealth.
+func (mr *MockServiceDiscoveryRepoMockRecorder) SetHealth(ctx, name, addr, health any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHealth", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).SetHealth), ctx, name, addr, health)
+}
+
+// UpdateWeight mocks base method.
+func (m *MockServiceDiscoveryRepo) UpdateWeight(ctx context.Context, name, addr string, weight int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWeight", ctx, name, addr, weight)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWeight indicates an expected call of UpdateWeight.
+func (mr *MockServiceDiscoveryRepoMockRecorder) UpdateWeight(ctx, name, addr, weight any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).Register), ctx, name, addr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWeight", reflect.TypeOf((*MockServiceDiscoveryRepo)(nil).UpdateWeight), ctx, name, addr, weight)
 }
@@ -0,0 +1,124 @@
+// Package client is the Go SDK of the discovery server. It talks to either
+// of its APIs, caches address lists and keeps the calling service registered.
+package client
+
+import (
+	"context"
+	"errors"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API is a transport to the discovery server, see GRPC and HTTP.
+// Implementations return ErrNotFound and ErrAlreadyExists for the matching server errors.
+type API interface {
+	Register(ctx context.Context, svc *md.Service) error
+	Deregister(ctx context.Context, name, addr string) error
+	Heartbeat(ctx context.Context, name, addr string) error
+	GetInstance(ctx context.Context, name, addr string) (*md.Service, error)
+	// Import restores snap, with replace instances missing from it are deregistered.
+	Import(ctx context.Context, snap *md.Snapshot, replace bool) error
+	Find(ctx context.Context, q *md.ServiceQuery) (string, error)
+	ListServices(ctx context.Context) ([]string, error)
+	ListAddrs(ctx context.Context, q *md.ServiceQuery) ([]string, error)
+}
+
+// Client wraps an API with a cache of address lists.
+// Cached lists are refreshed once older than the cache ttl, and served stale
+// when the server cannot be reached. A ttl of 0 disables the cache.
+type Client struct {
+	api API
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[string]entry
+}
+
+type entry struct {
+	addrs   []string
+	fetched time.Time
+}
+
+func New(api API, cacheTTL time.Duration) *Client {
+	return &Client{
+		api:   api,
+		ttl:   cacheTTL,
+		cache: make(map[string]entry),
+	}
+}
+
+func (c *Client) Register(ctx context.Context, svc *md.Service) error {
+	return c.api.Register(ctx, svc)
+}
+
+func (c *Client) Deregister(ctx context.Context, name, addr string) error {
+	return c.api.Deregister(ctx, name, addr)
+}
+
+func (c *Client) Heartbeat(ctx context.Context, name, addr string) error {
+	return c.api.Heartbeat(ctx, name, addr)
+}
+
+// Find picks one instance with the balancer of the server, it is never cached.
+func (c *Client) Find(ctx context.Context, q *md.ServiceQuery) (string, error) {
+	return c.api.Find(ctx, q)
+}
+
+func (c *Client) ListServices(ctx context.Context) ([]string, error) {
+	return c.api.ListServices(ctx)
+}
+
+// ListAddrs returns the active instances matching the query, through the cache.
+func (c *Client) ListAddrs(ctx context.Context, q *md.ServiceQuery) ([]string, error) {
+	if c.ttl <= 0 {
+		return c.api.ListAddrs(ctx, q)
+	}
+
+	k := cacheKey(q)
+	c.mu.Lock()
+	cached, ok := c.cache[k]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetched) < c.ttl {
+		return slices.Clone(cached.addrs), nil
+	}
+
+	addrs, err := c.api.ListAddrs(ctx, q)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// The server answered, the service is gone
+		c.Invalidate(q.Name)
+		return nil, err
+	case err != nil && ok:
+		zap.L().Warn("serving stale addresses", zap.String("name", q.Name), zap.Error(err))
+		return slices.Clone(cached.addrs), nil
+	case err != nil:
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache[k] = entry{addrs: addrs, fetched: time.Now()}
+	c.mu.Unlock()
+	return slices.Clone(addrs), nil
+}
+
+// Invalidate drops the cached lists of a service, whatever their query.
+func (c *Client) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.cache {
+		if strings.HasPrefix(k, name+"\x00") {
+			delete(c.cache, k)
+		}
+	}
+}
+
+func cacheKey(q *md.ServiceQuery) string {
+	tags := slices.Clone(q.Tags)
+	slices.Sort(tags)
+	return q.Name + "\x00" + q.Selector + "\x00" + strings.Join(tags, ",")
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"errors"
+	pb "github.com/JMURv/service-discovery/api/pb"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	grpchdl "github.com/JMURv/service-discovery/internal/hdl/grpc"
+	httphdl "github.com/JMURv/service-discovery/internal/hdl/http"
+	"github.com/JMURv/service-discovery/internal/repo/memory"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCtrl() *ctrl.Controller {
+	return ctrl.New(memory.New(balancer.NewRoundRobin()), make(chan md.Service, 16), broker.New())
+}
+
+func grpcServer(t *testing.T, svc *ctrl.Controller) API {
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterServiceDiscoveryServer(srv, grpchdl.New(svc))
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return GRPC(conn)
+}
+
+func httpServer(t *testing.T, svc *ctrl.Controller) API {
+	srv := httptest.NewServer(httphdl.New(svc))
+	t.Cleanup(srv.Close)
+	return HTTP(srv.URL+"/", srv.Client())
+}
+
+func TestAPI(t *testing.T) {
+	for name, start := range map[string]func(*testing.T, *ctrl.Controller) API{"grpc": grpcServer, "http": httpServer} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c := New(start(t, newCtrl()), 0)
+
+			svc := &md.Service{
+				Name:     "orders",
+				Address:  "addr1",
+				Version:  "v2",
+				Tags:     []string{"canary"},
+				Metadata: map[string]string{"zone": "eu-1"},
+				Check:    &md.Check{Type: md.CheckTTL},
+				TTL:      10,
+			}
+			assert.NoError(t, c.Register(ctx, svc))
+			assert.Equal(t, ErrAlreadyExists, c.Register(ctx, svc))
+			assert.NoError(t, c.Register(ctx, &md.Service{Name: "orders", Address: "addr2"}))
+
+			addr, err := c.Find(ctx, &md.ServiceQuery{Name: "orders", Selector: "version=v2"})
+			assert.NoError(t, err)
+			assert.Equal(t, "addr1", addr)
+
+			addrs, err := c.ListAddrs(ctx, &md.ServiceQuery{Name: "orders", Tags: []string{"canary"}, Selector: "zone=eu-1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"addr1"}, addrs)
+
+			names, err := c.ListServices(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"orders"}, names)
+
+			assert.NoError(t, c.Heartbeat(ctx, "orders", "addr1"))
+			assert.NoError(t, c.Deregister(ctx, "orders", "addr1"))
+			assert.Equal(t, ErrNotFound, c.Deregister(ctx, "orders", "addr1"))
+			assert.Equal(t, ErrNotFound, c.Heartbeat(ctx, "orders", "addr1"))
+
+			_, err = c.ListAddrs(ctx, &md.ServiceQuery{Name: "payments"})
+			assert.Equal(t, ErrNotFound, err)
+		})
+	}
+}
+
+// api fails ListAddrs with err when it is set.
+type api struct {
+	API
+	calls atomic.Int32
+	addrs []string
+	err   error
+}
+
+func (a *api) ListAddrs(ctx context.Context, q *md.ServiceQuery) ([]string, error) {
+	a.calls.Add(1)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a.addrs, nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	q := &md.ServiceQuery{Name: "orders"}
+	a := &api{addrs: []string{"addr1"}}
+	c := New(a, 50*time.Millisecond)
+
+	// Test case 1: Fresh lists are served from the cache
+	for i := 0; i < 3; i++ {
+		addrs, err := c.ListAddrs(ctx, q)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"addr1"}, addrs)
+	}
+	assert.Equal(t, int32(1), a.calls.Load())
+
+	// Test case 2: Queries are cached apart
+	_, err := c.ListAddrs(ctx, &md.ServiceQuery{Name: "orders", Tags: []string{"canary"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), a.calls.Load())
+
+	// Test case 3: Expired lists are refreshed
+	time.Sleep(60 * time.Millisecond)
+	a.addrs = []string{"addr1", "addr2"}
+	addrs, err := c.ListAddrs(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"addr1", "addr2"}, addrs)
+
+	// Test case 4: Stale lists are served when the server is unreachable
+	time.Sleep(60 * time.Millisecond)
+	a.err = errors.New("connection refused")
+	addrs, err = c.ListAddrs(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"addr1", "addr2"}, addrs)
+
+	// Test case 5: A service gone from the registry is dropped
+	a.err = ErrNotFound
+	_, err = c.ListAddrs(ctx, q)
+	assert.Equal(t, ErrNotFound, err)
+	a.err = errors.New("connection refused")
+	_, err = c.ListAddrs(ctx, q)
+	assert.Error(t, err)
+}
+
+func TestRegistration(t *testing.T) {
+	ctx := context.Background()
+	svc := newCtrl()
+	c := New(grpcServer(t, svc), 0)
+
+	// Left over by a previous run
+	require.NoError(t, svc.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Version: "v1", Weight: 3}))
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := svc.Watch(wctx, "orders")
+
+	r, err := c.Start(ctx, &md.Service{Name: "orders", Address: "addr1", Version: "v2", TTL: 1})
+	require.NoError(t, err)
+
+	// Updated in place, without leaving the registry
+	inst, err := svc.GetInstance(ctx, "orders", "addr1")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", inst.Version)
+	assert.Equal(t, 1, inst.TTL)
+	assert.Equal(t, 3, inst.Weight)
+	assert.True(t, inst.IsActive)
+	assert.Equal(t, md.Registered, (<-events).Type)
+
+	// Registered again once the registry lost it
+	require.NoError(t, svc.Deregister(ctx, "orders", "addr1"))
+	assert.Equal(t, md.Deregistered, (<-events).Type)
+	assert.Equal(t, md.Registered, (<-events).Type)
+
+	assert.NoError(t, r.Deregister(ctx))
+	assert.NoError(t, r.Deregister(ctx))
+	_, err = svc.GetInstance(ctx, "orders", "addr1")
+	assert.ErrorIs(t, err, ctrl.ErrNotFound)
+}
+
+func TestRegistrationWithoutTTL(t *testing.T) {
+	ctx := context.Background()
+	svc := newCtrl()
+	c := New(httpServer(t, svc), 0)
+	spec := &md.Service{Name: "orders", Address: "addr1", Version: "v1", Check: &md.Check{Type: md.CheckTCP, Interval: 1}}
+
+	// Left over by a previous run with the same spec
+	require.NoError(t, svc.Register(ctx, spec))
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := svc.Watch(wctx, "orders")
+
+	r, err := c.Start(ctx, spec)
+	require.NoError(t, err)
+
+	// Registered again once the registry lost it
+	require.NoError(t, svc.Deregister(ctx, "orders", "addr1"))
+	assert.Equal(t, md.Deregistered, (<-events).Type)
+	select {
+	case ev := <-events:
+		assert.Equal(t, md.Registered, ev.Type)
+	case <-time.After(3 * time.Second):
+		t.Fatal("instance was not registered again")
+	}
+
+	inst, err := svc.GetInstance(ctx, "orders", "addr1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", inst.Version)
+	assert.NoError(t, r.Deregister(ctx))
+}
@@ -0,0 +1,6 @@
+package client
+
+import "errors"
+
+var ErrNotFound = errors.New("not found")
+var ErrAlreadyExists = errors.New("already exists")
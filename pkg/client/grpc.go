@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	pb "github.com/JMURv/service-discovery/api/pb"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/goccy/go-json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+type grpcAPI struct {
+	client pb.ServiceDiscoveryClient
+}
+
+// GRPC talks to the gRPC API of the server behind conn.
+func GRPC(conn grpc.ClientConnInterface) API {
+	return &grpcAPI{client: pb.NewServiceDiscoveryClient(conn)}
+}
+
+func (a *grpcAPI) Register(ctx context.Context, svc *md.Service) error {
+	_, err := a.client.Register(ctx, &pb.NameAndAddressMsg{
+		Name:     svc.Name,
+		Address:  svc.Address,
+		Version:  svc.Version,
+		Tags:     svc.Tags,
+		Metadata: svc.Metadata,
+		Weight:   int32(svc.Weight),
+		Ttl:      int32(svc.TTL),
+		Check:    checkToProto(svc.Check),
+	})
+	return grpcErr(err)
+}
+
+func (a *grpcAPI) Deregister(ctx context.Context, name, addr string) error {
+	_, err := a.client.Deregister(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	return grpcErr(err)
+}
+
+func (a *grpcAPI) Heartbeat(ctx context.Context, name, addr string) error {
+	_, err := a.client.Heartbeat(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	return grpcErr(err)
+}
+
+func (a *grpcAPI) GetInstance(ctx context.Context, name, addr string) (*md.Service, error) {
+	res, err := a.client.GetInstance(ctx, &pb.NameAndAddressMsg{Name: name, Address: addr})
+	if err != nil {
+		return nil, grpcErr(err)
+	}
+
+	return &md.Service{
+		Name:     res.Name,
+		Address:  res.Address,
+		IsActive: res.IsActive,
+		Weight:   int(res.Weight),
+		Version:  res.Version,
+		Tags:     res.Tags,
+		Metadata: res.Metadata,
+		TTL:      int(res.Ttl),
+		Check:    checkFromProto(res.Check),
+		Health:   healthFromProto(res.Health),
+	}, nil
+}
+
+func (a *grpcAPI) Import(ctx context.Context, snap *md.Snapshot, replace bool) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.client.ImportSnapshot(ctx, &pb.ImportSnapshotMsg{Format: "json", Data: data, Replace: replace})
+	return grpcErr(err)
+}
+
+func (a *grpcAPI) Find(ctx context.Context, q *md.ServiceQuery) (string, error) {
+	res, err := a.client.FindService(ctx, queryToProto(q))
+	if err != nil {
+		return "", grpcErr(err)
+	}
+	return res.Address, nil
+}
+
+func (a *grpcAPI) ListServices(ctx context.Context) ([]string, error) {
+	res, err := a.client.ListServices(ctx, &pb.Empty{})
+	if err != nil {
+		return nil, grpcErr(err)
+	}
+	return res.Name, nil
+}
+
+func (a *grpcAPI) ListAddrs(ctx context.Context, q *md.ServiceQuery) ([]string, error) {
+	res, err := a.client.ListAddrs(ctx, queryToProto(q))
+	if err != nil {
+		return nil, grpcErr(err)
+	}
+	return res.Address, nil
+}
+
+func queryToProto(q *md.ServiceQuery) *pb.ServiceNameMsg {
+	return &pb.ServiceNameMsg{
+		Name:     q.Name,
+		Selector: q.Selector,
+		Tags:     q.Tags,
+		HashKey:  q.HashKey,
+	}
+}
+
+func checkToProto(check *md.Check) *pb.CheckMsg {
+	if check == nil {
+		return nil
+	}
+
+	res := &pb.CheckMsg{
+		Type:        string(check.Type),
+		Path:        check.Path,
+		Interval:    int32(check.Interval),
+		Timeout:     int32(check.Timeout),
+		GrpcService: check.GRPCService,
+	}
+	for _, code := range check.Statuses {
+		res.Statuses = append(res.Statuses, int32(code))
+	}
+
+	return res
+}
+
+func checkFromProto(check *pb.CheckMsg) *md.Check {
+	if check == nil {
+		return nil
+	}
+
+	res := &md.Check{
+		Type:        md.CheckType(check.Type),
+		Path:        check.Path,
+		Interval:    int(check.Interval),
+		Timeout:     int(check.Timeout),
+		GRPCService: check.GrpcService,
+	}
+	for _, code := range check.Statuses {
+		res.Statuses = append(res.Statuses, int(code))
+	}
+
+	return res
+}
+
+func healthFromProto(health *pb.HealthMsg) md.Health {
+	if health == nil {
+		return md.Health{}
+	}
+
+	return md.Health{
+		State:            md.HealthState(health.State),
+		Reason:           health.Reason,
+		Successes:        int(health.Successes),
+		Failures:         int(health.Failures),
+		Since:            timeOrZero(health.Since),
+		QuarantinedUntil: timeOrZero(health.QuarantinedUntil),
+		LastCheck:        timeOrZero(health.LastCheck),
+		LastResult:       health.LastResult,
+		LastError:        health.LastError,
+	}
+}
+
+func timeOrZero(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func grpcErr(err error) error {
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	default:
+		return err
+	}
+}
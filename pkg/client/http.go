@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	utils "github.com/JMURv/service-discovery/pkg/utils/http"
+	"github.com/goccy/go-json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type httpAPI struct {
+	url    string
+	client *http.Client
+}
+
+// HTTP talks to the HTTP API of the server at baseURL, http.DefaultClient is used when hc is nil.
+func HTTP(baseURL string, hc *http.Client) API {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &httpAPI{url: strings.TrimSuffix(baseURL, "/"), client: hc}
+}
+
+func (a *httpAPI) Register(ctx context.Context, svc *md.Service) error {
+	return a.do(ctx, http.MethodPost, "/register", svc, nil)
+}
+
+func (a *httpAPI) Deregister(ctx context.Context, name, addr string) error {
+	return a.do(ctx, http.MethodPost, "/deregister", &md.Service{Name: name, Address: addr}, nil)
+}
+
+func (a *httpAPI) Heartbeat(ctx context.Context, name, addr string) error {
+	return a.do(ctx, http.MethodPost, "/heartbeat", &md.Service{Name: name, Address: addr}, nil)
+}
+
+func (a *httpAPI) GetInstance(ctx context.Context, name, addr string) (*md.Service, error) {
+	var svc md.Service
+	if err := a.do(ctx, http.MethodPost, "/instance", &md.Service{Name: name, Address: addr}, &svc); err != nil {
+		return nil, err
+	}
+	return &svc, nil
+}
+
+func (a *httpAPI) Import(ctx context.Context, snap *md.Snapshot, replace bool) error {
+	return a.do(ctx, http.MethodPost, "/snapshot?format=json&replace="+strconv.FormatBool(replace), snap, nil)
+}
+
+func (a *httpAPI) Find(ctx context.Context, q *md.ServiceQuery) (string, error) {
+	var addr string
+	if err := a.do(ctx, http.MethodPost, "/find", q, &addr); err != nil {
+		return "", err
+	}
+	return addr, nil
+}
+
+func (a *httpAPI) ListServices(ctx context.Context) ([]string, error) {
+	var names []string
+	if err := a.do(ctx, http.MethodGet, "/list-svcs", nil, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (a *httpAPI) ListAddrs(ctx context.Context, q *md.ServiceQuery) ([]string, error) {
+	var addrs []string
+	if err := a.do(ctx, http.MethodPost, "/list-addrs", q, &addrs); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// do sends body as JSON and decodes the data of the response into out, unless it is nil.
+func (a *httpAPI) do(ctx context.Context, method, path string, body, out any) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.url+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var e utils.ErrorResponse
+		_ = json.NewDecoder(res.Body).Decode(&e)
+		switch res.StatusCode {
+		case http.StatusNotFound:
+			return ErrNotFound
+		case http.StatusConflict:
+			return ErrAlreadyExists
+		default:
+			return fmt.Errorf("%v %v: %v %v", method, path, res.StatusCode, e.Error)
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(&utils.Response{Data: out})
+}
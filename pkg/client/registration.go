@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"errors"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"go.uber.org/zap"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// minHeartbeat bounds how often an instance with a short ttl heartbeats
+	minHeartbeat = time.Second
+	// defaultKeepAlive is how often an instance without a ttl or a check interval
+	// makes sure it is still registered, the default cooldown of the checker
+	defaultKeepAlive = 5 * time.Second
+	// deregisterTimeout bounds the deregistration of Run once the service is stopping
+	deregisterTimeout = 5 * time.Second
+)
+
+// Registration keeps an instance registered until Deregister is called.
+type Registration struct {
+	client *Client
+	svc    md.Service
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// Start registers the instance and keeps it registered. Instances with a ttl
+// heartbeat every third of it, others as often as the checker probes them.
+// An instance missing from the registry, because it was deregistered by the
+// checker or the registry lost it, is registered again. A registration left
+// over by a previous run of the same instance is kept and updated in place.
+func (c *Client) Start(ctx context.Context, svc *md.Service) (*Registration, error) {
+	if err := c.register(ctx, svc); err != nil {
+		return nil, err
+	}
+
+	keepCtx, cancel := context.WithCancel(context.Background())
+	r := &Registration{client: c, svc: *svc, cancel: cancel}
+	r.wg.Add(1)
+	go r.keepAlive(keepCtx, keepAliveInterval(svc))
+	return r, nil
+}
+
+func keepAliveInterval(svc *md.Service) time.Duration {
+	switch {
+	case svc.TTL > 0:
+		return max(time.Duration(svc.TTL)*time.Second/3, minHeartbeat)
+	case svc.Check != nil && svc.Check.Interval > 0:
+		return max(time.Duration(svc.Check.Interval)*time.Second, minHeartbeat)
+	default:
+		return defaultKeepAlive
+	}
+}
+
+// register does not deregister an existing registration of the instance,
+// callers would lose it and watchers see it leave for nothing. Its spec is
+// updated in place when it changed, and its lease renewed.
+func (c *Client) register(ctx context.Context, svc *md.Service) error {
+	err := c.api.Register(ctx, svc)
+	if !errors.Is(err, ErrAlreadyExists) {
+		return err
+	}
+
+	cur, err := c.api.GetInstance(ctx, svc.Name, svc.Address)
+	if errors.Is(err, ErrNotFound) {
+		return c.api.Register(ctx, svc)
+	} else if err != nil {
+		return err
+	}
+
+	if !sameSpec(cur, svc) {
+		// Restoring overwrites the instance without deregistering it
+		if err = c.api.Import(ctx, respec(cur, svc), false); err != nil {
+			return err
+		}
+	}
+	return c.api.Heartbeat(ctx, svc.Name, svc.Address)
+}
+
+// sameSpec reports whether cur is registered as svc asks for.
+// A zero weight keeps the current one, as the server defaults it.
+func sameSpec(cur, svc *md.Service) bool {
+	return cur.Version == svc.Version &&
+		slices.Equal(cur.Tags, svc.Tags) &&
+		maps.Equal(cur.Metadata, svc.Metadata) &&
+		(svc.Weight == 0 || cur.Weight == svc.Weight) &&
+		cur.TTL == svc.TTL &&
+		sameCheck(cur.Check, svc.Check)
+}
+
+func sameCheck(a, b *md.Check) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && a.Path == b.Path && slices.Equal(a.Statuses, b.Statuses) &&
+		a.Interval == b.Interval && a.Timeout == b.Timeout && a.GRPCService == b.GRPCService
+}
+
+// respec is a snapshot of cur with the spec of svc. It keeps the state of cur,
+// so the instance stays in rotation with the health the checker gave it.
+func respec(cur, svc *md.Service) *md.Snapshot {
+	next := *svc
+	next.IsActive, next.Health = cur.IsActive, cur.Health
+	if next.Weight == 0 {
+		next.Weight = cur.Weight
+	}
+	return md.NewSnapshot([]md.Service{next}, time.Now().UTC())
+}
+
+func (r *Registration) keepAlive(ctx context.Context, interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := r.client.api.Heartbeat(ctx, r.svc.Name, r.svc.Address)
+		if errors.Is(err, ErrNotFound) {
+			zap.L().Info("instance missing from the registry, registering again", zap.String("name", r.svc.Name), zap.String("addr", r.svc.Address))
+			err = r.client.register(ctx, &r.svc)
+		}
+		if err != nil && ctx.Err() == nil {
+			zap.L().Warn("failed to keep the instance registered", zap.String("name", r.svc.Name), zap.String("addr", r.svc.Address), zap.Error(err))
+		}
+	}
+}
+
+// Deregister stops keeping the instance registered and removes it from the registry.
+// It is safe to call more than once, only the first call deregisters.
+func (r *Registration) Deregister(ctx context.Context) error {
+	var err error
+	r.once.Do(func() {
+		r.cancel()
+		r.wg.Wait()
+
+		err = r.client.api.Deregister(ctx, r.svc.Name, r.svc.Address)
+		if errors.Is(err, ErrNotFound) {
+			err = nil
+		}
+	})
+	return err
+}
+
+// Run registers the instance, keeps it registered until ctx is done or the
+// process receives SIGINT or SIGTERM, and deregisters it then, so callers
+// stop receiving traffic before the service shuts down.
+func (c *Client) Run(ctx context.Context, svc *md.Service) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r, err := c.Start(ctx, svc)
+	if err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	dctx, cancel := context.WithTimeout(context.Background(), deregisterTimeout)
+	defer cancel()
+	return r.Deregister(dctx)
+}
@@ -1,15 +1,16 @@
 package config
 
 import (
-	"gopkg.in/yaml.v3"
-	"os"
+	"fmt"
+	"strings"
 )
 
 type DB string
 
 const (
-	InMem  DB = "in-mem"
-	SQLite DB = "sqlite"
+	InMem    DB = "in-mem"
+	SQLite   DB = "sqlite"
+	Postgres DB = "postgres"
 )
 
 type AcceptReq string
@@ -17,39 +18,168 @@ type AcceptReq string
 const (
 	GRPC AcceptReq = "grpc"
 	HTTP AcceptReq = "http"
+	// Both serves gRPC on the server port and HTTP on the HTTP port
+	Both AcceptReq = "both"
+)
+
+type Exporter string
+
+const (
+	OTLP   Exporter = "otlp"
+	Stdout Exporter = "stdout"
+)
+
+type Strategy string
+
+const (
+	RoundRobin         Strategy = "round-robin"
+	Random             Strategy = "random"
+	WeightedRoundRobin Strategy = "weighted-round-robin"
+	PowerOfTwo         Strategy = "p2c"
+	ConsistentHash     Strategy = "consistent-hash"
 )
 
 type Config struct {
-	DB        DB             `yaml:"db" env-default:"in-mem"`
-	AcceptReq AcceptReq      `yaml:"accept-req" env-default:"grpc"`
-	Server    *ServerConfig  `yaml:"server"`
-	Checker   *CheckerConfig `yaml:"checker"`
+	DB        DB              `yaml:"db" env-default:"in-mem"`
+	AcceptReq AcceptReq       `yaml:"accept-req" env-default:"grpc"`
+	Server    *ServerConfig   `yaml:"server"`
+	Checker   *CheckerConfig  `yaml:"checker"`
+	Balancer  *BalancerConfig `yaml:"balancer"`
+	Memory    *MemoryConfig   `yaml:"memory"`
+	SQLite    *SQLiteConfig   `yaml:"sqlite"`
+	Postgres  *PostgresConfig `yaml:"postgres"`
+	Cluster   *ClusterConfig  `yaml:"cluster"`
+	DNS       *DNSConfig      `yaml:"dns"`
+	Tracing   *TracingConfig  `yaml:"tracing"`
 }
 
 type ServerConfig struct {
-	Port   int    `yaml:"port" env-required:"true"`
-	Mode   string `yaml:"mode" env-default:"dev"`
-	Scheme string `yaml:"scheme" env-default:"http"`
-	Domain string `yaml:"domain" env-default:"localhost"`
+	Port     int    `yaml:"port" env-required:"true"`
+	HTTPPort int    `yaml:"http_port"`
+	Mode     string `yaml:"mode" env-default:"dev"`
+	Scheme   string `yaml:"scheme" env-default:"http"`
+	Domain   string `yaml:"domain" env-default:"localhost"`
+	// Serves /metrics on a port of its own, it is also served by the HTTP API
+	MetricsPort int `yaml:"metrics_port"`
 }
 
 type CheckerConfig struct {
-	Req           AcceptReq `yaml:"req" env-default:"grpc"`
-	MaxRetriesReq int       `yaml:"max_retries_req" env-default:"3"`
-	CooldownReq   int       `yaml:"cooldown_req" env-default:"5"`
+	Req           AcceptReq   `yaml:"req" env-default:"grpc"`
+	MaxRetriesReq int         `yaml:"max_retries_req" env-default:"3"`
+	CooldownReq   int         `yaml:"cooldown_req" env-default:"5"`
+	Path          string      `yaml:"path" env-default:"/health-check"`
+	Timeout       int         `yaml:"timeout" env-default:"5"`
+	Rise          int         `yaml:"rise" env-default:"1"`
+	Fall          int         `yaml:"fall" env-default:"1"`
+	Flap          *FlapConfig `yaml:"flap"`
+	Workers       int         `yaml:"workers" env-default:"16"`
+	Jitter        int         `yaml:"jitter" env-default:"10"`
+	// In seconds, how often the leader lists the registry for instances registered through other replicas
+	Resync int `yaml:"resync" env-default:"30"`
+	// In seconds, how often unchanged health results are written to the registry, changes are written right away
+	HealthSync int `yaml:"health_sync" env-default:"60"`
+	// In seconds, lease of the replica running checks over a shared sqlite or postgres registry
+	LeaseTTL int `yaml:"lease_ttl" env-default:"15"`
+	// Holder of the lease, defaults to hostname and pid
+	ReplicaID string `yaml:"replica_id"`
 }
 
-func MustLoad(configPath string) *Config {
-	var conf Config
+type FlapConfig struct {
+	Threshold  int `yaml:"threshold" env-default:"4"`
+	Window     int `yaml:"window" env-default:"60"`
+	Quarantine int `yaml:"quarantine" env-default:"300"`
+}
 
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		panic("failed to read config: " + err.Error())
-	}
+type MemoryConfig struct {
+	// Empty path keeps the registry in memory only
+	SnapshotPath string `yaml:"snapshot_path"`
+	// In seconds, zero writes the snapshot on shutdown only
+	SnapshotInterval int `yaml:"snapshot_interval" env-default:"30"`
+}
+
+type SQLiteConfig struct {
+	Path        string `yaml:"path" env-default:"discovery.db"`
+	JournalMode string `yaml:"journal_mode" env-default:"WAL"`
+	// In milliseconds
+	BusyTimeout int `yaml:"busy_timeout" env-default:"5000"`
+	// Extra pragmas applied to every connection, e.g. synchronous: "NORMAL"
+	Pragmas map[string]string `yaml:"pragmas"`
+}
+
+type PostgresConfig struct {
+	// DSN takes precedence over the connection fields below
+	DSN      string `yaml:"dsn"`
+	Host     string `yaml:"host" env-default:"localhost"`
+	Port     int    `yaml:"port" env-default:"5432"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+	SSLMode  string `yaml:"sslmode" env-default:"disable"`
+
+	MaxOpenConns    int `yaml:"max_open_conns" env-default:"10"`
+	MaxIdleConns    int `yaml:"max_idle_conns" env-default:"5"`
+	ConnMaxLifetime int `yaml:"conn_max_lifetime" env-default:"300"`
+	ConnMaxIdleTime int `yaml:"conn_max_idle_time" env-default:"60"`
+}
+
+type BalancerConfig struct {
+	Strategy Strategy            `yaml:"strategy" env-default:"round-robin"`
+	Services map[string]Strategy `yaml:"services"`
+}
+
+// DNSConfig enables a DNS listener answering <name>.service.<domain> with the active instances.
+type DNSConfig struct {
+	// Served over both UDP and TCP
+	Port   int    `yaml:"port" env-default:"8600"`
+	Domain string `yaml:"domain" env-default:"discovery."`
+	// In seconds, how long resolvers may cache the answers
+	TTL int `yaml:"ttl" env-default:"5"`
+}
+
+// TracingConfig exports OpenTelemetry spans of the handlers, controller and repositories.
+type TracingConfig struct {
+	Exporter Exporter `yaml:"exporter" env-default:"otlp"`
+	// host:port of the OTLP collector, spans are sent over gRPC
+	Endpoint string `yaml:"endpoint" env-default:"localhost:4317"`
+	Insecure bool   `yaml:"insecure"`
+	// Reported as service.name
+	ServiceName string `yaml:"service_name" env-default:"service-discovery"`
+	// Share of new traces sampled, traces started by callers follow their decision
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// ClusterConfig enables the clustered mode, every node keeps the registry
+// in memory and replicates mutations through a Raft log stored in DataDir.
+type ClusterConfig struct {
+	NodeID string `yaml:"node_id" env-required:"true"`
+	// Raft traffic and writes forwarded to the leader share this address
+	BindAddr string `yaml:"bind_addr" env-default:"127.0.0.1:7000"`
+	// Address other nodes dial, defaults to the bind address
+	AdvertiseAddr string `yaml:"advertise_addr"`
+	DataDir       string `yaml:"data_dir" env-default:"raft"`
+	// Forms a new cluster out of the peers on first start, ignored once the node has state
+	Bootstrap bool         `yaml:"bootstrap"`
+	Peers     []PeerConfig `yaml:"peers"`
+	// In seconds
+	SnapshotInterval  int    `yaml:"snapshot_interval" env-default:"120"`
+	SnapshotThreshold uint64 `yaml:"snapshot_threshold" env-default:"8192"`
+	RetainSnapshots   int    `yaml:"retain_snapshots" env-default:"2"`
+	// In seconds
+	ApplyTimeout int `yaml:"apply_timeout" env-default:"5"`
+}
+
+type PeerConfig struct {
+	ID   string `yaml:"id"`
+	Addr string `yaml:"addr"`
+}
 
-	if err = yaml.Unmarshal(data, &conf); err != nil {
-		panic("failed to unmarshal config: " + err.Error())
+// UnmarshalText parses a peer written id=addr, as in DISCOVERY_CLUSTER_PEERS=node1=10.0.0.1:7000,node2=10.0.0.2:7000.
+func (p *PeerConfig) UnmarshalText(text []byte) error {
+	id, addr, ok := strings.Cut(string(text), "=")
+	if !ok || id == "" || addr == "" {
+		return fmt.Errorf("%q is not an id=addr peer", text)
 	}
 
-	return &conf
+	p.ID, p.Addr = id, addr
+	return nil
 }
@@ -0,0 +1,5 @@
+package config
+
+import "errors"
+
+var ErrInvalid = errors.New("invalid configuration")
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultPath is read when no configuration file is given, it may be missing
+	DefaultPath = "local.config.yaml"
+	// EnvPrefix starts the environment variable of every setting, e.g. DISCOVERY_SERVER_PORT
+	EnvPrefix = "DISCOVERY_"
+	// PathEnv holds the path of the configuration file, the -config flag takes precedence
+	PathEnv = EnvPrefix + "CONFIG"
+)
+
+// always lists the sections the server cannot run without, they are set up
+// with their defaults even when nothing configures them. The other sections
+// stay nil, and so disabled, unless the file, the environment or a flag sets them.
+var always = map[string]bool{
+	"server":  true,
+	"checker": true,
+}
+
+// setting is a leaf of Config, addressed by its yaml keys joined with dots, e.g. checker.flap.window.
+type setting struct {
+	path  string
+	index []int
+	tag   reflect.StructTag
+	typ   reflect.Type
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(s.path))
+}
+
+func (s setting) flag() string {
+	return "-" + s.path
+}
+
+// Load reads the configuration file at path and overrides it with the environment.
+func Load(path string) (*Config, error) {
+	return load(path, true, nil)
+}
+
+// MustLoad is Load panicking on error, for tests and tools.
+func MustLoad(configPath string) *Config {
+	conf, err := Load(configPath)
+	if err != nil {
+		panic("failed to load config: " + err.Error())
+	}
+	return conf
+}
+
+// Parse loads the configuration for the command line args. Every setting can be
+// overridden with a flag named after its yaml keys, e.g. -server.port, which takes
+// precedence over the environment. The file is taken from -config, DISCOVERY_CONFIG
+// or DefaultPath, in that order, only the latter may be missing.
+// It returns the arguments left after the flags.
+func Parse(name string, args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", "", fmt.Sprintf("configuration file, env %v (default %v)", PathEnv, DefaultPath))
+
+	flags := make(map[string]string)
+	for _, s := range settings(reflect.TypeOf(Config{}), "", nil) {
+		usage := "env " + s.env()
+		if def, ok := s.tag.Lookup("env-default"); ok {
+			usage += fmt.Sprintf(" (default %v)", def)
+		}
+
+		// Values are checked while parsing, so a typo fails with the usage
+		set := func(v string) error {
+			if err := decode(reflect.New(s.typ).Elem(), v); err != nil {
+				return err
+			}
+			flags[s.path] = v
+			return nil
+		}
+		if s.typ.Kind() == reflect.Bool {
+			fs.BoolFunc(s.path, usage, set)
+		} else {
+			fs.Func(s.path, usage, set)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	explicit := true
+	if *path == "" {
+		*path = os.Getenv(PathEnv)
+	}
+	if *path == "" {
+		*path, explicit = DefaultPath, false
+	}
+
+	conf, err := load(*path, explicit, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conf, fs.Args(), nil
+}
+
+// load applies, in increasing precedence, the defaults, the file, the environment and the flags.
+// A missing file is only an error when it is required.
+func load(path string, required bool, flags map[string]string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Overrides as source and value by setting, flags replace the environment
+	all := settings(reflect.TypeOf(Config{}), "", nil)
+	type override struct{ source, value string }
+	overrides := make(map[string]override)
+	for _, s := range all {
+		if v, ok := os.LookupEnv(s.env()); ok && v != "" {
+			overrides[s.path] = override{s.env(), v}
+		}
+		if v, ok := flags[s.path]; ok {
+			overrides[s.path] = override{s.flag(), v}
+		}
+	}
+
+	// Sections are set up before the file is decoded, so the values
+	// of the file replace the defaults, zero values included
+	prune(&doc)
+	present := keys(&doc, "")
+	for p := range overrides {
+		for _, section := range sections(p) {
+			present[section] = true
+		}
+	}
+	for p := range always {
+		present[p] = true
+	}
+
+	var conf Config
+	root := reflect.ValueOf(&conf).Elem()
+	for _, s := range all {
+		if !allPresent(sections(s.path), present) {
+			continue
+		}
+
+		v, _ := field(root, s, true)
+		if def, ok := s.tag.Lookup("env-default"); ok {
+			if err = decode(v, def); err != nil {
+				return nil, fmt.Errorf("%w: default of %v: %v", ErrInvalid, s.path, err)
+			}
+		}
+	}
+
+	if doc.Kind != 0 {
+		if err = doc.Decode(&conf); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+		}
+	}
+
+	for _, s := range all {
+		o, ok := overrides[s.path]
+		if !ok {
+			continue
+		}
+
+		v, _ := field(root, s, true)
+		if err = decode(v, o.value); err != nil {
+			return nil, fmt.Errorf("%w: %v from %v: %v", ErrInvalid, s.path, o.source, err)
+		}
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// sections lists the sections enclosing a setting, outermost first.
+func sections(path string) []string {
+	var found []string
+	for i, c := range path {
+		if c == '.' {
+			found = append(found, path[:i])
+		}
+	}
+	return found
+}
+
+func allPresent(sections []string, present map[string]bool) bool {
+	for _, section := range sections {
+		if !present[section] {
+			return false
+		}
+	}
+	return true
+}
+
+// prune drops the keys left empty, an empty section is not configured
+// and an empty setting keeps its default.
+func prune(n *yaml.Node) {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		prune(n.Content[0])
+		return
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	content := n.Content[:0]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if v.Tag == "!!null" {
+			continue
+		}
+
+		prune(v)
+		content = append(content, k, v)
+	}
+	n.Content = content
+}
+
+// keys lists the keys of the yaml mappings, nested ones joined with dots.
+func keys(n *yaml.Node, prefix string) map[string]bool {
+	found := make(map[string]bool)
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		return keys(n.Content[0], prefix)
+	}
+	if n.Kind != yaml.MappingNode {
+		return found
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		p := prefix + n.Content[i].Value
+		found[p] = true
+		for nested := range keys(n.Content[i+1], p+".") {
+			found[nested] = true
+		}
+	}
+	return found
+}
+
+// settings lists the leaves of t, sections are pointers to structs.
+func settings(t reflect.Type, prefix string, index []int) []setting {
+	var all []setting
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		idx := append(append([]int{}, index...), i)
+		if f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+			all = append(all, settings(f.Type.Elem(), prefix+name+".", idx)...)
+			continue
+		}
+
+		all = append(all, setting{path: prefix + name, index: idx, tag: f.Tag, typ: f.Type})
+	}
+	return all
+}
+
+// field returns the value of the setting, sections on the way are allocated when alloc is set.
+func field(root reflect.Value, s setting, alloc bool) (reflect.Value, bool) {
+	v := root
+	for _, i := range s.index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// decode parses a value of the environment or the command line. Lists are
+// separated by commas, map entries and peers are written key=value.
+func decode(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a positive integer", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		items := split(s)
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decode(list.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	case reflect.Map:
+		items := split(s)
+		m := reflect.MakeMapWithSize(v.Type(), len(items))
+		for _, item := range items {
+			rawKey, rawVal, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value entry", item)
+			}
+
+			key, val := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+			if err := decode(key, strings.TrimSpace(rawKey)); err != nil {
+				return err
+			}
+			if err := decode(val, strings.TrimSpace(rawVal)); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+func split(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
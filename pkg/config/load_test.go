@@ -0,0 +1,179 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults of the configured sections", func(t *testing.T) {
+		conf, err := Load(writeConfig(t, `
+server:
+  port: 50030
+dns:
+  domain: "consul."
+checker:
+  flap:
+`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, InMem, conf.DB)
+		assert.Equal(t, GRPC, conf.AcceptReq)
+		assert.Equal(t, &ServerConfig{Port: 50030, Mode: "dev", Scheme: "http", Domain: "localhost"}, conf.Server)
+		assert.Equal(t, 3, conf.Checker.MaxRetriesReq)
+		assert.Equal(t, 16, conf.Checker.Workers)
+		assert.Equal(t, &DNSConfig{Port: 8600, Domain: "consul.", TTL: 5}, conf.DNS)
+
+		// Sections left out or empty stay disabled
+		assert.Nil(t, conf.Checker.Flap)
+		assert.Nil(t, conf.Cluster)
+		assert.Nil(t, conf.Tracing)
+	})
+
+	t.Run("Zero values of the file are kept", func(t *testing.T) {
+		conf, err := Load(writeConfig(t, `
+server:
+  port: 50030
+memory:
+  snapshot_path: "registry.json"
+  snapshot_interval: 0
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, &MemoryConfig{SnapshotPath: "registry.json"}, conf.Memory)
+	})
+
+	t.Run("Environment overrides the file", func(t *testing.T) {
+		t.Setenv("DISCOVERY_SERVER_PORT", "50040")
+		t.Setenv("DISCOVERY_ACCEPT_REQ", "http")
+		t.Setenv("DISCOVERY_SQLITE_PRAGMAS", "synchronous=NORMAL, foreign_keys=ON")
+		t.Setenv("DISCOVERY_CHECKER_FLAP_WINDOW", "30")
+
+		conf, err := Load(writeConfig(t, `
+server:
+  port: 50030
+  mode: prod
+`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, 50040, conf.Server.Port)
+		assert.Equal(t, "prod", conf.Server.Mode)
+		assert.Equal(t, HTTP, conf.AcceptReq)
+		assert.Equal(t, map[string]string{"synchronous": "NORMAL", "foreign_keys": "ON"}, conf.SQLite.Pragmas)
+		assert.Equal(t, "discovery.db", conf.SQLite.Path)
+		assert.Equal(t, &FlapConfig{Threshold: 4, Window: 30, Quarantine: 300}, conf.Checker.Flap)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		t.Setenv("DISCOVERY_SERVER_PORT", "abc")
+
+		_, err := Load(writeConfig(t, ""))
+		assert.ErrorIs(t, err, ErrInvalid)
+		assert.EqualError(t, err, `invalid configuration: server.port from DISCOVERY_SERVER_PORT: "abc" is not an integer`)
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Environment only", func(t *testing.T) {
+		t.Setenv("DISCOVERY_SERVER_PORT", "50030")
+		t.Setenv("DISCOVERY_CLUSTER_NODE_ID", "node1")
+		t.Setenv("DISCOVERY_CLUSTER_PEERS", "node1=10.0.0.1:7000,node2=10.0.0.2:7000")
+
+		conf, args, err := Parse("discovery", nil)
+		assert.NoError(t, err)
+		assert.Empty(t, args)
+
+		assert.Equal(t, 50030, conf.Server.Port)
+		assert.Equal(t, "node1", conf.Cluster.NodeID)
+		assert.Equal(t, "127.0.0.1:7000", conf.Cluster.BindAddr)
+		assert.Equal(t, []PeerConfig{{ID: "node1", Addr: "10.0.0.1:7000"}, {ID: "node2", Addr: "10.0.0.2:7000"}}, conf.Cluster.Peers)
+	})
+
+	t.Run("Flags override the environment", func(t *testing.T) {
+		path := writeConfig(t, `
+server:
+  port: 50030
+`)
+		t.Setenv("DISCOVERY_SERVER_PORT", "50040")
+		t.Setenv("DISCOVERY_TRACING_EXPORTER", "stdout")
+
+		conf, args, err := Parse("discovery", []string{
+			"-config", path, "-server.port=50050", "-tracing.insecure", "export", "-o", "dump.json",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"export", "-o", "dump.json"}, args)
+
+		assert.Equal(t, 50050, conf.Server.Port)
+		assert.Equal(t, &TracingConfig{
+			Exporter:    Stdout,
+			Endpoint:    "localhost:4317",
+			Insecure:    true,
+			ServiceName: "service-discovery",
+			SampleRatio: 1,
+		}, conf.Tracing)
+	})
+
+	t.Run("Config path from the environment", func(t *testing.T) {
+		t.Setenv(PathEnv, writeConfig(t, `
+server:
+  port: 50030
+balancer:
+  services:
+    sessions: "consistent-hash"
+`))
+
+		conf, _, err := Parse("discovery", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, &BalancerConfig{Strategy: RoundRobin, Services: map[string]Strategy{"sessions": ConsistentHash}}, conf.Balancer)
+	})
+
+	t.Run("Explicit config path must exist", func(t *testing.T) {
+		_, _, err := Parse("discovery", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("Invalid flag", func(t *testing.T) {
+		_, _, err := Parse("discovery", []string{"-checker.workers", "many"})
+		assert.ErrorContains(t, err, `"many" is not an integer`)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	conf := &Config{
+		DB:        SQLite,
+		AcceptReq: Both,
+		Server:    &ServerConfig{HTTPPort: 70000},
+		Checker:   &CheckerConfig{Req: "tcp", MaxRetriesReq: 3, Fall: 4},
+		Balancer:  &BalancerConfig{Strategy: RoundRobin, Services: map[string]Strategy{"orders": "least-conn"}},
+		Cluster:   &ClusterConfig{},
+		Tracing:   &TracingConfig{Exporter: OTLP, SampleRatio: 2},
+	}
+
+	// Test case 1: Every problem is reported at once
+	err := conf.Validate()
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.EqualError(t, err, `invalid configuration: server.port is required, set it in the file, with DISCOVERY_SERVER_PORT or -server.port
+invalid configuration: cluster.node_id is required, set it in the file, with DISCOVERY_CLUSTER_NODE_ID or -cluster.node_id
+invalid configuration: server.http_port 70000 is not a port
+invalid configuration: checker.req "tcp" is not one of ["grpc" "http"]
+invalid configuration: checker.fall 4 exceeds checker.max_retries_req 3
+invalid configuration: balancer.services.orders "least-conn" is not one of ["round-robin" "random" "weighted-round-robin" "p2c" "consistent-hash"]
+invalid configuration: cluster mode requires db in-mem, got sqlite
+invalid configuration: tracing.sample_ratio 2 is not between 0 and 1`)
+
+	// Test case 2: The example configuration is valid
+	_, err = Load("../../example.config.yaml")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+const maxPort = 65535
+
+// Validate checks the configuration, every problem is reported at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalid}, args...)...))
+	}
+
+	// Required settings only count within the configured sections, e.g. cluster.node_id
+	root := reflect.ValueOf(c).Elem()
+	for _, s := range settings(root.Type(), "", nil) {
+		if _, ok := s.tag.Lookup("env-required"); !ok {
+			continue
+		}
+		if v, ok := field(root, s, false); ok && v.IsZero() {
+			invalid("%v is required, set it in the file, with %v or %v", s.path, s.env(), s.flag())
+		}
+	}
+
+	oneOf(invalid, "db", c.DB, InMem, SQLite, Postgres)
+	oneOf(invalid, "accept-req", c.AcceptReq, GRPC, HTTP, Both)
+
+	if c.Server != nil {
+		port(invalid, "server.port", c.Server.Port)
+		port(invalid, "server.http_port", c.Server.HTTPPort)
+		port(invalid, "server.metrics_port", c.Server.MetricsPort)
+
+		if c.AcceptReq == Both && (c.Server.HTTPPort == 0 || c.Server.HTTPPort == c.Server.Port) {
+			invalid("server.http_port must be set and differ from server.port when accept-req is %v", Both)
+		}
+	}
+
+	if c.Checker != nil {
+		oneOf(invalid, "checker.req", c.Checker.Req, GRPC, HTTP)
+
+		// Instances are deregistered after max_retries_req failures, they have to be marked failing first
+		if max(c.Checker.Fall, 1) > max(c.Checker.MaxRetriesReq, 1) {
+			invalid("checker.fall %v exceeds checker.max_retries_req %v", c.Checker.Fall, c.Checker.MaxRetriesReq)
+		}
+	}
+
+	if c.Balancer != nil {
+		strategies := []Strategy{RoundRobin, Random, WeightedRoundRobin, PowerOfTwo, ConsistentHash}
+		oneOf(invalid, "balancer.strategy", c.Balancer.Strategy, strategies...)
+		for name, strategy := range c.Balancer.Services {
+			oneOf(invalid, "balancer.services."+name, strategy, strategies...)
+		}
+	}
+
+	if c.Postgres != nil {
+		port(invalid, "postgres.port", c.Postgres.Port)
+	}
+
+	if c.Cluster != nil && c.DB != InMem {
+		invalid("cluster mode requires db %v, got %v", InMem, c.DB)
+	}
+
+	if c.DNS != nil {
+		port(invalid, "dns.port", c.DNS.Port)
+	}
+
+	if c.Tracing != nil {
+		oneOf(invalid, "tracing.exporter", c.Tracing.Exporter, OTLP, Stdout)
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			invalid("tracing.sample_ratio %v is not between 0 and 1", c.Tracing.SampleRatio)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf[T ~string](invalid func(string, ...any), path string, v T, allowed ...T) {
+	if !slices.Contains(allowed, v) {
+		invalid("%v %q is not one of %q", path, v, allowed)
+	}
+}
+
+func port(invalid func(string, ...any), path string, p int) {
+	if p < 0 || p > maxPort {
+		invalid("%v %v is not a port", path, p)
+	}
+}
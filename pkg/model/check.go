@@ -0,0 +1,22 @@
+package model
+
+type CheckType string
+
+const (
+	CheckHTTP CheckType = "http"
+	CheckGRPC CheckType = "grpc"
+	CheckTCP  CheckType = "tcp"
+	CheckTTL  CheckType = "ttl"
+	CheckNone CheckType = "none"
+)
+
+// Check describes how the checker probes an instance.
+// Zero fields fall back to the checker configuration.
+type Check struct {
+	Type        CheckType `json:"type" yaml:"type"`
+	Path        string    `json:"path" yaml:"path"`
+	Statuses    []int     `json:"statuses" yaml:"statuses"`
+	Interval    int       `json:"interval" yaml:"interval"`
+	Timeout     int       `json:"timeout" yaml:"timeout"`
+	GRPCService string    `json:"grpc_service" yaml:"grpc_service"`
+}
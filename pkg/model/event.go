@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type EventType string
+
+const (
+	Registered   EventType = "REGISTERED"
+	Deregistered EventType = "DEREGISTERED"
+	Activated    EventType = "ACTIVATED"
+	Deactivated  EventType = "DEACTIVATED"
+)
+
+type ServiceEvent struct {
+	Type      EventType `json:"type"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Timestamp time.Time `json:"timestamp"`
+}
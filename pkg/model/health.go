@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type HealthState string
+
+const (
+	HealthUnknown     HealthState = "unknown"
+	HealthPassing     HealthState = "passing"
+	HealthFailing     HealthState = "failing"
+	HealthQuarantined HealthState = "quarantined"
+)
+
+// Health is the state of an instance as seen by the checker.
+// Successes and Failures count consecutive probe results, Reason explains the current state.
+type Health struct {
+	State            HealthState `json:"state" yaml:"state"`
+	Reason           string      `json:"reason" yaml:"reason"`
+	Successes        int         `json:"successes" yaml:"successes"`
+	Failures         int         `json:"failures" yaml:"failures"`
+	Since            time.Time   `json:"since" yaml:"since"`
+	QuarantinedUntil time.Time   `json:"quarantined_until" yaml:"quarantined_until"`
+	LastCheck        time.Time   `json:"last_check" yaml:"last_check"`
+	LastResult       bool        `json:"last_result" yaml:"last_result"`
+	LastError        string      `json:"last_error" yaml:"last_error"`
+}
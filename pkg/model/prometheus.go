@@ -0,0 +1,102 @@
+package model
+
+import (
+	"cmp"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Labels of the target groups, relabel them to keep them on the scraped series.
+const (
+	PrometheusLabelPrefix   = "__meta_discovery_"
+	PrometheusServiceLabel  = PrometheusLabelPrefix + "service"
+	PrometheusActiveLabel   = PrometheusLabelPrefix + "active"
+	PrometheusHealthLabel   = PrometheusLabelPrefix + "health"
+	PrometheusVersionLabel  = PrometheusLabelPrefix + "version"
+	PrometheusTagsLabel     = PrometheusLabelPrefix + "tags"
+	PrometheusMetadataLabel = PrometheusLabelPrefix + "metadata_"
+)
+
+// TargetGroup is an entry of the Prometheus http_sd_config format.
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// NewTargetGroups groups the instances of every service sharing the same labels.
+// Addresses with a scheme are scraped over it, the scheme is dropped from the target.
+func NewTargetGroups(svcs []Service) []TargetGroup {
+	svcs = slices.Clone(svcs)
+	slices.SortFunc(svcs, func(a, b Service) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Address, b.Address))
+	})
+
+	groups := make([]TargetGroup, 0)
+	index := make(map[string]int)
+	for i := range svcs {
+		target, labels := prometheusTarget(&svcs[i])
+
+		k := labelsKey(labels)
+		if at, ok := index[k]; ok {
+			groups[at].Targets = append(groups[at].Targets, target)
+			continue
+		}
+
+		index[k] = len(groups)
+		groups = append(groups, TargetGroup{Targets: []string{target}, Labels: labels})
+	}
+
+	return groups
+}
+
+func prometheusTarget(svc *Service) (string, map[string]string) {
+	labels := map[string]string{
+		PrometheusServiceLabel: svc.Name,
+		PrometheusActiveLabel:  strconv.FormatBool(svc.IsActive),
+	}
+	if svc.Health.State != "" {
+		labels[PrometheusHealthLabel] = string(svc.Health.State)
+	}
+	if svc.Version != "" {
+		labels[PrometheusVersionLabel] = svc.Version
+	}
+	if len(svc.Tags) > 0 {
+		// Delimited on both ends, so a regex can match a whole tag: .*,canary,.*
+		labels[PrometheusTagsLabel] = "," + strings.Join(svc.Tags, ",") + ","
+	}
+	for k, v := range svc.Metadata {
+		labels[PrometheusMetadataLabel+labelName(k)] = v
+	}
+
+	target := svc.Address
+	if u, err := url.Parse(svc.Address); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		target = u.Host
+		labels["__scheme__"] = u.Scheme
+	}
+
+	return target, labels
+}
+
+// labelName replaces the characters Prometheus does not accept in label names.
+func labelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+func labelsKey(labels map[string]string) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package model
+
+import "slices"
+
+// Selector narrows a lookup down to instances carrying the given labels and tags.
+// The "version" label matches Service.Version, other labels match Service.Metadata.
+type Selector struct {
+	Labels map[string]string
+	Tags   []string
+}
+
+type ServiceQuery struct {
+	Name     string   `json:"name"`
+	Selector string   `json:"selector"`
+	Tags     []string `json:"tags"`
+	HashKey  string   `json:"hash_key"`
+}
+
+func (s Selector) Matches(svc *Service) bool {
+	for k, v := range s.Labels {
+		if k == "version" {
+			if svc.Version != v {
+				return false
+			}
+			continue
+		}
+
+		if actual, ok := svc.Metadata[k]; !ok || actual != v {
+			return false
+		}
+	}
+
+	for _, tag := range s.Tags {
+		if !slices.Contains(svc.Tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -2,11 +2,32 @@ package model
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
+// DefaultWeight is assigned on registration when no weight is given.
+// A weight of zero set afterwards drains the instance from FindServiceByName.
+const DefaultWeight = 1
+
 type Service struct {
 	gorm.Model
-	Name     string `gorm:"index;not null" json:"name"`
-	Address  string `gorm:"not null" json:"address"`
-	IsActive bool   `gorm:"not null" json:"is_active"`
+	Name     string            `gorm:"index;not null;uniqueIndex:idx_services_name_address,where:deleted_at IS NULL" json:"name"`
+	Address  string            `gorm:"not null;uniqueIndex:idx_services_name_address" json:"address"`
+	IsActive bool              `gorm:"not null" json:"is_active"`
+	Weight   int               `gorm:"not null;default:1" json:"weight"`
+	Version  string            `json:"version"`
+	Tags     []string          `gorm:"serializer:json" json:"tags"`
+	Metadata map[string]string `gorm:"serializer:json" json:"metadata"`
+
+	// TTL in seconds switches the instance to lease mode: it has to heartbeat
+	// before the lease expires instead of being probed by the checker.
+	TTL           int       `json:"ttl"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+
+	Check  *Check `gorm:"serializer:json" json:"check"`
+	Health Health `gorm:"serializer:json" json:"health"`
+}
+
+func (s *Service) LeaseExpired(now time.Time) bool {
+	return s.TTL > 0 && now.Sub(s.LastHeartbeat) > time.Duration(s.TTL)*time.Second
 }
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is the format version written by NewSnapshot.
+const SnapshotVersion = 1
+
+// Snapshot is a backend independent dump of the registry.
+// It is grouped by service so that checked-in seed files stay readable.
+type Snapshot struct {
+	Version   int               `json:"version" yaml:"version"`
+	CreatedAt time.Time         `json:"created_at" yaml:"created_at"`
+	Services  []SnapshotService `json:"services" yaml:"services"`
+}
+
+type SnapshotService struct {
+	Name      string             `json:"name" yaml:"name"`
+	Instances []SnapshotInstance `json:"instances" yaml:"instances"`
+}
+
+// SnapshotInstance is an instance as stored in a snapshot.
+// Omitted IsActive and Weight mean an active instance with DefaultWeight,
+// the same as a fresh registration.
+type SnapshotInstance struct {
+	Address  string            `json:"address" yaml:"address"`
+	IsActive *bool             `json:"is_active,omitempty" yaml:"is_active,omitempty"`
+	Weight   *int              `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Version  string            `json:"version,omitempty" yaml:"version,omitempty"`
+	Tags     []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	TTL      int               `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Check    *Check            `json:"check,omitempty" yaml:"check,omitempty"`
+	Health   *Health           `json:"health,omitempty" yaml:"health,omitempty"`
+}
+
+// NewSnapshot groups instances by service name, both sorted.
+func NewSnapshot(svcs []Service, now time.Time) *Snapshot {
+	snap := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: now,
+		Services:  make([]SnapshotService, 0),
+	}
+
+	svcs = slices.Clone(svcs)
+	slices.SortFunc(svcs, func(a, b Service) int {
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Address, b.Address)
+	})
+
+	for _, svc := range svcs {
+		if n := len(snap.Services); n == 0 || snap.Services[n-1].Name != svc.Name {
+			snap.Services = append(snap.Services, SnapshotService{Name: svc.Name})
+		}
+
+		active, weight := svc.IsActive, svc.Weight
+		inst := SnapshotInstance{
+			Address:  svc.Address,
+			IsActive: &active,
+			Weight:   &weight,
+			Version:  svc.Version,
+			Tags:     svc.Tags,
+			Metadata: svc.Metadata,
+			TTL:      svc.TTL,
+			Check:    svc.Check,
+		}
+		// Instances that were never checked carry no health
+		if svc.Health != (Health{}) {
+			health := svc.Health
+			inst.Health = &health
+		}
+
+		last := &snap.Services[len(snap.Services)-1]
+		last.Instances = append(last.Instances, inst)
+	}
+
+	return snap
+}
+
+// Instances flattens the snapshot back into services.
+// Leases restart at now, so lease-mode instances get a full ttl
+// to heartbeat the backend they were restored into.
+func (s *Snapshot) Instances(now time.Time) []Service {
+	res := make([]Service, 0)
+	for _, svc := range s.Services {
+		for _, inst := range svc.Instances {
+			restored := Service{
+				Name:     svc.Name,
+				Address:  inst.Address,
+				IsActive: true,
+				Weight:   DefaultWeight,
+				Version:  inst.Version,
+				Tags:     inst.Tags,
+				Metadata: inst.Metadata,
+				TTL:      inst.TTL,
+				Check:    inst.Check,
+
+				LastHeartbeat: now,
+			}
+			if inst.IsActive != nil {
+				restored.IsActive = *inst.IsActive
+			}
+			if inst.Weight != nil {
+				restored.Weight = *inst.Weight
+			}
+			if inst.Health != nil {
+				restored.Health = *inst.Health
+			}
+			res = append(res, restored)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,7 @@
+package resolver
+
+import "errors"
+
+var ErrMissingName = errors.New("target has no service name, expected discovery:///<name>")
+var ErrNoInstances = errors.New("no instances of the service are available")
+var ErrWatchClosed = errors.New("watch closed by the server")
@@ -0,0 +1,218 @@
+// Package resolver resolves "discovery:///<name>" gRPC targets through the registry,
+// so the balancers of grpc-go (round_robin, pick_first) pick among its instances.
+//
+// The target may filter instances like ListAddrs does:
+//
+//	discovery:///orders?selector=version%3Dv2&tag=canary&tag=eu-1
+package resolver
+
+import (
+	"context"
+	"errors"
+	pb "github.com/JMURv/service-discovery/api/pb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheme of the targets resolved through the registry.
+const Scheme = "discovery"
+
+const (
+	// refreshInterval re-lists the instances even when no event is received,
+	// so an event missed while the watch was being established is caught up.
+	refreshInterval = 30 * time.Second
+	minRetry        = 100 * time.Millisecond
+	maxRetry        = 30 * time.Second
+	requestTimeout  = 10 * time.Second
+)
+
+// Builder builds resolvers that list and watch instances through the client of a discovery server.
+type Builder struct {
+	client pb.ServiceDiscoveryClient
+}
+
+func New(client pb.ServiceDiscoveryClient) *Builder {
+	return &Builder{client: client}
+}
+
+// Register installs the builder globally, so "discovery:///" targets resolve
+// without passing grpc.WithResolvers to every dial. It is not safe to call
+// concurrently with dials, call it during initialization.
+func Register(client pb.ServiceDiscoveryClient) {
+	resolver.Register(New(client))
+}
+
+func (b *Builder) Scheme() string {
+	return Scheme
+}
+
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	name := strings.TrimPrefix(target.Endpoint(), "/")
+	if name == "" {
+		return nil, ErrMissingName
+	}
+
+	query := target.URL.Query()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &discoveryResolver{
+		client: b.client,
+		cc:     cc,
+		req: &pb.ServiceNameMsg{
+			Name:     name,
+			Selector: query.Get("selector"),
+			Tags:     query["tag"],
+		},
+		cancel: cancel,
+		now:    make(chan struct{}, 1),
+	}
+
+	r.wg.Add(1)
+	go r.run(ctx)
+	return r, nil
+}
+
+type discoveryResolver struct {
+	client pb.ServiceDiscoveryClient
+	cc     resolver.ClientConn
+	req    *pb.ServiceNameMsg
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// now asks run to list the instances again
+	now chan struct{}
+}
+
+// ResolveNow is called by gRPC when connecting to the instances fails.
+func (r *discoveryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	signal(r.now)
+}
+
+func (r *discoveryResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// run keeps a watch on the service open and lists its instances on every event.
+// A broken watch is established again with an exponential backoff.
+func (r *discoveryResolver) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	events := make(chan struct{}, 1)
+	go r.watch(ctx, events)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	retry := minRetry
+	var backoff <-chan time.Time
+	for {
+		if err := r.resolve(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.cc.ReportError(err)
+			backoff = time.After(retry)
+			retry = min(2*retry, maxRetry)
+		} else {
+			backoff = nil
+			retry = minRetry
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-events:
+		case <-r.now:
+		case <-ticker.C:
+		case <-backoff:
+		}
+	}
+}
+
+// resolve hands the current instances to gRPC.
+func (r *discoveryResolver) resolve(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	res, err := r.client.ListAddrs(ctx, r.req)
+	if status.Code(err) == codes.NotFound {
+		return ErrNoInstances
+	} else if err != nil {
+		return err
+	}
+	if len(res.Address) == 0 {
+		return ErrNoInstances
+	}
+
+	addrs := make([]resolver.Address, 0, len(res.Address))
+	for _, addr := range res.Address {
+		addrs = append(addrs, resolver.Address{Addr: hostPort(addr)})
+	}
+	return r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+// hostPort strips the scheme instances are registered with, e.g. http://10.0.0.1:8080,
+// gRPC dials host:port.
+func hostPort(addr string) string {
+	if !strings.Contains(addr, "://") {
+		return addr
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return addr
+	}
+	return u.Host
+}
+
+// watch signals every membership change of the service, until ctx is done.
+func (r *discoveryResolver) watch(ctx context.Context, events chan<- struct{}) {
+	retry := minRetry
+	for {
+		stream, err := r.client.Watch(ctx, &pb.ServiceNameMsg{Name: r.req.Name})
+		if err == nil {
+			retry = minRetry
+			// Changes made while the watch was down are caught up by listing again
+			signal(events)
+			err = forward(stream, events)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		zap.L().Debug("watch of service broken", zap.String("name", r.req.Name), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, maxRetry)
+	}
+}
+
+// forward signals the events of a watch until it breaks.
+func forward(stream pb.ServiceDiscovery_WatchClient, events chan<- struct{}) error {
+	for {
+		if _, err := stream.Recv(); errors.Is(err, io.EOF) {
+			return ErrWatchClosed
+		} else if err != nil {
+			return err
+		}
+		signal(events)
+	}
+}
+
+// signal never blocks, a pending signal already triggers a new listing.
+func signal(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
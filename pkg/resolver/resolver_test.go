@@ -0,0 +1,183 @@
+package resolver
+
+import (
+	"context"
+	pb "github.com/JMURv/service-discovery/api/pb"
+	"github.com/JMURv/service-discovery/internal/balancer"
+	"github.com/JMURv/service-discovery/internal/broker"
+	"github.com/JMURv/service-discovery/internal/ctrl"
+	hdl "github.com/JMURv/service-discovery/internal/hdl/grpc"
+	"github.com/JMURv/service-discovery/internal/repo/memory"
+	md "github.com/JMURv/service-discovery/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startDiscovery serves the registry over an in-process connection.
+func startDiscovery(t *testing.T) (*ctrl.Controller, pb.ServiceDiscoveryClient) {
+	svc := ctrl.New(memory.New(balancer.NewRoundRobin()), make(chan md.Service, 16), broker.New())
+
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterServiceDiscoveryServer(srv, hdl.New(svc))
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return svc, pb.NewServiceDiscoveryClient(conn)
+}
+
+// clientConn records the states a resolver reports.
+type clientConn struct {
+	resolver.ClientConn
+
+	mu    sync.Mutex
+	addrs []string
+	err   error
+}
+
+func (c *clientConn) UpdateState(s resolver.State) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addrs, c.err = nil, nil
+	for _, addr := range s.Addresses {
+		c.addrs = append(c.addrs, addr.Addr)
+	}
+	slices.Sort(c.addrs)
+	return nil
+}
+
+func (c *clientConn) ReportError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *clientConn) resolved(addrs ...string) func() bool {
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err == nil && slices.Equal(c.addrs, addrs)
+	}
+}
+
+func (c *clientConn) failed(err error) func() bool {
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err == err
+	}
+}
+
+func build(t *testing.T, b *Builder, target string) *clientConn {
+	u, err := url.Parse(target)
+	require.NoError(t, err)
+
+	cc := &clientConn{}
+	r, err := b.Build(resolver.Target{URL: *u}, cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	return cc
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+	svc, client := startDiscovery(t)
+	b := New(client)
+
+	require.NoError(t, svc.Register(ctx, &md.Service{Name: "orders", Address: "addr1", Version: "v1"}))
+	require.NoError(t, svc.Register(ctx, &md.Service{Name: "orders", Address: "addr2", Version: "v2", Tags: []string{"canary"}}))
+
+	t.Run("Instances are listed on build", func(t *testing.T) {
+		cc := build(t, b, "discovery:///orders")
+		assert.Eventually(t, cc.resolved("addr1", "addr2"), 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Target filters instances", func(t *testing.T) {
+		cc := build(t, b, "discovery:///orders?selector=version%3Dv2&tag=canary")
+		assert.Eventually(t, cc.resolved("addr2"), 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Membership changes are followed", func(t *testing.T) {
+		cc := build(t, b, "discovery:///orders")
+		assert.Eventually(t, cc.resolved("addr1", "addr2"), 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, svc.Register(ctx, &md.Service{Name: "orders", Address: "addr3"}))
+		assert.Eventually(t, cc.resolved("addr1", "addr2", "addr3"), 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, svc.Deregister(ctx, "orders", "addr1"))
+		assert.Eventually(t, cc.resolved("addr2", "addr3"), 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Schemes are stripped", func(t *testing.T) {
+		require.NoError(t, svc.Register(ctx, &md.Service{Name: "billing", Address: "http://10.0.0.1:8080"}))
+		require.NoError(t, svc.Register(ctx, &md.Service{Name: "billing", Address: "https://[::1]:8443/api"}))
+
+		cc := build(t, b, "discovery:///billing")
+		assert.Eventually(t, cc.resolved("10.0.0.1:8080", "[::1]:8443"), 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Unknown service", func(t *testing.T) {
+		cc := build(t, b, "discovery:///payments")
+		assert.Eventually(t, cc.failed(ErrNoInstances), 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Missing name", func(t *testing.T) {
+		u, _ := url.Parse("discovery:///")
+		_, err := b.Build(resolver.Target{URL: *u}, &clientConn{}, resolver.BuildOptions{})
+		assert.Equal(t, ErrMissingName, err)
+	})
+}
+
+func TestDial(t *testing.T) {
+	ctx := context.Background()
+	svc, client := startDiscovery(t)
+
+	// A backend serving the standard health service
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	// Registered with a scheme, as the checker expects
+	require.NoError(t, svc.Register(ctx, &md.Service{Name: "health", Address: "http://" + ln.Addr().String()}))
+
+	conn, err := grpc.NewClient(
+		"discovery:///health",
+		grpc.WithResolvers(New(client)),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin": {}}]}`),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+}